@@ -0,0 +1,131 @@
+package campaign
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// maxRecipientIssues — сколько отклонённых и повторных адресов ответ
+// перечисляет поимённо; счётчики учитывают все.
+const maxRecipientIssues = 100
+
+// RecipientsReport — что стало с recipients запроса после проверки.
+type RecipientsReport struct {
+	Accepted   int `json:"accepted"`
+	Rejected   int `json:"rejected"`
+	Duplicates int `json:"duplicates"`
+	// Suppressed — сколько из принятых сразу помечены suppressed: адрес в
+	// списке блокировок, письмо ему не уйдёт.
+	Suppressed int              `json:"suppressed"`
+	Issues     []RecipientIssue `json:"issues,omitempty"`
+}
+
+// RecipientIssue — отклонённый или повторный получатель; Index — позиция в recipients.
+type RecipientIssue struct {
+	Index   int    `json:"index"`
+	Address string `json:"address"`
+	Reason  string `json:"reason"`
+}
+
+// NormalizeAddress разбирает адрес по RFC 5322, в том числе в форме
+// "Имя <адрес>", и приводит его к виду local@domain: пробелы по краям
+// убраны, домен в нижнем регистре, IDN-домен в punycode. Локальная часть
+// не меняется — её регистр значим для сервера получателя.
+func NormalizeAddress(s string) (addr, name string, err error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", "", errors.New("address is required")
+	}
+	a, err := mail.ParseAddress(s)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid address: %w", err)
+	}
+	at := strings.LastIndexByte(a.Address, '@')
+	local, domain := a.Address[:at], a.Address[at+1:]
+	if len(local) > 64 {
+		return "", "", errors.New("invalid address: local part is longer than 64 octets")
+	}
+
+	if strings.HasPrefix(domain, "[") {
+		// адресный литерал вроде [192.0.2.1]
+		domain = strings.ToLower(domain)
+	} else {
+		domain, err = idna.Lookup.ToASCII(domain)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid address: domain: %w", err)
+		}
+		if !strings.Contains(domain, ".") {
+			return "", "", errors.New("invalid address: domain must be fully qualified")
+		}
+	}
+
+	addr = local + "@" + domain
+	if len(addr) > 254 {
+		return "", "", errors.New("invalid address: longer than 254 octets")
+	}
+	return addr, a.Name, nil
+}
+
+// SuppressionKey приводит адрес к виду, в котором он хранится в списке
+// блокировок: нормализованный и целиком в нижнем регистре, чтобы
+// блокировка не обходилась регистром локальной части.
+func SuppressionKey(s string) (string, error) {
+	addr, _, err := NormalizeAddress(s)
+	if err != nil {
+		return "", err
+	}
+	return strings.ToLower(addr), nil
+}
+
+// Normalize проверяет получателя, пришедшего из запроса или импорта, и
+// возвращает его с нормализованным адресом. Имя из формы "Имя <адрес>"
+// используется, если name не задан явно.
+func (r Recipient) Normalize() (Recipient, error) {
+	addr, name, err := NormalizeAddress(r.Address)
+	if err != nil {
+		return Recipient{}, err
+	}
+	r.Address = addr
+	if r.Name == "" {
+		r.Name = name
+	}
+	if hasCRLF(r.Name) {
+		return Recipient{}, errors.New("name must not contain line breaks")
+	}
+	return r, nil
+}
+
+// PrepareRecipients нормализует получателей и убирает повторы адресов:
+// из нескольких записей с одним адресом остаётся первая.
+func PrepareRecipients(rs []Recipient) ([]Recipient, RecipientsReport) {
+	out := make([]Recipient, 0, len(rs))
+	var rep RecipientsReport
+	seen := make(map[string]int, len(rs))
+	issue := func(i int, reason string) {
+		if len(rep.Issues) < maxRecipientIssues {
+			rep.Issues = append(rep.Issues, RecipientIssue{Index: i, Address: rs[i].Address, Reason: reason})
+		}
+	}
+
+	for i, r := range rs {
+		n, err := r.Normalize()
+		if err != nil {
+			rep.Rejected++
+			issue(i, err.Error())
+			continue
+		}
+		if first, ok := seen[n.Address]; ok {
+			rep.Duplicates++
+			issue(i, fmt.Sprintf("duplicate of recipients[%d]", first))
+			continue
+		}
+		seen[n.Address] = i
+		out = append(out, n)
+	}
+	rep.Accepted = len(out)
+	return out, rep
+}
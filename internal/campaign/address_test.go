@@ -0,0 +1,68 @@
+package campaign
+
+import "testing"
+
+func TestNormalizeAddress(t *testing.T) {
+	cases := []struct {
+		in, addr, name string
+	}{
+		{"a@example.com", "a@example.com", ""},
+		{"  John.Doe@Example.COM\t", "John.Doe@example.com", ""},
+		{"Ann Lee <ann@example.com>", "ann@example.com", "Ann Lee"},
+		{`"Lee, Ann" <ann@EXAMPLE.com>`, "ann@example.com", "Lee, Ann"},
+		{"<ann@example.com>", "ann@example.com", ""},
+		{"user@пример.рф", "user@xn--e1afmkfd.xn--p1ai", ""},
+		{"user@ПРИМЕР.РФ", "user@xn--e1afmkfd.xn--p1ai", ""},
+		{"user@xn--e1afmkfd.xn--p1ai", "user@xn--e1afmkfd.xn--p1ai", ""},
+		{"user@[192.0.2.1]", "user@[192.0.2.1]", ""},
+	}
+	for _, c := range cases {
+		addr, name, err := NormalizeAddress(c.in)
+		if err != nil {
+			t.Errorf("%q: %v", c.in, err)
+			continue
+		}
+		if addr != c.addr || name != c.name {
+			t.Errorf("%q: got %q %q, want %q %q", c.in, addr, name, c.addr, c.name)
+		}
+	}
+}
+
+func TestNormalizeAddress_Invalid(t *testing.T) {
+	for _, in := range []string{
+		"",
+		"   ",
+		"foo",
+		"a@localhost",
+		"a@b.com, c@d.com",
+		"a b@example.com",
+		"a@exa_mple.com",
+		"a@-example.com",
+	} {
+		if addr, _, err := NormalizeAddress(in); err == nil {
+			t.Errorf("%q: want error, got %q", in, addr)
+		}
+	}
+}
+
+func TestPrepareRecipients(t *testing.T) {
+	rs := []Recipient{
+		{Address: "a@example.com", Vars: map[string]any{"n": 1}},
+		{Address: "bad"},
+		{Address: "A <a@EXAMPLE.com>", Vars: map[string]any{"n": 2}},
+		{Address: "b@example.com", Name: "Line\nbreak"},
+		{Address: "Bob <b@example.com>"},
+	}
+	out, rep := PrepareRecipients(rs)
+
+	if rep.Accepted != 2 || rep.Rejected != 2 || rep.Duplicates != 1 || len(rep.Issues) != 3 {
+		t.Fatalf("unexpected report: %+v", rep)
+	}
+	if out[0].Vars["n"] != 1 || out[1].Address != "b@example.com" || out[1].Name != "Bob" {
+		t.Fatalf("unexpected recipients: %+v", out)
+	}
+	if rep.Issues[0].Index != 1 || rep.Issues[0].Address != "bad" ||
+		rep.Issues[1].Reason != "duplicate of recipients[0]" || rep.Issues[2].Index != 3 {
+		t.Fatalf("unexpected issues: %+v", rep.Issues)
+	}
+}
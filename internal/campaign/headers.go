@@ -0,0 +1,134 @@
+package campaign
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/textproto"
+	"strings"
+)
+
+// maxHeaderLine — предел длины строки заголовка из RFC 5322 без CRLF.
+const maxHeaderLine = 998
+
+// headerFoldWidth — ширина, по которой сборщик письма переносит заголовки.
+const headerFoldWidth = 78
+
+// maxAddressLen — предел длины адреса: путь SMTP из RFC 5321 — 256 октетов
+// вместе с угловыми скобками.
+const maxAddressLen = 254
+
+// reservedHeaders формирует сам воркер; задать их через headers нельзя.
+var reservedHeaders = map[string]bool{
+	"From":                      true,
+	"Sender":                    true,
+	"To":                        true,
+	"Cc":                        true,
+	"Bcc":                       true,
+	"Reply-To":                  true,
+	"Subject":                   true,
+	"Date":                      true,
+	"Message-Id":                true,
+	"Return-Path":               true,
+	"Mime-Version":              true,
+	"Content-Type":              true,
+	"Content-Transfer-Encoding": true,
+}
+
+// Validate проверяет поля, которые попадут в заголовки письма.
+func (r *CreateCampaignReq) Validate() error {
+	if r.Import && len(r.Recipients) > 0 {
+		return errors.New("recipients must not be set when import is true")
+	}
+	if !r.Import && len(r.Recipients) == 0 {
+		return errors.New("recipients must not be empty")
+	}
+	if hasCRLF(r.Subject) {
+		return errors.New("subject must not contain line breaks")
+	}
+	if !foldable("Subject", r.Subject) {
+		return errTooLong("subject")
+	}
+	if hasCRLF(r.FromName) {
+		return errors.New("from_name must not contain line breaks")
+	}
+	if !foldable("From", r.FromName) {
+		return errTooLong("from_name")
+	}
+	if r.FromAddress != "" {
+		if err := validateAddress(r.FromAddress); err != nil {
+			return fmt.Errorf("from_address: %w", err)
+		}
+	}
+	if r.ReplyTo != "" {
+		if err := validateAddress(r.ReplyTo); err != nil {
+			return fmt.Errorf("reply_to: %w", err)
+		}
+	}
+	return ValidateHeaders(r.Headers)
+}
+
+func ValidateHeaders(h map[string]string) error {
+	for name, value := range h {
+		if name == "" {
+			return errors.New("header name must not be empty")
+		}
+		for _, c := range name {
+			// RFC 5322: печатные ASCII-символы, кроме двоеточия
+			if c < '!' || c > '~' || c == ':' {
+				return fmt.Errorf("header %q: invalid name", name)
+			}
+		}
+		if reservedHeaders[textproto.CanonicalMIMEHeaderKey(name)] {
+			return fmt.Errorf("header %q is set by the sender and cannot be overridden", name)
+		}
+		if hasCRLF(value) {
+			return fmt.Errorf("header %q: value must not contain line breaks", name)
+		}
+		if !foldable(name, value) {
+			return fmt.Errorf("header %q: %w", name, errTooLong("value"))
+		}
+	}
+	return nil
+}
+
+// validateAddress принимает только голый адрес без отображаемого имени.
+func validateAddress(s string) error {
+	a, err := mail.ParseAddress(s)
+	if err != nil {
+		return err
+	}
+	if a.Name != "" || a.Address != s {
+		return errors.New("must be a bare email address")
+	}
+	if len(s) > maxAddressLen {
+		return fmt.Errorf("must not be longer than %d octets", maxAddressLen)
+	}
+	return nil
+}
+
+func hasCRLF(s string) bool {
+	return strings.ContainsAny(s, "\r\n")
+}
+
+// foldable сообщает, уложится ли заголовок name: value в строки по
+// maxHeaderLine октетов. Перенос повторяет сборщик письма: только по
+// пробелу перед непустым словом, когда строка длиннее headerFoldWidth,
+// так что лишние пробелы остаются в конце строки.
+func foldable(name, value string) bool {
+	lineLen := len(name) + 1
+	for i, word := range strings.Split(value, " ") {
+		if i > 0 && word != "" && lineLen+1+len(word) > headerFoldWidth {
+			lineLen = 0
+		}
+		lineLen += 1 + len(word)
+		if lineLen > maxHeaderLine {
+			return false
+		}
+	}
+	return true
+}
+
+func errTooLong(field string) error {
+	return fmt.Errorf("%s has a word too long to fit a %d-octet header line", field, maxHeaderLine)
+}
@@ -0,0 +1,157 @@
+package campaign
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// RetryPolicy — правила повторной отправки писем кампании.
+type RetryPolicy struct {
+	// MaxAttempts — сколько всего попыток отправки, включая первую.
+	MaxAttempts int
+	// BaseDelay — пауза перед первым повтором; дальше она удваивается до MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// Jitter — доля случайного разброса паузы, от 0 до 1.
+	Jitter float64
+	// Deadline ограничивает время от первой неудачной попытки до последнего
+	// повтора; ноль — без ограничения.
+	Deadline time.Duration
+}
+
+const maxRetryAttempts = 100
+
+// retryPolicyJSON — представление RetryPolicy в API и в БД: паузы строками вида "30s".
+type retryPolicyJSON struct {
+	MaxAttempts int      `json:"max_attempts"`
+	BaseDelay   Duration `json:"base_delay"`
+	MaxDelay    Duration `json:"max_delay"`
+	Jitter      float64  `json:"jitter"`
+	Deadline    Duration `json:"deadline"`
+}
+
+func (p RetryPolicy) MarshalJSON() ([]byte, error) {
+	return json.Marshal(retryPolicyJSON{
+		MaxAttempts: p.MaxAttempts,
+		BaseDelay:   Duration(p.BaseDelay),
+		MaxDelay:    Duration(p.MaxDelay),
+		Jitter:      p.Jitter,
+		Deadline:    Duration(p.Deadline),
+	})
+}
+
+func (p *RetryPolicy) UnmarshalJSON(data []byte) error {
+	var v retryPolicyJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*p = RetryPolicy{
+		MaxAttempts: v.MaxAttempts,
+		BaseDelay:   time.Duration(v.BaseDelay),
+		MaxDelay:    time.Duration(v.MaxDelay),
+		Jitter:      v.Jitter,
+		Deadline:    time.Duration(v.Deadline),
+	}
+	return nil
+}
+
+// Validate проверяет, что политика исполнима: паузы выдерживаются в очередях
+// ожидания с TTL в целых секундах, поэтому base_delay не меньше секунды.
+func (p RetryPolicy) Validate() error {
+	switch {
+	case p.MaxAttempts < 1 || p.MaxAttempts > maxRetryAttempts:
+		return fmt.Errorf("max_attempts must be between 1 and %d", maxRetryAttempts)
+	case p.BaseDelay < time.Second:
+		return errors.New("base_delay must be at least 1s")
+	case p.MaxDelay < p.BaseDelay:
+		return errors.New("max_delay must not be less than base_delay")
+	case p.Jitter < 0 || p.Jitter > 1:
+		return errors.New("jitter must be between 0 and 1")
+	case p.Deadline < 0:
+		return errors.New("deadline must not be negative")
+	}
+	return nil
+}
+
+// Backoff возвращает паузу перед повтором после retries неудачных повторов:
+// BaseDelay·2^retries, не больше MaxDelay, с разбросом ±Jitter. rnd — случайное
+// число из [0, 1). Результат округляется до секунды.
+func (p RetryPolicy) Backoff(retries int, rnd float64) time.Duration {
+	if retries < 0 {
+		retries = 0
+	}
+	d := float64(p.BaseDelay) * math.Pow(2, float64(retries))
+	if d > float64(p.MaxDelay) {
+		d = float64(p.MaxDelay)
+	}
+	d *= 1 + p.Jitter*(2*rnd-1)
+
+	delay := time.Duration(d).Round(time.Second)
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if delay < time.Second {
+		delay = time.Second
+	}
+	return delay
+}
+
+// RetryPolicyReq — политика повторов в запросе; незаданные поля берутся из
+// политики по умолчанию.
+type RetryPolicyReq struct {
+	MaxAttempts *int      `json:"max_attempts"`
+	BaseDelay   *Duration `json:"base_delay"`
+	MaxDelay    *Duration `json:"max_delay"`
+	Jitter      *float64  `json:"jitter"`
+	Deadline    *Duration `json:"deadline"`
+}
+
+func (r *RetryPolicyReq) Apply(def RetryPolicy) RetryPolicy {
+	p := def
+	if r == nil {
+		return p
+	}
+	if r.MaxAttempts != nil {
+		p.MaxAttempts = *r.MaxAttempts
+	}
+	if r.BaseDelay != nil {
+		p.BaseDelay = time.Duration(*r.BaseDelay)
+	}
+	if r.MaxDelay != nil {
+		p.MaxDelay = time.Duration(*r.MaxDelay)
+	}
+	if r.Jitter != nil {
+		p.Jitter = *r.Jitter
+	}
+	if r.Deadline != nil {
+		p.Deadline = time.Duration(*r.Deadline)
+	}
+	return p
+}
+
+// Duration в JSON записывается строкой в формате time.ParseDuration ("1m30s").
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return errors.New(`duration must be a string like "30s"`)
+	}
+	if s == "" {
+		*d = 0
+		return nil
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
@@ -0,0 +1,82 @@
+package campaign
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 5, BaseDelay: time.Second, MaxDelay: 10 * time.Second, Jitter: 0.5}
+	cases := []struct {
+		retries int
+		rnd     float64
+		want    time.Duration
+	}{
+		{0, 0.5, time.Second},
+		{1, 0.5, 2 * time.Second},
+		{2, 0.5, 4 * time.Second},
+		{5, 0.5, 10 * time.Second},
+		{2, 0, 2 * time.Second},
+		{2, 0.99, 6 * time.Second},
+		{5, 0.99, 10 * time.Second},
+		{0, 0, time.Second},
+	}
+	for _, c := range cases {
+		if got := p.Backoff(c.retries, c.rnd); got != c.want {
+			t.Errorf("Backoff(%d, %v) = %s, want %s", c.retries, c.rnd, got, c.want)
+		}
+	}
+}
+
+func TestRetryPolicyReq_Apply(t *testing.T) {
+	def := RetryPolicy{MaxAttempts: 4, BaseDelay: time.Second, MaxDelay: 5 * time.Minute, Jitter: 0.1, Deadline: 24 * time.Hour}
+
+	var nilReq *RetryPolicyReq
+	if got := nilReq.Apply(def); got != def {
+		t.Fatalf("nil request must keep defaults, got %+v", got)
+	}
+
+	var req RetryPolicyReq
+	if err := json.Unmarshal([]byte(`{"max_attempts":2,"base_delay":"30s","jitter":0}`), &req); err != nil {
+		t.Fatal(err)
+	}
+	got := req.Apply(def)
+	want := RetryPolicy{MaxAttempts: 2, BaseDelay: 30 * time.Second, MaxDelay: 5 * time.Minute, Deadline: 24 * time.Hour}
+	if got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+
+	b, err := json.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"max_attempts":2,"base_delay":"30s","max_delay":"5m0s","jitter":0,"deadline":"24h0m0s"}` {
+		t.Fatalf("unexpected json: %s", b)
+	}
+	var back RetryPolicy
+	if err := json.Unmarshal(b, &back); err != nil || back != got {
+		t.Fatalf("round trip: %+v, %v", back, err)
+	}
+}
+
+func TestRetryPolicy_Validate(t *testing.T) {
+	ok := RetryPolicy{MaxAttempts: 1, BaseDelay: time.Second, MaxDelay: time.Second}
+	if err := ok.Validate(); err != nil {
+		t.Fatalf("valid policy rejected: %v", err)
+	}
+
+	bad := []RetryPolicy{
+		{MaxAttempts: 0, BaseDelay: time.Second, MaxDelay: time.Second},
+		{MaxAttempts: 101, BaseDelay: time.Second, MaxDelay: time.Second},
+		{MaxAttempts: 3, BaseDelay: 500 * time.Millisecond, MaxDelay: time.Second},
+		{MaxAttempts: 3, BaseDelay: time.Minute, MaxDelay: time.Second},
+		{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: time.Second, Jitter: 1.5},
+		{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: time.Second, Deadline: -time.Second},
+	}
+	for _, p := range bad {
+		if err := p.Validate(); err == nil {
+			t.Errorf("policy %+v must be rejected", p)
+		}
+	}
+}
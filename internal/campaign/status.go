@@ -0,0 +1,73 @@
+package campaign
+
+import (
+	"errors"
+	"math"
+	"sort"
+)
+
+const (
+	// StatusImporting — получатели ещё загружаются асинхронным импортом.
+	StatusImporting  = "importing"
+	StatusQueued     = "queued"
+	StatusProcessing = "processing"
+	StatusPaused     = "paused"
+	StatusDone       = "done"
+	StatusFailed     = "failed"
+	StatusCanceled   = "canceled"
+)
+
+var ErrInvalidTransition = errors.New("invalid campaign status transition")
+
+// transitions — единственное место, где описаны допустимые переходы статусов кампании.
+var transitions = map[string][]string{
+	StatusImporting:  {StatusQueued, StatusCanceled},
+	StatusQueued:     {StatusProcessing, StatusCanceled},
+	StatusProcessing: {StatusPaused, StatusDone, StatusFailed, StatusCanceled},
+	StatusPaused:     {StatusProcessing, StatusCanceled},
+}
+
+func CanTransition(from, to string) bool {
+	for _, s := range transitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Sources возвращает статусы, из которых допустим переход в to.
+func Sources(to string) []string {
+	var out []string
+	for from, targets := range transitions {
+		for _, s := range targets {
+			if s == to {
+				out = append(out, from)
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// IsFinal сообщает, что кампания в этом статусе больше не меняется.
+func IsFinal(status string) bool {
+	return status == StatusDone || status == StatusFailed || status == StatusCanceled
+}
+
+// Outcome выбирает итоговый статус отработавшей кампании: failed, если доля
+// неудачных сообщений превышает threshold, иначе done.
+func Outcome(total, failed int, threshold float64) string {
+	if total > 0 && float64(failed)/float64(total) > threshold {
+		return StatusFailed
+	}
+	return StatusDone
+}
+
+// Rate — доля part от whole, округлённая до 0.01%; 0 при пустом whole.
+func Rate(part, whole int) float64 {
+	if whole <= 0 {
+		return 0
+	}
+	return math.Round(float64(part)/float64(whole)*1e4) / 1e4
+}
@@ -0,0 +1,83 @@
+package campaign
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCanTransition(t *testing.T) {
+	cases := []struct {
+		from, to string
+		ok       bool
+	}{
+		{StatusQueued, StatusProcessing, true},
+		{StatusQueued, StatusCanceled, true},
+		{StatusProcessing, StatusDone, true},
+		{StatusProcessing, StatusFailed, true},
+		{StatusProcessing, StatusCanceled, true},
+		{StatusProcessing, StatusPaused, true},
+		{StatusPaused, StatusProcessing, true},
+		{StatusPaused, StatusCanceled, true},
+		{StatusImporting, StatusQueued, true},
+		{StatusImporting, StatusCanceled, true},
+		{StatusImporting, StatusProcessing, false},
+		{StatusQueued, StatusPaused, false},
+		{StatusPaused, StatusDone, false},
+		{StatusQueued, StatusDone, false},
+		{StatusDone, StatusQueued, false},
+		{StatusFailed, StatusProcessing, false},
+		{StatusCanceled, StatusProcessing, false},
+		{StatusProcessing, StatusQueued, false},
+	}
+	for _, c := range cases {
+		if got := CanTransition(c.from, c.to); got != c.ok {
+			t.Errorf("%s -> %s: want %v, got %v", c.from, c.to, c.ok, got)
+		}
+	}
+}
+
+func TestSources(t *testing.T) {
+	if got, want := Sources(StatusCanceled), []string{StatusImporting, StatusPaused, StatusProcessing, StatusQueued}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+	if got, want := Sources(StatusQueued), []string{StatusImporting}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("only a finished import may move to queued, got %v", got)
+	}
+}
+
+func TestOutcome(t *testing.T) {
+	cases := []struct {
+		total, failed int
+		threshold     float64
+		want          string
+	}{
+		{10, 0, 0.5, StatusDone},
+		{10, 5, 0.5, StatusDone},
+		{10, 6, 0.5, StatusFailed},
+		{10, 1, 0, StatusFailed},
+		{0, 0, 0, StatusDone},
+	}
+	for _, c := range cases {
+		if got := Outcome(c.total, c.failed, c.threshold); got != c.want {
+			t.Errorf("Outcome(%d,%d,%v): want %s, got %s", c.total, c.failed, c.threshold, c.want, got)
+		}
+	}
+}
+
+func TestRate(t *testing.T) {
+	cases := []struct {
+		part, whole int
+		want        float64
+	}{
+		{0, 0, 0},
+		{3, 0, 0},
+		{1, 4, 0.25},
+		{1, 3, 0.3333},
+		{2, 3, 0.6667},
+	}
+	for _, c := range cases {
+		if got := Rate(c.part, c.whole); got != c.want {
+			t.Errorf("Rate(%d,%d): want %v, got %v", c.part, c.whole, c.want, got)
+		}
+	}
+}
@@ -1,16 +1,65 @@
 package campaign
 
-import "time"
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+)
 
 type CreateCampaignResp struct {
-	ID int64 `json:"id"`
+	ID     int64  `json:"id"`
+	Status string `json:"status"`
+	// Recipients — итог проверки recipients; нет для кампании с import.
+	Recipients *RecipientsReport `json:"recipients,omitempty"`
+}
+
+type CampaignStatusResp struct {
+	ID     int64  `json:"id"`
+	Status string `json:"status"`
 }
 
 type CreateCampaignReq struct {
-	Name        string    `json:"name"        binding:"required"`
-	Body        string    `json:"body"        binding:"required"`
-	ScheduledAt time.Time `json:"scheduled_at" binding:"required"`
-	Recipients  []string  `json:"recipients"  binding:"required,min=1,dive,required"`
+	Name        string      `json:"name"        binding:"required"`
+	Body        string      `json:"body"        binding:"required"`
+	ScheduledAt time.Time   `json:"scheduled_at" binding:"required"`
+	Recipients  []Recipient `json:"recipients"`
+	// Import — получатели будут загружены отдельным запросом
+	// POST /campaigns/{id}/imports, а не переданы в recipients.
+	Import bool `json:"import"`
+	// Format — формат body: text (по умолчанию) или html. По содержимому
+	// формат не угадывается: от него зависят экранирование подстановок
+	// и Content-Type письма.
+	Format string `json:"format"`
+
+	// TextBody — текстовая альтернатива для HTML-тела.
+	TextBody    string            `json:"text_body"`
+	Subject     string            `json:"subject"`
+	FromName    string            `json:"from_name"`
+	FromAddress string            `json:"from_address"`
+	ReplyTo     string            `json:"reply_to"`
+	Headers     map[string]string `json:"headers"`
+
+	RetryPolicy *RetryPolicyReq `json:"retry_policy"`
+	// TrackOpens — считать открытия HTML-писем по пикселю.
+	TrackOpens bool `json:"track_opens"`
+}
+
+// Recipient принимается либо строкой с адресом, либо объектом
+// {"address": "...", "name": "...", "vars": {...}} с отображаемым именем
+// и переменными для шаблона.
+type Recipient struct {
+	Address string         `json:"address"`
+	Name    string         `json:"name,omitempty"`
+	Vars    map[string]any `json:"vars,omitempty"`
+}
+
+func (r *Recipient) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte(`"`)) {
+		*r = Recipient{}
+		return json.Unmarshal(data, &r.Address)
+	}
+	type plain Recipient
+	return json.Unmarshal(data, (*plain)(r))
 }
 
 type JobMessage struct {
@@ -29,29 +78,206 @@ type Campaign struct {
 }
 
 type CampaignListItem struct {
-	ID          int64     `json:"id"`
-	Name        string    `json:"name"`
-	ScheduledAt time.Time `json:"scheduled_at"`
-	Status      string    `json:"status"`
-	CreatedAt   time.Time `json:"created_at"`
+	ID          int64      `json:"id"`
+	Name        string     `json:"name"`
+	ScheduledAt time.Time  `json:"scheduled_at"`
+	Status      string     `json:"status"`
+	CreatedAt   time.Time  `json:"created_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
 	Stats       struct {
-		Total   int `json:"total"`
-		Pending int `json:"pending"`
-		Sent    int `json:"sent"`
-		Failed  int `json:"failed"`
+		Total          int          `json:"total"`
+		Pending        int          `json:"pending"`
+		Sent           int          `json:"sent"`
+		Failed         int          `json:"failed"`
+		Suppressed     int          `json:"suppressed"`
+		Bounced        int          `json:"bounced"`
+		Canceled       int          `json:"canceled"`
+		SoftBounces    int          `json:"soft_bounces"`
+		Complaints     int          `json:"complaints"`
+		ComplaintRate  float64      `json:"complaint_rate"`
+		Opens          int          `json:"opens"`
+		UniqueOpens    int          `json:"unique_opens"`
+		UniqueOpenRate float64      `json:"unique_open_rate"`
+		Failures       FailureStats `json:"failures"`
 	} `json:"stats"`
 }
 type CampaignDetails struct {
-	ID          int64     `json:"id"`
-	Name        string    `json:"name"`
-	Body        string    `json:"body"`
-	ScheduledAt time.Time `json:"scheduled_at"`
-	Status      string    `json:"status"`
-	CreatedAt   time.Time `json:"created_at"`
+	ID          int64             `json:"id"`
+	Name        string            `json:"name"`
+	Body        string            `json:"body"`
+	Format      string            `json:"format"`
+	TextBody    string            `json:"text_body,omitempty"`
+	Subject     string            `json:"subject"`
+	FromName    string            `json:"from_name,omitempty"`
+	FromAddress string            `json:"from_address,omitempty"`
+	ReplyTo     string            `json:"reply_to,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	RetryPolicy RetryPolicy       `json:"retry_policy"`
+	TrackOpens  bool              `json:"track_opens"`
+	ScheduledAt time.Time         `json:"scheduled_at"`
+	Status      string            `json:"status"`
+	CreatedAt   time.Time         `json:"created_at"`
+	FinishedAt  *time.Time        `json:"finished_at,omitempty"`
 	Stats       struct {
-		Total   int `json:"total"`
-		Pending int `json:"pending"`
-		Sent    int `json:"sent"`
-		Failed  int `json:"failed"`
+		Total          int          `json:"total"`
+		Pending        int          `json:"pending"`
+		Sent           int          `json:"sent"`
+		Failed         int          `json:"failed"`
+		Suppressed     int          `json:"suppressed"`
+		Bounced        int          `json:"bounced"`
+		Canceled       int          `json:"canceled"`
+		SoftBounces    int          `json:"soft_bounces"`
+		Complaints     int          `json:"complaints"`
+		ComplaintRate  float64      `json:"complaint_rate"`
+		Opens          int          `json:"opens"`
+		UniqueOpens    int          `json:"unique_opens"`
+		UniqueOpenRate float64      `json:"unique_open_rate"`
+		Failures       FailureStats `json:"failures"`
 	} `json:"stats"`
 }
+
+// MessageAttempt — одна попытка отправки сообщения.
+type MessageAttempt struct {
+	Attempt      int       `json:"attempt"`
+	StartedAt    time.Time `json:"started_at"`
+	DurationMS   int64     `json:"duration_ms"`
+	Transport    string    `json:"transport"`
+	Provider     string    `json:"provider,omitempty"`
+	ResponseCode int       `json:"response_code,omitempty"`
+	ResponseText string    `json:"response_text,omitempty"`
+	ErrorClass   string    `json:"error_class,omitempty"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// FailureStats раскладывает failed-сообщения по классам ошибок доставки.
+type FailureStats struct {
+	Permanent     int `json:"permanent"`
+	Temporary     int `json:"temporary"`
+	RateLimited   int `json:"rate_limited"`
+	PolicyBlocked int `json:"policy_blocked"`
+}
+
+const (
+	ImportPending   = "pending"
+	ImportRunning   = "running"
+	ImportCompleted = "completed"
+	ImportFailed    = "failed"
+)
+
+// Форматы тела загрузки получателей.
+const (
+	ImportFormatJSON   = "json"
+	ImportFormatNDJSON = "ndjson"
+	ImportFormatCSV    = "csv"
+)
+
+// ImportMapping говорит, какие колонки CSV (или поля плоских объектов
+// JSON/NDJSON) становятся адресом, именем и переменными получателя.
+// Vars сопоставляет имя переменной шаблона с колонкой; если Vars не задан,
+// переменными становятся все колонки, кроме адреса и имени.
+type ImportMapping struct {
+	Address string            `json:"address"`
+	Name    string            `json:"name"`
+	Vars    map[string]string `json:"vars"`
+}
+
+type CreateImportResp struct {
+	ID         int64  `json:"id"`
+	CampaignID int64  `json:"campaign_id"`
+	Status     string `json:"status"`
+}
+
+// Import — состояние асинхронной загрузки получателей кампании.
+type Import struct {
+	ID         int64            `json:"id"`
+	CampaignID int64            `json:"campaign_id"`
+	Format     string           `json:"format"`
+	Status     string           `json:"status"`
+	Processed  int              `json:"processed"`
+	Accepted   int              `json:"accepted"`
+	Rejected   int              `json:"rejected"`
+	Duplicates int              `json:"duplicates"`
+	Errors     []ImportRowError `json:"errors"`
+	Error      string           `json:"error,omitempty"`
+	CreatedAt  time.Time        `json:"created_at"`
+	UpdatedAt  time.Time        `json:"updated_at"`
+	FinishedAt *time.Time       `json:"finished_at,omitempty"`
+}
+
+// ImportRowError — отклонённая или повторная строка загрузки; Row
+// считается с единицы.
+type ImportRowError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+// Причины блокировки адреса.
+const (
+	SuppressionHardBounce  = "hard_bounce"
+	SuppressionComplaint   = "complaint"
+	SuppressionUnsubscribe = "unsubscribe"
+	SuppressionManual      = "manual"
+)
+
+// IsSuppressionReason сообщает, что reason — известная причина блокировки.
+func IsSuppressionReason(reason string) bool {
+	switch reason {
+	case SuppressionHardBounce, SuppressionComplaint, SuppressionUnsubscribe, SuppressionManual:
+		return true
+	}
+	return false
+}
+
+// Suppression — адрес, на который не отправляется ни одна кампания, пока
+// запись действует (expires_at не наступил или не задан).
+type Suppression struct {
+	Address   string     `json:"address"`
+	Reason    string     `json:"reason"`
+	Source    string     `json:"source,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+type CreateSuppressionReq struct {
+	Address   string     `json:"address"    binding:"required"`
+	Reason    string     `json:"reason"`
+	Source    string     `json:"source"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// SuppressionImportResp — итог загрузки списка блокировок.
+type SuppressionImportResp struct {
+	Processed int              `json:"processed"`
+	Accepted  int              `json:"accepted"`
+	Rejected  int              `json:"rejected"`
+	Errors    []ImportRowError `json:"errors"`
+}
+
+// Типы событий сообщения после отправки.
+const (
+	EventHardBounce = "hard_bounce"
+	EventSoftBounce = "soft_bounce"
+	EventComplained = "complained"
+)
+
+// ComplaintResp — жалоба получателя, записанная по ARF-отчёту; Address —
+// заблокированный адрес.
+type ComplaintResp struct {
+	CampaignID   int64  `json:"campaign_id"`
+	RecipientID  int64  `json:"recipient_id"`
+	FeedbackType string `json:"feedback_type"`
+	Address      string `json:"address"`
+}
+
+// BounceResp — что сделано по уведомлению о недоставке.
+type BounceResp struct {
+	CampaignID  int64  `json:"campaign_id"`
+	RecipientID int64  `json:"recipient_id"`
+	Type        string `json:"type"`
+	Status      string `json:"status,omitempty"`
+	// HardBounces — сколько постоянных отказов у адреса по всем кампаниям.
+	HardBounces int  `json:"hard_bounces"`
+	Suppressed  bool `json:"suppressed"`
+	// Duplicate — этот отчёт уже был учтён раньше.
+	Duplicate bool `json:"duplicate,omitempty"`
+}
@@ -0,0 +1,74 @@
+package feedback
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"strings"
+)
+
+var ErrNotARF = errors.New("feedback: not an abuse feedback report")
+
+// ARF — жалоба получателя, пересланная почтовой системой по feedback loop.
+type ARF struct {
+	// FeedbackType — abuse, fraud, virus, not-spam, other и т. п.
+	FeedbackType     string
+	UserAgent        string
+	SourceIP         string
+	ArrivalDate      string
+	OriginalMailFrom string
+	// OriginalRcptTo — адреса получателей; провайдеры часто их вырезают.
+	OriginalRcptTo []string
+	// OriginalMessageID и OriginalReturnPath — из заголовков исходного
+	// письма, если отчёт их вернул.
+	OriginalMessageID  string
+	OriginalReturnPath string
+}
+
+// Complaint сообщает, что получатель пожаловался на письмо как на спам
+// или мошенничество; прочие виды отчётов адрес не блокируют.
+func (a *ARF) Complaint() bool {
+	return a.FeedbackType == "abuse" || a.FeedbackType == "fraud"
+}
+
+// ParseARF разбирает письмо multipart/report; report-type=feedback-report.
+func ParseARF(r io.Reader) (*ARF, error) {
+	a := &ARF{}
+	_, err := readReport(r, "feedback-report", ErrNotARF, func(ct string, p *multipart.Part) error {
+		switch ct {
+		case "message/feedback-report":
+			h, err := textproto.NewReader(bufio.NewReader(partBody(p))).ReadMIMEHeader()
+			if err != nil && err != io.EOF {
+				return fmt.Errorf("%w: %v", ErrNotARF, err)
+			}
+			a.FeedbackType = strings.ToLower(strings.TrimSpace(h.Get("Feedback-Type")))
+			a.UserAgent = strings.TrimSpace(h.Get("User-Agent"))
+			a.SourceIP = strings.TrimSpace(h.Get("Source-Ip"))
+			a.ArrivalDate = strings.TrimSpace(h.Get("Arrival-Date"))
+			a.OriginalMailFrom = angleAddr(h.Get("Original-Mail-From"))
+			for _, v := range h.Values("Original-Rcpt-To") {
+				a.OriginalRcptTo = append(a.OriginalRcptTo, angleAddr(v))
+			}
+		case "message/rfc822", "text/rfc822-headers", "message/global", "message/global-headers":
+			h := originalHeaders(p)
+			a.OriginalMessageID = messageID(h)
+			a.OriginalReturnPath = angleAddr(h.Get("Return-Path"))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if a.FeedbackType == "" {
+		return nil, fmt.Errorf("%w: no Feedback-Type", ErrNotARF)
+	}
+	return a, nil
+}
+
+// angleAddr снимает угловые скобки с адреса вида <user@example.com>.
+func angleAddr(v string) string {
+	return strings.Trim(strings.TrimSpace(v), "<>")
+}
@@ -0,0 +1,58 @@
+package feedback
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseARF(t *testing.T) {
+	f, err := os.Open(filepath.Join("testdata", "abuse.eml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	a, err := ParseARF(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &ARF{
+		FeedbackType:       "abuse",
+		UserAgent:          "Yahoo!-Mail-Feedback/2.0",
+		SourceIP:           "192.0.2.10",
+		ArrivalDate:        "Thu, 8 Mar 2025 14:00:00 +0000",
+		OriginalMailFrom:   "bounces+abc@mail.example.com",
+		OriginalRcptTo:     []string{"redacted@yahoo.com"},
+		OriginalMessageID:  "0011aabb@example.com",
+		OriginalReturnPath: "bounces+abc@mail.example.com",
+	}
+	if !reflect.DeepEqual(a, want) {
+		t.Fatalf("got  %+v\nwant %+v", a, want)
+	}
+	if !a.Complaint() {
+		t.Fatal("abuse report must be a complaint")
+	}
+}
+
+func TestARF_Complaint(t *testing.T) {
+	for typ, want := range map[string]bool{"abuse": true, "fraud": true, "not-spam": false, "virus": false, "other": false} {
+		if got := (&ARF{FeedbackType: typ}).Complaint(); got != want {
+			t.Errorf("%s: want %v, got %v", typ, want, got)
+		}
+	}
+}
+
+func TestParseARF_NotReport(t *testing.T) {
+	for name, raw := range map[string]string{
+		"dsn":     "Content-Type: multipart/report; report-type=delivery-status; boundary=x\r\n\r\n--x--\r\n",
+		"no type": "Content-Type: multipart/report; report-type=feedback-report; boundary=x\r\n\r\n--x\r\nContent-Type: message/feedback-report\r\n\r\nVersion: 1\r\n\r\n--x--\r\n",
+		"garbage": "not a message",
+	} {
+		if _, err := ParseARF(strings.NewReader(raw)); !errors.Is(err, ErrNotARF) {
+			t.Errorf("%s: want ErrNotARF, got %v", name, err)
+		}
+	}
+}
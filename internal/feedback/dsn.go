@@ -0,0 +1,151 @@
+// Package feedback разбирает отчёты, которые почтовые системы присылают
+// после отправки: уведомления о недоставке (DSN, RFC 3464) и жалобы
+// получателей (ARF, RFC 5965).
+package feedback
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"strings"
+)
+
+// Виды недоставки.
+const (
+	BounceHard = "hard"
+	BounceSoft = "soft"
+)
+
+var ErrNotDSN = errors.New("feedback: not a delivery status notification")
+
+// DSN — уведомление о недоставке.
+type DSN struct {
+	// To — адреса, которым пришло уведомление (To, Delivered-To,
+	// X-Original-To): адрес возврата исходного письма.
+	To []string
+	// OriginalMessageID — Message-ID исходного письма без угловых скобок,
+	// если уведомление вернуло его заголовки.
+	OriginalMessageID string
+	// MessageID — Message-ID самого уведомления без угловых скобок.
+	MessageID string
+	// EnvelopeID — Original-Envelope-Id из per-message полей отчёта.
+	EnvelopeID string
+	Recipients []DSNRecipient
+}
+
+// DSNRecipient — per-recipient поля отчёта.
+type DSNRecipient struct {
+	FinalRecipient string
+	Action         string
+	// Status — код RFC 3463 вида 5.1.1.
+	Status         string
+	DiagnosticCode string
+	RemoteMTA      string
+}
+
+// Bounce классифицирует отказ: BounceHard — адрес не примет письмо и
+// впредь, BounceSoft — временная причина, "" — это не отказ (delivered,
+// relayed, expanded).
+func (r DSNRecipient) Bounce() string {
+	switch r.Action {
+	case "failed":
+		// 5.2.2 — переполненный ящик, освободится сам
+		if strings.HasPrefix(r.Status, "5.") && !strings.HasPrefix(r.Status, "5.2.2") {
+			return BounceHard
+		}
+		return BounceSoft
+	case "delayed":
+		return BounceSoft
+	}
+	return ""
+}
+
+// Failure — первый получатель отчёта с отказом.
+func (d *DSN) Failure() (DSNRecipient, bool) {
+	for _, r := range d.Recipients {
+		if r.Bounce() != "" {
+			return r, true
+		}
+	}
+	return DSNRecipient{}, false
+}
+
+// ReportID — ключ, по которому повторно доставленное уведомление узнаётся
+// как уже учтённое: его Message-ID, а без него — Original-Envelope-Id вместе
+// с действием и кодом отказа, чтобы отложенная доставка и последовавший
+// отказ того же письма остались разными событиями. Пустой, если в отчёте
+// нет ни того, ни другого.
+func (d *DSN) ReportID() string {
+	if d.MessageID != "" {
+		return d.MessageID
+	}
+	if d.EnvelopeID == "" {
+		return ""
+	}
+	r, _ := d.Failure()
+	return d.EnvelopeID + ";" + r.Action + ";" + r.Status
+}
+
+// ParseDSN разбирает письмо multipart/report; report-type=delivery-status.
+func ParseDSN(r io.Reader) (*DSN, error) {
+	d := &DSN{}
+	found := false
+	h, err := readReport(r, "delivery-status", ErrNotDSN, func(ct string, p *multipart.Part) error {
+		switch ct {
+		case "message/delivery-status", "message/global-delivery-status":
+			var err error
+			if d.EnvelopeID, d.Recipients, err = parseDeliveryStatus(partBody(p)); err != nil {
+				return err
+			}
+			found = true
+		case "text/rfc822-headers", "message/rfc822", "message/global-headers":
+			d.OriginalMessageID = messageID(originalHeaders(p))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrNotDSN
+	}
+	d.To = envelopeAddresses(h)
+	d.MessageID = messageID(textproto.MIMEHeader(h))
+	return d, nil
+}
+
+// parseDeliveryStatus читает блоки полей: первый — per-message (из него
+// берётся Original-Envelope-Id), остальные — по одному на получателя.
+func parseDeliveryStatus(r io.Reader) (string, []DSNRecipient, error) {
+	tr := textproto.NewReader(bufio.NewReader(r))
+	msg, err := tr.ReadMIMEHeader()
+	if err != nil && err != io.EOF {
+		return "", nil, fmt.Errorf("%w: %v", ErrNotDSN, err)
+	}
+	var out []DSNRecipient
+	for {
+		h, err := tr.ReadMIMEHeader()
+		if len(h) > 0 {
+			out = append(out, DSNRecipient{
+				FinalRecipient: typedValue(h.Get("Final-Recipient")),
+				Action:         strings.ToLower(strings.TrimSpace(h.Get("Action"))),
+				Status:         firstField(h.Get("Status")),
+				DiagnosticCode: typedValue(h.Get("Diagnostic-Code")),
+				RemoteMTA:      typedValue(h.Get("Remote-MTA")),
+			})
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", nil, fmt.Errorf("%w: %v", ErrNotDSN, err)
+		}
+	}
+	if len(out) == 0 {
+		return "", nil, fmt.Errorf("%w: no recipient fields", ErrNotDSN)
+	}
+	return strings.TrimSpace(msg.Get("Original-Envelope-Id")), out, nil
+}
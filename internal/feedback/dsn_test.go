@@ -0,0 +1,110 @@
+package feedback
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func parseFile(t *testing.T, name string) *DSN {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	d, err := ParseDSN(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestParseDSN_Hard(t *testing.T) {
+	d := parseFile(t, "hard.eml")
+	if len(d.To) != 2 || d.To[0] != "bounces+abc@mail.example.com" {
+		t.Fatalf("unexpected To: %v", d.To)
+	}
+	if d.OriginalMessageID != "0011aabb@example.com" {
+		t.Fatalf("unexpected original Message-ID %q", d.OriginalMessageID)
+	}
+	if d.MessageID != "20251002120001.4F2A1@mx.example.net" || d.EnvelopeID != "7-101" {
+		t.Fatalf("unexpected report ids: %q %q", d.MessageID, d.EnvelopeID)
+	}
+	r, ok := d.Failure()
+	if !ok {
+		t.Fatal("want a failure")
+	}
+	want := DSNRecipient{
+		FinalRecipient: "ann@example.org",
+		Action:         "failed",
+		Status:         "5.1.1",
+		DiagnosticCode: "550 5.1.1 <ann@example.org>: Recipient address rejected: User unknown",
+		RemoteMTA:      "mx.example.org",
+	}
+	if r != want {
+		t.Fatalf("got  %+v\nwant %+v", r, want)
+	}
+	if r.Bounce() != BounceHard {
+		t.Fatalf("want hard bounce, got %q", r.Bounce())
+	}
+}
+
+func TestParseDSN_DelayedBase64(t *testing.T) {
+	d := parseFile(t, "delayed.eml")
+	r, ok := d.Failure()
+	if !ok || r.FinalRecipient != "bob@example.org" || r.Status != "4.2.2" || r.Bounce() != BounceSoft {
+		t.Fatalf("unexpected recipient: %+v", r)
+	}
+}
+
+func TestDSN_ReportID(t *testing.T) {
+	failed := []DSNRecipient{{Action: "failed", Status: "5.1.1"}}
+	cases := []struct {
+		d    DSN
+		want string
+	}{
+		{DSN{MessageID: "r1@mx", EnvelopeID: "7-101", Recipients: failed}, "r1@mx"},
+		{DSN{EnvelopeID: "7-101", Recipients: failed}, "7-101;failed;5.1.1"},
+		{DSN{Recipients: failed}, ""},
+	}
+	for _, c := range cases {
+		if got := c.d.ReportID(); got != c.want {
+			t.Errorf("ReportID(%+v) = %q, want %q", c.d, got, c.want)
+		}
+	}
+}
+
+func TestDSNRecipient_Bounce(t *testing.T) {
+	cases := []struct {
+		action, status, want string
+	}{
+		{"failed", "5.1.1", BounceHard},
+		{"failed", "5.7.1", BounceHard},
+		{"failed", "5.2.2", BounceSoft},
+		{"failed", "4.4.7", BounceSoft},
+		{"delayed", "4.4.1", BounceSoft},
+		{"delivered", "2.0.0", ""},
+		{"relayed", "2.0.0", ""},
+	}
+	for _, c := range cases {
+		if got := (DSNRecipient{Action: c.action, Status: c.status}).Bounce(); got != c.want {
+			t.Errorf("%s %s: want %q, got %q", c.action, c.status, c.want, got)
+		}
+	}
+}
+
+func TestParseDSN_NotReport(t *testing.T) {
+	for name, raw := range map[string]string{
+		"plain":    "Subject: hi\r\nContent-Type: text/plain\r\n\r\nhello\r\n",
+		"arf":      "Content-Type: multipart/report; report-type=feedback-report; boundary=x\r\n\r\n--x--\r\n",
+		"no parts": "Content-Type: multipart/report; report-type=delivery-status; boundary=x\r\n\r\n--x\r\nContent-Type: text/plain\r\n\r\nsorry\r\n--x--\r\n",
+		"garbage":  "not a message",
+	} {
+		if _, err := ParseDSN(strings.NewReader(raw)); !errors.Is(err, ErrNotDSN) {
+			t.Errorf("%s: want ErrNotDSN, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package feedback
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"strings"
+)
+
+// readReport открывает письмо multipart/report с заданным report-type и
+// передаёт fn каждую часть с её media type; notReport — ошибка, которой
+// помечается письмо не того вида. Возвращает заголовки самого отчёта.
+func readReport(r io.Reader, reportType string, notReport error, fn func(ct string, p *multipart.Part) error) (mail.Header, error) {
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", notReport, err)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/report" || !strings.EqualFold(params["report-type"], reportType) {
+		return nil, notReport
+	}
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			return msg.Header, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", notReport, err)
+		}
+		ct, _, _ := mime.ParseMediaType(p.Header.Get("Content-Type"))
+		if err := fn(ct, p); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// originalHeaders читает заголовки исходного письма из части отчёта; они
+// могут быть обрезаны — берём, что успели прочитать.
+func originalHeaders(p *multipart.Part) textproto.MIMEHeader {
+	h, _ := textproto.NewReader(bufio.NewReader(partBody(p))).ReadMIMEHeader()
+	return h
+}
+
+func messageID(h textproto.MIMEHeader) string {
+	return strings.Trim(strings.TrimSpace(h.Get("Message-Id")), "<>")
+}
+
+// partBody снимает base64; quoted-printable multipart.Reader снимает сам.
+func partBody(p *multipart.Part) io.Reader {
+	if strings.EqualFold(p.Header.Get("Content-Transfer-Encoding"), "base64") {
+		return base64.NewDecoder(base64.StdEncoding, p)
+	}
+	return p
+}
+
+// typedValue убирает тип из значений вида "rfc822; user@example.com".
+func typedValue(v string) string {
+	if i := strings.IndexByte(v, ';'); i >= 0 {
+		v = v[i+1:]
+	}
+	return strings.TrimSpace(v)
+}
+
+func firstField(v string) string {
+	if f := strings.Fields(v); len(f) > 0 {
+		return f[0]
+	}
+	return ""
+}
+
+func envelopeAddresses(h mail.Header) []string {
+	var out []string
+	for _, name := range []string{"Delivered-To", "X-Original-To", "To"} {
+		for _, v := range h[name] {
+			list, err := mail.ParseAddressList(v)
+			if err != nil {
+				continue
+			}
+			for _, a := range list {
+				out = append(out, a.Address)
+			}
+		}
+	}
+	return out
+}
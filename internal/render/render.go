@@ -0,0 +1,99 @@
+// Package render подставляет переменные получателя в тело кампании.
+//
+// Шаблоны — это Go templates: {{.first_name}}, {{if .plan}}…{{end}},
+// {{range .items}}…{{end}}. Обращение к отсутствующей переменной через
+// точку — ошибка рендера; необязательные значения берутся через index:
+// {{index . "first_name" | default "друг"}}. HTML-тела рендерятся через
+// html/template, поэтому значения экранируются с учётом контекста.
+package render
+
+import (
+	"errors"
+	htmltemplate "html/template"
+	"io"
+	"reflect"
+	"strings"
+	texttemplate "text/template"
+)
+
+const (
+	FormatText = "text"
+	FormatHTML = "html"
+)
+
+var ErrUnknownFormat = errors.New("render: unknown body format")
+
+type executor interface {
+	Execute(w io.Writer, data any) error
+}
+
+type Template struct {
+	exec executor
+}
+
+func Parse(body, format string) (*Template, error) {
+	switch format {
+	case FormatHTML:
+		t, err := htmltemplate.New("body").Option("missingkey=error").Funcs(htmltemplate.FuncMap(funcs)).Parse(body)
+		if err != nil {
+			return nil, err
+		}
+		return &Template{exec: t}, nil
+	case FormatText:
+		t, err := texttemplate.New("body").Option("missingkey=error").Funcs(funcs).Parse(body)
+		if err != nil {
+			return nil, err
+		}
+		return &Template{exec: t}, nil
+	default:
+		return nil, ErrUnknownFormat
+	}
+}
+
+func (t *Template) Execute(vars map[string]any) (string, error) {
+	var b strings.Builder
+	if err := t.exec.Execute(&b, vars); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// Render разбирает и исполняет шаблон за один вызов.
+func Render(body, format string, vars map[string]any) (string, error) {
+	t, err := Parse(body, format)
+	if err != nil {
+		return "", err
+	}
+	return t.Execute(vars)
+}
+
+var funcs = texttemplate.FuncMap{
+	"default": defaultValue,
+	"upper":   strings.ToUpper,
+	"lower":   strings.ToLower,
+	"trim":    strings.TrimSpace,
+}
+
+// defaultValue возвращает def, если v пустое или отсутствует.
+func defaultValue(def, v any) any {
+	if isEmpty(v) {
+		return def
+	}
+	return v
+}
+
+func isEmpty(v any) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return rv.Len() == 0
+	case reflect.Bool:
+		return !rv.Bool()
+	case reflect.Pointer, reflect.Interface:
+		return rv.IsNil()
+	}
+	return false
+}
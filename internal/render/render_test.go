@@ -0,0 +1,50 @@
+package render
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender_Text(t *testing.T) {
+	body := `Привет, {{index . "first_name" | default "друг"}}!` +
+		`{{if eq .plan "pro"}} Спасибо за Pro.{{end}}` +
+		`{{range .items}} [{{.}}]{{end}}`
+	vars := map[string]any{"plan": "pro", "items": []any{"a", "b"}}
+
+	got, err := Render(body, FormatText, vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Привет, друг! Спасибо за Pro. [a] [b]"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestRender_HTMLEscapes(t *testing.T) {
+	got, err := Render(`<p>Hi {{.name}}</p>`, FormatHTML, map[string]any{"name": `<script>x</script>`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(got, "<script>") {
+		t.Fatalf("value must be escaped: %q", got)
+	}
+}
+
+func TestRender_MissingVariable(t *testing.T) {
+	_, err := Render(`Hi {{.first_name}}`, FormatText, map[string]any{})
+	if err == nil || !strings.Contains(err.Error(), "first_name") {
+		t.Fatalf("want error naming the variable, got %v", err)
+	}
+}
+
+func TestParse_SyntaxError(t *testing.T) {
+	if _, err := Parse(`Hi {{if .x}}`, FormatText); err == nil {
+		t.Fatal("want syntax error")
+	}
+}
+
+func TestParse_UnknownFormat(t *testing.T) {
+	if _, err := Parse(`Hi`, "markdown"); err != ErrUnknownFormat {
+		t.Fatalf("want ErrUnknownFormat, got %v", err)
+	}
+}
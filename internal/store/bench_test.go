@@ -0,0 +1,75 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Mutter0815/MassMailer/internal/campaign"
+	"github.com/Mutter0815/MassMailer/pkg/db"
+)
+
+var errRollback = errors.New("rollback")
+
+// BenchmarkInsertRecipients_100k пишет кампанию на 100 тысяч получателей
+// в настоящий Postgres с применёнными миграциями и откатывает транзакцию.
+// Запуск: MASSMAILER_BENCH_DSN=postgres://... go test -run=^$ -bench=InsertRecipients ./internal/store
+func BenchmarkInsertRecipients_100k(b *testing.B) {
+	dsn := os.Getenv("MASSMAILER_BENCH_DSN")
+	if dsn == "" {
+		b.Skip("MASSMAILER_BENCH_DSN is not set")
+	}
+	conn, err := db.Open(dsn)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	s := New(conn)
+
+	rs := make([]NewRecipient, 100_000)
+	for i := range rs {
+		rs[i] = NewRecipient{
+			Address: fmt.Sprintf("user%d@example.com", i),
+			Vars:    map[string]any{"first_name": fmt.Sprintf("U%d", i)},
+		}
+	}
+	// тот же бюджет, что у POST /campaigns
+	const budget = 10 * time.Second
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), budget)
+		start := time.Now()
+		err := s.WithTx(ctx, func(tx *sql.Tx) error {
+			id, err := s.InsertCampaign(ctx, tx, NewCampaign{
+				Name:        "bench",
+				Body:        "Hi {{.first_name}}",
+				ScheduledAt: time.Now(),
+				Retry:       campaign.RetryPolicy{MaxAttempts: 1, BaseDelay: time.Second, MaxDelay: time.Second},
+			})
+			if err != nil {
+				return err
+			}
+			n, _, err := s.InsertRecipients(ctx, tx, id, rs)
+			if err != nil {
+				return err
+			}
+			if n != len(rs) {
+				return fmt.Errorf("inserted %d of %d", n, len(rs))
+			}
+			return errRollback
+		})
+		elapsed := time.Since(start)
+		cancel()
+		if !errors.Is(err, errRollback) {
+			b.Fatal(err)
+		}
+		if elapsed > budget {
+			b.Fatalf("insert took %s, budget is %s", elapsed, budget)
+		}
+	}
+}
@@ -4,32 +4,105 @@ import (
 	"context"
 	"database/sql"
 	"database/sql/driver"
+	"encoding/json"
+	"errors"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/Mutter0815/MassMailer/internal/campaign"
 	"github.com/Mutter0815/MassMailer/pkg/logx"
 )
 
 type Store struct {
 	DB *sql.DB
 }
+
+// ErrClaimLost — воркер больше не держит сообщение: заявка истекла и его
+// забрал другой воркер, либо сообщение отменили вместе с кампанией.
+var ErrClaimLost = errors.New("store: message claim lost")
+
+// Querier — общее подмножество *sql.DB и *sql.Tx.
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
 type CampaignRow struct {
 	ID          int64
 	Name        string
 	Body        string
+	Format      string
+	TextBody    string
 	ScheduledAt time.Time
 	Status      string
 	CreatedAt   time.Time
+	FinishedAt  *time.Time
+	Retry       campaign.RetryPolicy
+	TrackOpens  bool
+	Envelope
+}
+
+// Envelope — заголовки письма, заданные кампанией.
+type Envelope struct {
+	Subject     string
+	FromName    string
+	FromAddress string
+	ReplyTo     string
+	Headers     map[string]string
+}
+
+type NewCampaign struct {
+	Name string
+	Body string
+	// Format — формат тела: text или html.
+	Format      string
+	TextBody    string
+	ScheduledAt time.Time
+	// Status — начальный статус; пустой означает queued.
+	Status string
+	Retry  campaign.RetryPolicy
+	// TrackOpens — вставлять в HTML-письма пиксель открытия.
+	TrackOpens bool
+	Envelope
+}
+
+// DrainedCampaign — кампания в processing, у которой не осталось сообщений в работе.
+type DrainedCampaign struct {
+	ID     int64
+	Total  int
+	Failed int
+}
+
+type OutboxRow struct {
+	ID      int64
+	Payload []byte
 }
 
 type CampaignStats struct {
-	Total   int
-	Pending int
-	Sent    int
-	Failed  int
+	Total      int
+	Pending    int
+	Sent       int
+	Failed     int
+	Suppressed int
+	Bounced    int
+	Canceled   int
+	// SoftBounces — число временных отказов, а не сообщений: у одного
+	// сообщения их может быть несколько.
+	SoftBounces int
+	// Complaints — число сообщений, на которые пожаловались получатели.
+	Complaints int
+	// Opens — все открытия по пикселю, Opened — открытые сообщения.
+	Opens    int
+	Opened   int
+	Failures campaign.FailureStats
 }
 
+// Delivered — сколько сообщений дошло до получателя: bounced тоже сначала
+// было sent, а жалобы и открытия на него уже могли прийти.
+func (st CampaignStats) Delivered() int { return st.Sent + st.Bounced }
+
 func New(db *sql.DB) *Store { return &Store{DB: db} }
 
 func (s *Store) WithTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
@@ -50,66 +123,934 @@ func (s *Store) WithTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
 	return tx.Commit()
 }
 
-func (s *Store) InsertCampaign(ctx context.Context, tx *sql.Tx, name, body string, scheduledAt any) (int64, error) {
+func (s *Store) InsertCampaign(ctx context.Context, tx *sql.Tx, c NewCampaign) (int64, error) {
+	headers, err := jsonObject(c.Headers)
+	if err != nil {
+		return 0, err
+	}
+	retry, err := json.Marshal(c.Retry)
+	if err != nil {
+		return 0, err
+	}
+
+	status := c.Status
+	if status == "" {
+		status = campaign.StatusQueued
+	}
+
 	var id int64
-	err := tx.QueryRowContext(ctx, `
-	INSERT INTO campaigns (name,body,scheduled_at,status)
-	VALUES ($1,$2,$3,'queued') RETURNING id`, name, body, scheduledAt).Scan(&id)
+	err = tx.QueryRowContext(ctx, `
+	INSERT INTO campaigns (name,body,scheduled_at,status,subject,from_name,from_address,reply_to,headers,text_body,retry_policy,track_opens,body_format)
+	VALUES ($1,$2,$3,$11,$4,$5,$6,$7,$8,$9,$10,$12,$13) RETURNING id`,
+		c.Name, c.Body, c.ScheduledAt, c.Subject, c.FromName, c.FromAddress, c.ReplyTo, headers, c.TextBody,
+		string(retry), status, c.TrackOpens, c.Format).Scan(&id)
 	return id, err
 }
 
-func (s *Store) InsertRecipient(ctx context.Context, tx *sql.Tx, campaignID int64, address string) (int64, error) {
-	var id int64
-	err := tx.QueryRowContext(ctx, `
-		INSERT INTO recipients (campaign_id, address)
-		VALUES ($1,$2) RETURNING id
-	`, campaignID, address).Scan(&id)
-	return id, err
+// NewRecipient — получатель, добавляемый в кампанию.
+type NewRecipient struct {
+	Address string
+	Name    string
+	Vars    map[string]any
 }
-func (s *Store) GetCampaignBody(ctx context.Context, dbOrTx interface {
-	QueryRowContext(context.Context, string, ...any) *sql.Row
-}, campaignID int64) (string, error) {
-	var body string
-	err := dbOrTx.QueryRowContext(ctx, `SELECT body FROM campaigns WHERE id=$1`, campaignID).Scan(&body)
-	return body, err
+
+// recipientChunk ограничивает размер массивов-параметров одного INSERT.
+const recipientChunk = 5000
+
+// InsertRecipients добавляет получателей кампании вместе с их сообщениями.
+// Строки передаются массивами и разворачиваются через unnest, так что на пачку
+// из recipientChunk получателей уходит один запрос, а не два на каждого.
+// Адрес, уже записанный в кампанию, пропускается (уникальный индекс
+// по campaign_id, address), так что inserted может быть меньше len(rs).
+// Сообщение адреса из списка блокировок сразу получает статус suppressed;
+// suppressed — сколько таких.
+func (s *Store) InsertRecipients(ctx context.Context, tx *sql.Tx, campaignID int64, rs []NewRecipient) (inserted, suppressed int, err error) {
+	for start := 0; start < len(rs); start += recipientChunk {
+		chunk := rs[start:min(start+recipientChunk, len(rs))]
+		addrs := make(textSlice, len(chunk))
+		names := make(textSlice, len(chunk))
+		vars := make(textSlice, len(chunk))
+		for i, r := range chunk {
+			raw, err := jsonObject(r.Vars)
+			if err != nil {
+				return inserted, suppressed, err
+			}
+			addrs[i], names[i], vars[i] = r.Address, r.Name, raw
+		}
+
+		var n, sup int
+		err := tx.QueryRowContext(ctx, `
+			WITH r AS (
+			    INSERT INTO recipients (campaign_id, address, vars, name)
+			    SELECT $1, t.address, t.vars::jsonb, t.name
+			      FROM unnest($2::text[], $3::text[], $4::text[]) WITH ORDINALITY AS t(address, vars, name, n)
+			     ORDER BY t.n
+			    ON CONFLICT (campaign_id, address) DO NOTHING
+			 RETURNING id, address
+			), m AS (
+			    INSERT INTO messages (campaign_id, recipient_id, status)
+			    SELECT $1, r.id, CASE WHEN `+suppressedSQL("r.address")+` THEN 'suppressed' ELSE 'pending' END
+			      FROM r ORDER BY r.id
+			 RETURNING status
+			)
+			SELECT COUNT(*), COUNT(*) FILTER (WHERE status='suppressed') FROM m
+		`, campaignID, addrs, vars, names).Scan(&n, &sup)
+		if err != nil {
+			return inserted, suppressed, err
+		}
+		inserted += n
+		suppressed += sup
+	}
+	return inserted, suppressed, nil
 }
 
-func (s *Store) InsertMessagePending(ctx context.Context, tx *sql.Tx, campaignID, recipientID int64) error {
-	_, err := tx.ExecContext(ctx, `
-		INSERT INTO messages (campaign_id, recipient_id, status)
-		VALUES ($1,$2,'pending')
-	`, campaignID, recipientID)
-	return err
+// suppressedSQL — условие «для адреса в колонке col действует блокировка».
+func suppressedSQL(col string) string {
+	return `EXISTS (SELECT 1 FROM suppressions s
+		             WHERE s.address = lower(` + col + `)
+		               AND (s.expires_at IS NULL OR s.expires_at > NOW()))`
 }
 
-func (s *Store) MarkMessageSent(ctx context.Context, msg *sql.DB, campaignID, recipientID int64) error {
-	_, err := msg.ExecContext(ctx, `
+// JobRow — всё, что нужно воркеру для обработки одного задания.
+type JobRow struct {
+	Body           string
+	Format         string
+	TextBody       string
+	CampaignStatus string
+	MessageStatus  string
+	RecipientName  string
+	Vars           map[string]any
+	Retry          campaign.RetryPolicy
+	// Suppressed — адрес получателя сейчас в списке блокировок.
+	Suppressed bool
+	TrackOpens bool
+	Envelope
+}
+
+func (s *Store) LoadJob(ctx context.Context, q Querier, campaignID, recipientID int64) (JobRow, error) {
+	var j JobRow
+	var rawVars, rawHeaders, rawRetry []byte
+	err := q.QueryRowContext(ctx, `
+		SELECT c.body, c.status, m.status, r.vars,
+		       c.subject, c.from_name, c.from_address, c.reply_to, c.headers, c.text_body,
+		       c.retry_policy, r.name, `+suppressedSQL("r.address")+`, c.track_opens, c.body_format
+		  FROM messages m
+		  JOIN campaigns c  ON c.id = m.campaign_id
+		  JOIN recipients r ON r.id = m.recipient_id
+		 WHERE m.campaign_id=$1 AND m.recipient_id=$2
+	`, campaignID, recipientID).Scan(&j.Body, &j.CampaignStatus, &j.MessageStatus, &rawVars,
+		&j.Subject, &j.FromName, &j.FromAddress, &j.ReplyTo, &rawHeaders, &j.TextBody,
+		&rawRetry, &j.RecipientName, &j.Suppressed, &j.TrackOpens, &j.Format)
+	if err != nil {
+		return JobRow{}, err
+	}
+	if len(rawRetry) > 0 {
+		if err := json.Unmarshal(rawRetry, &j.Retry); err != nil {
+			return JobRow{}, err
+		}
+	}
+	if len(rawVars) > 0 {
+		if err := json.Unmarshal(rawVars, &j.Vars); err != nil {
+			return JobRow{}, err
+		}
+	}
+	if len(rawHeaders) > 0 {
+		if err := json.Unmarshal(rawHeaders, &j.Headers); err != nil {
+			return JobRow{}, err
+		}
+	}
+	return j, nil
+}
+
+// ClaimMessage атомарно забирает сообщение в отправку: pending → sending
+// с владельцем owner до истечения lease. Просроченную чужую заявку тоже
+// можно забрать — её владелец, скорее всего, упал. false означает, что
+// сообщение уже отправлено, завершено или его держит живой воркер.
+func (s *Store) ClaimMessage(ctx context.Context, msg *sql.DB, campaignID, recipientID int64, owner string, lease time.Duration) (bool, error) {
+	res, err := msg.ExecContext(ctx, `
 		UPDATE messages
-		   SET status='sent', sent_at=NOW(), last_error=NULL
+		   SET status='sending', claimed_by=$3, claim_expires_at=NOW() + make_interval(secs => $4)
 		 WHERE campaign_id=$1 AND recipient_id=$2
+		   AND (status='pending' OR (status='sending' AND claim_expires_at < NOW()))
+	`, campaignID, recipientID, owner, lease.Seconds())
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// MarkMessageSent завершает отправку сообщения, которое держит owner
+// (см. ClaimMessage). Если заявку уже потеряли — ErrClaimLost.
+func (s *Store) MarkMessageSent(ctx context.Context, msg *sql.DB, campaignID, recipientID int64, owner string) error {
+	return claimed(msg.ExecContext(ctx, `
+		UPDATE messages
+		   SET status='sent', sent_at=NOW(), last_error=NULL, error_class=NULL,
+		       claimed_by=NULL, claim_expires_at=NULL
+		 WHERE campaign_id=$1 AND recipient_id=$2
+		   AND status='sending' AND claimed_by=$3
+	`, campaignID, recipientID, owner))
+}
+
+// RecordMessageError сохраняет ошибку попытки и возвращает сообщение в pending до ретрая,
+// который придёт из очереди через retryIn. Как и MarkMessageSent, пишет только
+// владелец заявки: отменённое за время попытки сообщение остаётся canceled.
+// errorClass — класс ошибки доставки (temporary, permanent, rate_limited, policy_blocked).
+func (s *Store) RecordMessageError(ctx context.Context, msg *sql.DB, campaignID, recipientID int64, owner, lastErr, errorClass string, retryIn time.Duration) error {
+	return claimed(msg.ExecContext(ctx, `
+		UPDATE messages
+		   SET status='pending', last_error=$1, error_class=$4,
+		       claimed_by=NULL, claim_expires_at=NULL,
+		       queued_at=NOW() + make_interval(secs => $5)
+		 WHERE campaign_id=$2 AND recipient_id=$3
+		   AND status='sending' AND claimed_by=$6
+	`, lastErr, campaignID, recipientID, errorClass, retryIn.Seconds(), owner))
+}
+
+// MarkMessageFailed окончательно завершает сообщение ошибкой. Пустой owner —
+// сообщение ещё не забрано в отправку и должно быть pending; иначе его
+// должен держать owner. Если это не так — ErrClaimLost.
+func (s *Store) MarkMessageFailed(ctx context.Context, msg *sql.DB, campaignID, recipientID int64, owner, lastErr, errorClass string) error {
+	return claimed(msg.ExecContext(ctx, `
+		UPDATE messages
+		   SET status='failed', last_error=$1, error_class=$4,
+		       claimed_by=NULL, claim_expires_at=NULL
+		 WHERE campaign_id=$2 AND recipient_id=$3
+		   AND ((status='pending' AND $5 = '') OR (status='sending' AND claimed_by=$5))
+	`, lastErr, campaignID, recipientID, errorClass, owner))
+}
+
+// claimed переводит «ни одна строка не обновлена» в ErrClaimLost.
+func claimed(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrClaimLost
+	}
+	return nil
+}
+
+// MarkMessageSuppressed снимает с отправки сообщение, адрес которого попал
+// в список блокировок после создания кампании.
+func (s *Store) MarkMessageSuppressed(ctx context.Context, msg *sql.DB, campaignID, recipientID int64) error {
+	_, err := msg.ExecContext(ctx, `
+		UPDATE messages
+		   SET status='suppressed', claimed_by=NULL, claim_expires_at=NULL
+		 WHERE campaign_id=$1 AND recipient_id=$2 AND status IN ('pending','sending')
 	`, campaignID, recipientID)
 	return err
 }
 
-func (s *Store) MarkMessageFailed(ctx context.Context, msg *sql.DB, campaignID, recipientID int64, lastErr string) error {
+// Attempt — одна попытка отправки сообщения.
+type Attempt struct {
+	Attempt      int
+	StartedAt    time.Time
+	Duration     time.Duration
+	Transport    string
+	Provider     string
+	ResponseCode int
+	ResponseText string
+	ErrorClass   string
+	Error        string
+}
+
+// InsertAttempt дописывает попытку в историю сообщения; в отличие от
+// last_error, история не перезаписывается.
+func (s *Store) InsertAttempt(ctx context.Context, msg *sql.DB, campaignID, recipientID int64, a Attempt) error {
 	_, err := msg.ExecContext(ctx, `
+		INSERT INTO message_attempts (message_id, attempt, started_at, duration_ms, transport, provider,
+		                              response_code, response_text, error_class, error)
+		SELECT m.id, $3::int, $4::timestamptz, $5::bigint, $6::text, $7::text,
+		       NULLIF($8::int, 0), $9::text, NULLIF($10::text, ''), NULLIF($11::text, '')
+		  FROM messages m
+		 WHERE m.campaign_id=$1 AND m.recipient_id=$2
+	`, campaignID, recipientID, a.Attempt, a.StartedAt, a.Duration.Milliseconds(), a.Transport, a.Provider,
+		a.ResponseCode, a.ResponseText, a.ErrorClass, a.Error)
+	return err
+}
+
+// ListAttempts возвращает историю попыток сообщения кампании в порядке
+// отправки; sql.ErrNoRows — если такого сообщения в кампании нет.
+func (s *Store) ListAttempts(ctx context.Context, campaignID, messageID int64) ([]Attempt, error) {
+	var exists bool
+	if err := s.DB.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM messages WHERE id=$1 AND campaign_id=$2)
+	`, messageID, campaignID).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, sql.ErrNoRows
+	}
+
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT attempt, started_at, duration_ms, transport, provider,
+		       COALESCE(response_code, 0), response_text, COALESCE(error_class, ''), COALESCE(error, '')
+		  FROM message_attempts
+		 WHERE message_id=$1
+		 ORDER BY started_at, id
+	`, messageID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	out := []Attempt{}
+	for rows.Next() {
+		var a Attempt
+		var ms int64
+		if err := rows.Scan(&a.Attempt, &a.StartedAt, &ms, &a.Transport, &a.Provider,
+			&a.ResponseCode, &a.ResponseText, &a.ErrorClass, &a.Error); err != nil {
+			return nil, err
+		}
+		a.Duration = time.Duration(ms) * time.Millisecond
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+// MessageEvent — событие сообщения после отправки, например отказ из DSN.
+type MessageEvent struct {
+	CampaignID  int64
+	RecipientID int64
+	Type        string
+	Status      string
+	Detail      string
+	// ReportID — ключ отчёта, из которого взято событие; событие с уже
+	// записанным для этого сообщения ключом не записывается.
+	ReportID string
+}
+
+// BounceResult — итог записи отказа: адрес получателя, сколько у него
+// постоянных отказов по всем кампаниям с учётом этого и заблокирован ли он.
+// Duplicate — отчёт с тем же ReportID уже был учтён, ничего не изменилось.
+type BounceResult struct {
+	Address     string
+	HardBounces int
+	Suppressed  bool
+	Duplicate   bool
+}
+
+// RecordBounce записывает отказ одним запросом. Постоянный отказ переводит
+// отправленное сообщение в bounced, а адрес, набравший suppressAfter
+// постоянных отказов, бессрочно блокируется. Если сообщения нет — sql.ErrNoRows.
+func (s *Store) RecordBounce(ctx context.Context, e MessageEvent, suppressAfter int) (BounceResult, error) {
+	var b BounceResult
+	// CTE не видят строк, вставленных соседними CTE, поэтому к счётчику
+	// отказов из таблицы прибавляется только что записанный
+	err := s.DB.QueryRowContext(ctx, `
+		WITH msg AS (
+		    SELECT m.id, lower(r.address) AS address
+		      FROM messages m
+		      JOIN recipients r ON r.id = m.recipient_id
+		     WHERE m.campaign_id=$1 AND m.recipient_id=$2
+		), ev AS (
+		    INSERT INTO message_events (message_id, type, address, status, detail, report_id)
+		    SELECT id, $3, address, $4, $5, NULLIF($7, '') FROM msg
+		    ON CONFLICT (message_id, report_id) DO NOTHING
+		 RETURNING message_id, address
+		), bounced AS (
+		    UPDATE messages m
+		       SET status='bounced', last_error=NULLIF($5, ''), error_class='permanent'
+		      FROM ev
+		     WHERE m.id = ev.message_id AND $3 = 'hard_bounce' AND m.status='sent'
+		), hard AS (
+		    SELECT msg.address,
+		           (SELECT COUNT(*) FROM message_events e WHERE e.address = msg.address AND e.type='hard_bounce')
+		           + CASE WHEN $3 = 'hard_bounce' AND EXISTS (SELECT 1 FROM ev) THEN 1 ELSE 0 END AS n
+		      FROM msg
+		), sup AS (
+		    INSERT INTO suppressions (address, reason, source)
+		    SELECT address, 'hard_bounce', 'bounce' FROM hard
+		     WHERE $3 = 'hard_bounce' AND n >= $6 AND EXISTS (SELECT 1 FROM ev)
+		    ON CONFLICT (address) DO UPDATE SET expires_at=NULL
+		 RETURNING address
+		)
+		SELECT hard.address, hard.n,
+		       EXISTS (SELECT 1 FROM sup) OR `+suppressedSQL("hard.address")+`,
+		       NOT EXISTS (SELECT 1 FROM ev)
+		  FROM hard
+	`, e.CampaignID, e.RecipientID, e.Type, e.Status, e.Detail, suppressAfter, e.ReportID).
+		Scan(&b.Address, &b.HardBounces, &b.Suppressed, &b.Duplicate)
+	return b, err
+}
+
+// RecordComplaint записывает жалобу получателя (status события — вид отчёта,
+// например abuse) и бессрочно блокирует его адрес; возвращает адрес. Уже
+// заблокированный адрес сохраняет прежнюю причину. Если сообщения нет —
+// sql.ErrNoRows.
+func (s *Store) RecordComplaint(ctx context.Context, campaignID, recipientID int64, feedbackType, detail string) (string, error) {
+	var addr string
+	err := s.DB.QueryRowContext(ctx, `
+		WITH ev AS (
+		    INSERT INTO message_events (message_id, type, address, status, detail)
+		    SELECT m.id, 'complained', lower(r.address), $3, $4
+		      FROM messages m
+		      JOIN recipients r ON r.id = m.recipient_id
+		     WHERE m.campaign_id=$1 AND m.recipient_id=$2
+		 RETURNING address
+		), sup AS (
+		    INSERT INTO suppressions (address, reason, source)
+		    SELECT address, 'complaint', 'fbl' FROM ev
+		    ON CONFLICT (address) DO UPDATE SET expires_at=NULL
+		)
+		SELECT address FROM ev
+	`, campaignID, recipientID, feedbackType, detail).Scan(&addr)
+	return addr, err
+}
+
+// MessageOpen — открытия одного сообщения по пикселю, накопленные с
+// прошлой записи.
+type MessageOpen struct {
+	CampaignID  int64
+	RecipientID int64
+	Count       int
+	First       time.Time
+	Last        time.Time
+}
+
+// RecordOpens добавляет открытия сообщений одним запросом: счётчик растёт,
+// время первого открытия ставится однажды, последнего — сдвигается вперёд.
+// Открытия несуществующих сообщений молча пропускаются.
+func (s *Store) RecordOpens(ctx context.Context, opens []MessageOpen) error {
+	if len(opens) == 0 {
+		return nil
+	}
+	campaigns := make(int64Slice, len(opens))
+	recipients := make(int64Slice, len(opens))
+	counts := make(int64Slice, len(opens))
+	firsts := make(textSlice, len(opens))
+	lasts := make(textSlice, len(opens))
+	for i, o := range opens {
+		campaigns[i], recipients[i], counts[i] = o.CampaignID, o.RecipientID, int64(o.Count)
+		firsts[i] = o.First.UTC().Format(time.RFC3339Nano)
+		lasts[i] = o.Last.UTC().Format(time.RFC3339Nano)
+	}
+	_, err := s.DB.ExecContext(ctx, `
+		UPDATE messages m
+		   SET open_count      = m.open_count + t.n,
+		       first_opened_at = COALESCE(m.first_opened_at, t.first::timestamptz),
+		       last_opened_at  = GREATEST(m.last_opened_at, t.last::timestamptz)
+		  FROM unnest($1::bigint[], $2::bigint[], $3::bigint[], $4::text[], $5::text[])
+		       AS t(campaign_id, recipient_id, n, first, last)
+		 WHERE m.campaign_id = t.campaign_id AND m.recipient_id = t.recipient_id
+	`, campaigns, recipients, counts, firsts, lasts)
+	return err
+}
+
+// ImportRow — асинхронная загрузка получателей кампании.
+type ImportRow struct {
+	ID         int64
+	CampaignID int64
+	Format     string
+	Status     string
+	Processed  int
+	Accepted   int
+	Rejected   int
+	Duplicates int
+	Errors     []campaign.ImportRowError
+	Error      string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	FinishedAt *time.Time
+}
+
+// ImportProgress — приращение счётчиков импорта за одну пачку.
+type ImportProgress struct {
+	Processed  int
+	Accepted   int
+	Rejected   int
+	Duplicates int
+	Errors     []campaign.ImportRowError
+}
+
+// CreateImport заводит импорт для кампании в importing. У кампании может быть
+// только один импорт: если он уже есть или кампания не ждёт импорта,
+// возвращается sql.ErrNoRows.
+func (s *Store) CreateImport(ctx context.Context, campaignID int64, format string) (int64, error) {
+	var id int64
+	err := s.DB.QueryRowContext(ctx, `
+		INSERT INTO imports (campaign_id, format)
+		SELECT id, $2 FROM campaigns WHERE id=$1 AND status='importing'
+		ON CONFLICT (campaign_id) DO NOTHING
+		RETURNING id
+	`, campaignID, format).Scan(&id)
+	return id, err
+}
+
+func (s *Store) GetImport(ctx context.Context, id int64) (ImportRow, error) {
+	var r ImportRow
+	var rawErrors []byte
+	err := s.DB.QueryRowContext(ctx, `
+		SELECT id, campaign_id, format, status, processed, accepted, rejected, duplicates, errors,
+		       COALESCE(error, ''), created_at, updated_at, finished_at
+		  FROM imports
+		 WHERE id=$1
+	`, id).Scan(&r.ID, &r.CampaignID, &r.Format, &r.Status, &r.Processed, &r.Accepted, &r.Rejected,
+		&r.Duplicates, &rawErrors, &r.Error, &r.CreatedAt, &r.UpdatedAt, &r.FinishedAt)
+	if err != nil {
+		return ImportRow{}, err
+	}
+	if len(rawErrors) > 0 {
+		if err := json.Unmarshal(rawErrors, &r.Errors); err != nil {
+			return ImportRow{}, err
+		}
+	}
+	return r, nil
+}
+
+// StartImport переводит импорт pending → running; sql.ErrNoRows — импорт уже
+// не ждёт обработки (например, его закрыл FailStaleImports).
+func (s *Store) StartImport(ctx context.Context, id int64) error {
+	res, err := s.DB.ExecContext(ctx, `
+		UPDATE imports SET status='running', updated_at=NOW()
+		 WHERE id=$1 AND status='pending'
+	`, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// AddImportProgress прибавляет к счётчикам импорта итоги пачки; обновление
+// updated_at служит пульсом для FailStaleImports.
+func (s *Store) AddImportProgress(ctx context.Context, tx *sql.Tx, id int64, p ImportProgress) error {
+	errs := []campaign.ImportRowError{}
+	if len(p.Errors) > 0 {
+		errs = p.Errors
+	}
+	rawErrors, err := json.Marshal(errs)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+		UPDATE imports
+		   SET processed = processed + $2,
+		       accepted  = accepted + $3,
+		       rejected  = rejected + $4,
+		       errors    = errors || $5::jsonb,
+		       duplicates = duplicates + $6,
+		       updated_at = NOW()
+		 WHERE id=$1
+	`, id, p.Processed, p.Accepted, p.Rejected, string(rawErrors), p.Duplicates)
+	return err
+}
+
+// FinishImport завершает импорт со статусом completed или failed.
+func (s *Store) FinishImport(ctx context.Context, q Querier, id int64, status, lastErr string) error {
+	_, err := q.ExecContext(ctx, `
+		UPDATE imports
+		   SET status=$2, error=NULLIF($3, ''), updated_at=NOW(), finished_at=NOW()
+		 WHERE id=$1
+	`, id, status, lastErr)
+	return err
+}
+
+// FailStaleImports закрывает импорты, которые дольше staleAfter не двигались:
+// их процесс, скорее всего, упал вместе с загруженным файлом. Кампании таких
+// импортов отменяются вместе с уже записанными сообщениями.
+func (s *Store) FailStaleImports(ctx context.Context, staleAfter time.Duration) (int, error) {
+	var n int
+	err := s.DB.QueryRowContext(ctx, `
+		WITH stale AS (
+		    UPDATE imports
+		       SET status='failed', error='import interrupted', updated_at=NOW(), finished_at=NOW()
+		     WHERE status IN ('pending','running') AND updated_at < NOW() - make_interval(secs => $1)
+		 RETURNING campaign_id
+		), canceled AS (
+		    UPDATE campaigns c
+		       SET status='canceled', finished_at=NOW()
+		      FROM stale
+		     WHERE c.id = stale.campaign_id AND c.status='importing'
+		 RETURNING c.id
+		), msgs AS (
+		    UPDATE messages m
+		       SET status='canceled', claimed_by=NULL, claim_expires_at=NULL
+		      FROM canceled
+		     WHERE m.campaign_id = canceled.id AND m.status IN ('pending','sending')
+		)
+		SELECT COUNT(*) FROM stale
+	`, staleAfter.Seconds()).Scan(&n)
+	return n, err
+}
+
+// Suppression — запись списка блокировок.
+type Suppression struct {
+	Address   string
+	Reason    string
+	Source    string
+	CreatedAt time.Time
+	ExpiresAt *time.Time
+}
+
+// SuppressionQuery — страница списка блокировок: адреса строго после After
+// в порядке сортировки, не больше Limit; Reason сужает выборку.
+type SuppressionQuery struct {
+	Reason string
+	After  string
+	Limit  int
+}
+
+// UpsertSuppressions добавляет адреса в список блокировок; у уже
+// заблокированного адреса обновляются причина, источник и срок. Если адрес
+// повторяется в ss, действует последняя запись. Адреса ожидаются в виде
+// campaign.SuppressionKey.
+func (s *Store) UpsertSuppressions(ctx context.Context, q Querier, ss []Suppression) (int, error) {
+	upserted := 0
+	for start := 0; start < len(ss); start += recipientChunk {
+		chunk := ss[start:min(start+recipientChunk, len(ss))]
+		addrs := make(textSlice, len(chunk))
+		reasons := make(textSlice, len(chunk))
+		sources := make(textSlice, len(chunk))
+		expires := make(textSlice, len(chunk))
+		for i, sp := range chunk {
+			addrs[i], reasons[i], sources[i] = sp.Address, sp.Reason, sp.Source
+			if sp.ExpiresAt != nil {
+				expires[i] = sp.ExpiresAt.UTC().Format(time.RFC3339Nano)
+			}
+		}
+
+		res, err := q.ExecContext(ctx, `
+			INSERT INTO suppressions (address, reason, source, expires_at)
+			SELECT DISTINCT ON (t.address) t.address, t.reason, t.source, NULLIF(t.expires_at, '')::timestamptz
+			  FROM unnest($1::text[], $2::text[], $3::text[], $4::text[])
+			       WITH ORDINALITY AS t(address, reason, source, expires_at, n)
+			 ORDER BY t.address, t.n DESC
+			ON CONFLICT (address) DO UPDATE
+			   SET reason=EXCLUDED.reason, source=EXCLUDED.source,
+			       expires_at=EXCLUDED.expires_at, created_at=NOW()
+		`, addrs, reasons, sources, expires)
+		if err != nil {
+			return upserted, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return upserted, err
+		}
+		upserted += int(n)
+	}
+	return upserted, nil
+}
+
+// SuppressRecipient бессрочно блокирует адрес получателя кампании и
+// возвращает его ключ в списке. У уже заблокированного адреса остаются
+// прежние причина и источник, снимается только срок. Если получателя нет
+// в кампании — sql.ErrNoRows.
+func (s *Store) SuppressRecipient(ctx context.Context, campaignID, recipientID int64, reason, source string) (string, error) {
+	var addr string
+	err := s.DB.QueryRowContext(ctx, `
+		INSERT INTO suppressions (address, reason, source)
+		SELECT lower(r.address), $3, $4
+		  FROM recipients r
+		 WHERE r.id=$2 AND r.campaign_id=$1
+		ON CONFLICT (address) DO UPDATE SET expires_at=NULL
+		RETURNING address
+	`, campaignID, recipientID, reason, source).Scan(&addr)
+	return addr, err
+}
+
+func (s *Store) GetSuppression(ctx context.Context, address string) (Suppression, error) {
+	var sp Suppression
+	err := s.DB.QueryRowContext(ctx, `
+		SELECT address, reason, source, created_at, expires_at
+		  FROM suppressions
+		 WHERE address=$1
+	`, address).Scan(&sp.Address, &sp.Reason, &sp.Source, &sp.CreatedAt, &sp.ExpiresAt)
+	return sp, err
+}
+
+// DeleteSuppression убирает адрес из списка блокировок; false — его там не было.
+func (s *Store) DeleteSuppression(ctx context.Context, address string) (bool, error) {
+	res, err := s.DB.ExecContext(ctx, `DELETE FROM suppressions WHERE address=$1`, address)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// ListSuppressions возвращает страницу списка блокировок по возрастанию
+// адреса, включая записи с истёкшим сроком.
+func (s *Store) ListSuppressions(ctx context.Context, q SuppressionQuery) ([]Suppression, error) {
+	if q.Limit <= 0 {
+		q.Limit = 100
+	}
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT address, reason, source, created_at, expires_at
+		  FROM suppressions
+		 WHERE address > $1 AND ($2 = '' OR reason = $2)
+		 ORDER BY address
+		 LIMIT $3
+	`, q.After, q.Reason, q.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	out := []Suppression{}
+	for rows.Next() {
+		var sp Suppression
+		if err := rows.Scan(&sp.Address, &sp.Reason, &sp.Source, &sp.CreatedAt, &sp.ExpiresAt); err != nil {
+			return nil, err
+		}
+		out = append(out, sp)
+	}
+	return out, rows.Err()
+}
+
+// ClaimDueCampaign блокирует одну queued-кампанию, время которой наступило.
+// SKIP LOCKED позволяет нескольким планировщикам работать параллельно,
+// не забирая одну и ту же кампанию. Если кампаний нет — возвращает sql.ErrNoRows.
+func (s *Store) ClaimDueCampaign(ctx context.Context, tx *sql.Tx, now time.Time) (int64, error) {
+	var id int64
+	err := tx.QueryRowContext(ctx, `
+		SELECT id
+		  FROM campaigns
+		 WHERE status='queued' AND scheduled_at <= $1
+		 ORDER BY scheduled_at
+		 LIMIT 1
+		 FOR UPDATE SKIP LOCKED
+	`, now).Scan(&id)
+	return id, err
+}
+
+// SetCampaignStatus переводит кампанию в статус to, если это разрешено
+// campaign.CanTransition из её текущего статуса. Проверка выполняется
+// в самом UPDATE, поэтому гонка двух переходов невозможна: проигравший
+// получает campaign.ErrInvalidTransition.
+func (s *Store) SetCampaignStatus(ctx context.Context, q Querier, id int64, to string) error {
+	from := campaign.Sources(to)
+	if len(from) == 0 {
+		return campaign.ErrInvalidTransition
+	}
+	res, err := q.ExecContext(ctx, `
+		UPDATE campaigns
+		   SET status=$2,
+		       finished_at = CASE WHEN $4 THEN NOW() ELSE finished_at END
+		 WHERE id=$1 AND status = ANY($3)
+	`, id, to, textSlice(from), campaign.IsFinal(to))
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return campaign.ErrInvalidTransition
+	}
+	return nil
+}
+
+// LockCampaignStatus возвращает статус кампании и до конца транзакции tx
+// не даёт его сменить: параллельный переход дождётся её коммита и увидит
+// всё, что она записала.
+func (s *Store) LockCampaignStatus(ctx context.Context, tx *sql.Tx, id int64) (string, error) {
+	var status string
+	err := tx.QueryRowContext(ctx, `SELECT status FROM campaigns WHERE id=$1 FOR SHARE`, id).Scan(&status)
+	return status, err
+}
+
+// CancelMessages снимает с отправки все ещё не отправленные сообщения
+// кампании: pending и sending → canceled. Вызывается в той же транзакции,
+// что и перевод кампании в canceled. Воркер, который отправляет письмо
+// прямо сейчас, теряет заявку: итог его попытки уже не запишется.
+func (s *Store) CancelMessages(ctx context.Context, q Querier, campaignID int64) (int, error) {
+	res, err := q.ExecContext(ctx, `
 		UPDATE messages
-		   SET status='failed', last_error=$1
-		 WHERE campaign_id=$2 AND recipient_id=$3
-	`, lastErr, campaignID, recipientID)
+		   SET status='canceled', claimed_by=NULL, claim_expires_at=NULL
+		 WHERE campaign_id=$1 AND status IN ('pending','sending')
+	`, campaignID)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// ListDrainedCampaigns возвращает кампании в processing, все сообщения
+// которых уже получили окончательный статус; sending окончательным не считается.
+func (s *Store) ListDrainedCampaigns(ctx context.Context, limit int) ([]DrainedCampaign, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT c.id,
+		       COUNT(m.id)                                  AS total,
+		       COUNT(m.id) FILTER (WHERE m.status='failed') AS failed
+		  FROM campaigns c
+		  LEFT JOIN messages m ON m.campaign_id = c.id
+		 WHERE c.status='processing'
+		 GROUP BY c.id
+		HAVING COUNT(m.id) FILTER (WHERE m.status IN ('pending','sending')) = 0
+		 ORDER BY c.id
+		 LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var out []DrainedCampaign
+	for rows.Next() {
+		var d DrainedCampaign
+		if err := rows.Scan(&d.ID, &d.Total, &d.Failed); err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+// EnqueuePendingJobs кладёт в outbox задания для pending-сообщений кампании
+// одним запросом, не вычитывая их в приложение. Сообщения, отложенные
+// RecordMessageError до ретрая (queued_at в будущем), пропускаются: их
+// задание ещё ждёт в очереди задержки и придёт само, не нарушив backoff.
+func (s *Store) EnqueuePendingJobs(ctx context.Context, tx *sql.Tx, campaignID int64) (int, error) {
+	var n int
+	err := tx.QueryRowContext(ctx, `
+		WITH queued AS (
+		    UPDATE messages
+		       SET queued_at=NOW()
+		     WHERE campaign_id=$1 AND status='pending'
+		       AND (queued_at IS NULL OR queued_at <= NOW())
+		 RETURNING id, recipient_id
+		), jobs AS (
+		    INSERT INTO outbox (payload)
+		    SELECT jsonb_build_object('campaign_id', $1::bigint, 'recipient_id', q.recipient_id, 'address', r.address)
+		      FROM queued q
+		      JOIN recipients r ON r.id = q.recipient_id
+		     ORDER BY q.id
+		)
+		SELECT COUNT(*) FROM queued
+	`, campaignID).Scan(&n)
+	return n, err
+}
+
+// Requeued — сколько застрявших сообщений reconciler вернул в очередь.
+type Requeued struct {
+	// Pending — задание pending-сообщения потерялось по дороге в очередь или в ней.
+	Pending int
+	// Sending — заявка воркера давно истекла, а задание так и не вернулось.
+	Sending int
+}
+
+// RequeueStuckMessages заново ставит в outbox задания сообщений кампаний
+// в processing, которые дольше stuckAfter ждут в pending или висят в sending
+// с истёкшей заявкой. queued_at сдвигается, а SKIP LOCKED не даёт двум
+// reconciler'ам взять одно сообщение; дубль задания для ещё живого сообщения
+// безопасен — воркер отправляет только то, что смог забрать.
+func (s *Store) RequeueStuckMessages(ctx context.Context, stuckAfter time.Duration, limit int) (Requeued, error) {
+	var r Requeued
+	err := s.DB.QueryRowContext(ctx, `
+		WITH stuck AS (
+		    SELECT m.id, m.status
+		      FROM messages m
+		      JOIN campaigns c ON c.id = m.campaign_id
+		     WHERE c.status='processing'
+		       AND ((m.status='pending' AND COALESCE(m.queued_at, '-infinity') < NOW() - make_interval(secs => $1))
+		         OR (m.status='sending' AND m.claim_expires_at < NOW() - make_interval(secs => $1)))
+		     ORDER BY m.id
+		     LIMIT $2
+		       FOR UPDATE OF m SKIP LOCKED
+		), requeued AS (
+		    UPDATE messages m
+		       SET status='pending', claimed_by=NULL, claim_expires_at=NULL, queued_at=NOW()
+		      FROM stuck
+		     WHERE m.id = stuck.id
+		 RETURNING m.campaign_id, m.recipient_id, stuck.status AS was
+		), queued AS (
+		    INSERT INTO outbox (payload)
+		    SELECT jsonb_build_object('campaign_id', q.campaign_id, 'recipient_id', q.recipient_id, 'address', r.address)
+		      FROM requeued q
+		      JOIN recipients r ON r.id = q.recipient_id
+		)
+		SELECT COUNT(*) FILTER (WHERE was='pending'),
+		       COUNT(*) FILTER (WHERE was='sending')
+		  FROM requeued
+	`, stuckAfter.Seconds(), limit).Scan(&r.Pending, &r.Sending)
+	return r, err
+}
+
+// ClaimOutbox блокирует пачку неотправленных записей; SKIP LOCKED позволяет
+// нескольким relay работать параллельно без повторной публикации.
+func (s *Store) ClaimOutbox(ctx context.Context, tx *sql.Tx, limit int) ([]OutboxRow, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, payload
+		  FROM outbox
+		 WHERE dispatched_at IS NULL
+		 ORDER BY id
+		 LIMIT $1
+		 FOR UPDATE SKIP LOCKED
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var out []OutboxRow
+	for rows.Next() {
+		var r OutboxRow
+		if err := rows.Scan(&r.ID, &r.Payload); err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+func (s *Store) MarkOutboxDispatched(ctx context.Context, tx *sql.Tx, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	_, err := tx.ExecContext(ctx, `
+		UPDATE outbox
+		   SET dispatched_at=NOW(), attempts=attempts+1, last_error=NULL
+		 WHERE id = ANY($1)
+	`, int64Slice(ids))
+	return err
+}
+
+func (s *Store) RecordOutboxError(ctx context.Context, tx *sql.Tx, id int64, lastErr string) error {
+	_, err := tx.ExecContext(ctx, `
+		UPDATE outbox
+		   SET attempts=attempts+1, last_error=$2
+		 WHERE id=$1
+	`, id, lastErr)
 	return err
 }
 
 func (s *Store) GetCampaign(ctx context.Context, id int64) (CampaignRow, error) {
 	var c CampaignRow
+	var rawHeaders, rawRetry []byte
 	err := s.DB.QueryRowContext(ctx, `
-		SELECT id, name, body, scheduled_at, status, created_at
+		SELECT id, name, body, scheduled_at, status, created_at, finished_at,
+		       subject, from_name, from_address, reply_to, headers, text_body, retry_policy, track_opens, body_format
 		FROM campaigns
 		WHERE id = $1
-	`, id).Scan(&c.ID, &c.Name, &c.Body, &c.ScheduledAt, &c.Status, &c.CreatedAt)
+	`, id).Scan(&c.ID, &c.Name, &c.Body, &c.ScheduledAt, &c.Status, &c.CreatedAt, &c.FinishedAt,
+		&c.Subject, &c.FromName, &c.FromAddress, &c.ReplyTo, &rawHeaders, &c.TextBody, &rawRetry, &c.TrackOpens, &c.Format)
 	if err != nil {
 		return CampaignRow{}, err
 	}
+	if len(rawRetry) > 0 {
+		if err := json.Unmarshal(rawRetry, &c.Retry); err != nil {
+			return CampaignRow{}, err
+		}
+	}
+	if len(rawHeaders) > 0 {
+		if err := json.Unmarshal(rawHeaders, &c.Headers); err != nil {
+			return CampaignRow{}, err
+		}
+	}
 	return c, nil
 }
 
@@ -117,13 +1058,23 @@ func (s *Store) GetCampaignStats(ctx context.Context, id int64) (CampaignStats,
 	var st CampaignStats
 	err := s.DB.QueryRowContext(ctx, `
 		SELECT
-		  COUNT(*)                                         AS total,
-		  COUNT(*) FILTER (WHERE status='pending')         AS pending,
-		  COUNT(*) FILTER (WHERE status='sent')            AS sent,
-		  COUNT(*) FILTER (WHERE status='failed')          AS failed
+		  COUNT(*)                                                AS total,
+		  COUNT(*) FILTER (WHERE status IN ('pending','sending')) AS pending,
+		  COUNT(*) FILTER (WHERE status='sent')                   AS sent,
+		  COUNT(*) FILTER (WHERE status='failed')                 AS failed,
+		  COUNT(*) FILTER (WHERE status='suppressed')             AS suppressed,
+		  COUNT(*) FILTER (WHERE status='bounced')                AS bounced,
+		  COUNT(*) FILTER (WHERE status='canceled')               AS canceled,
+		  `+softBouncesSQL+` AS soft_bounces,
+		  `+complaintsSQL+` AS complaints,
+		  COALESCE(SUM(open_count), 0)                            AS opens,
+		  COUNT(*) FILTER (WHERE open_count > 0)                  AS opened,
+		  `+failureCountsSQL+`
 		FROM messages
+		`+messageEventsJoin("em.campaign_id = $1")+`
 		WHERE campaign_id = $1
-	`, id).Scan(&st.Total, &st.Pending, &st.Sent, &st.Failed)
+	`, id).Scan(append([]any{&st.Total, &st.Pending, &st.Sent, &st.Failed, &st.Suppressed, &st.Bounced, &st.Canceled, &st.SoftBounces, &st.Complaints, &st.Opens, &st.Opened},
+		failureDest(&st.Failures)...)...)
 	if err != nil {
 		return CampaignStats{}, err
 	}
@@ -139,7 +1090,7 @@ func (s *Store) ListCampaigns(ctx context.Context, limit, offset int) ([]Campaig
 	}
 
 	rows, err := s.DB.QueryContext(ctx, `
-		SELECT id, name, body, scheduled_at, status, created_at
+		SELECT id, name, body, scheduled_at, status, created_at, finished_at
 		FROM campaigns
 		ORDER BY id DESC
 		LIMIT $1 OFFSET $2
@@ -155,7 +1106,7 @@ func (s *Store) ListCampaigns(ctx context.Context, limit, offset int) ([]Campaig
 	var ids []int64
 	for rows.Next() {
 		var c CampaignRow
-		if err := rows.Scan(&c.ID, &c.Name, &c.Body, &c.ScheduledAt, &c.Status, &c.CreatedAt); err != nil {
+		if err := rows.Scan(&c.ID, &c.Name, &c.Body, &c.ScheduledAt, &c.Status, &c.CreatedAt, &c.FinishedAt); err != nil {
 			return nil, nil, err
 		}
 		campaigns = append(campaigns, c)
@@ -170,11 +1121,20 @@ func (s *Store) ListCampaigns(ctx context.Context, limit, offset int) ([]Campaig
 
 	statRows, err := s.DB.QueryContext(ctx, `
 		SELECT campaign_id,
-		       COUNT(*)                                         AS total,
-		       COUNT(*) FILTER (WHERE status='pending')         AS pending,
-		       COUNT(*) FILTER (WHERE status='sent')            AS sent,
-		       COUNT(*) FILTER (WHERE status='failed')          AS failed
+		       COUNT(*)                                                AS total,
+		       COUNT(*) FILTER (WHERE status IN ('pending','sending')) AS pending,
+		       COUNT(*) FILTER (WHERE status='sent')                   AS sent,
+		       COUNT(*) FILTER (WHERE status='failed')                 AS failed,
+		       COUNT(*) FILTER (WHERE status='suppressed')             AS suppressed,
+		       COUNT(*) FILTER (WHERE status='bounced')                AS bounced,
+		       COUNT(*) FILTER (WHERE status='canceled')               AS canceled,
+		       `+softBouncesSQL+` AS soft_bounces,
+		       `+complaintsSQL+` AS complaints,
+		       COALESCE(SUM(open_count), 0)                            AS opens,
+		       COUNT(*) FILTER (WHERE open_count > 0)                  AS opened,
+		       `+failureCountsSQL+`
 		FROM messages
+		`+messageEventsJoin("em.campaign_id = ANY($1)")+`
 		WHERE campaign_id = ANY($1)
 		GROUP BY campaign_id
 	`, int64Slice(ids))
@@ -187,7 +1147,9 @@ func (s *Store) ListCampaigns(ctx context.Context, limit, offset int) ([]Campaig
 	for statRows.Next() {
 		var id int64
 		var st CampaignStats
-		if err := statRows.Scan(&id, &st.Total, &st.Pending, &st.Sent, &st.Failed); err != nil {
+		dest := append([]any{&id, &st.Total, &st.Pending, &st.Sent, &st.Failed, &st.Suppressed, &st.Bounced, &st.Canceled, &st.SoftBounces, &st.Complaints, &st.Opens, &st.Opened},
+			failureDest(&st.Failures)...)
+		if err := statRows.Scan(dest...); err != nil {
 			return nil, nil, err
 		}
 		statsByID[id] = st
@@ -203,6 +1165,50 @@ func (s *Store) ListCampaigns(ctx context.Context, limit, offset int) ([]Campaig
 	return campaigns, out, nil
 }
 
+// messageEventsJoin присоединяет к messages события их писем, свёрнутые
+// по сообщению: ev.soft_bounces и ev.complained. campaignCond (по алиасу em)
+// ограничивает свёртку кампаниями запроса, так что события читаются одним
+// проходом, а не подзапросом на каждое сообщение.
+func messageEventsJoin(campaignCond string) string {
+	return `LEFT JOIN (
+		    SELECT e.message_id,
+		           COUNT(*) FILTER (WHERE e.type='soft_bounce') AS soft_bounces,
+		           bool_or(e.type='complained')                AS complained
+		      FROM message_events e
+		      JOIN messages em ON em.id = e.message_id
+		     WHERE ` + campaignCond + ` AND e.type IN ('soft_bounce','complained')
+		     GROUP BY e.message_id
+		) ev ON ev.message_id = messages.id`
+}
+
+// softBouncesSQL считает временные отказы сообщений группы строк messages
+// (нужен messageEventsJoin).
+const softBouncesSQL = `COALESCE(SUM(ev.soft_bounces), 0)`
+
+// complaintsSQL считает сообщения с жалобами: повторный отчёт о том же
+// письме не увеличивает счётчик.
+const complaintsSQL = `COUNT(*) FILTER (WHERE ev.complained)`
+
+// failureCountsSQL считает failed-сообщения по классам ошибок; порядок
+// колонок совпадает с failureDest.
+const failureCountsSQL = `COUNT(*) FILTER (WHERE status='failed' AND error_class='permanent')      AS failed_permanent,
+		  COUNT(*) FILTER (WHERE status='failed' AND error_class='temporary')      AS failed_temporary,
+		  COUNT(*) FILTER (WHERE status='failed' AND error_class='rate_limited')   AS failed_rate_limited,
+		  COUNT(*) FILTER (WHERE status='failed' AND error_class='policy_blocked') AS failed_policy_blocked`
+
+func failureDest(f *campaign.FailureStats) []any {
+	return []any{&f.Permanent, &f.Temporary, &f.RateLimited, &f.PolicyBlocked}
+}
+
+// jsonObject сериализует map для JSONB-колонки; nil становится пустым объектом.
+func jsonObject[M ~map[string]V, V any](m M) (string, error) {
+	if len(m) == 0 {
+		return "{}", nil
+	}
+	b, err := json.Marshal(m)
+	return string(b), err
+}
+
 type int64Slice []int64
 
 func (a int64Slice) Value() (driver.Value, error) {
@@ -220,3 +1226,25 @@ func (a int64Slice) Value() (driver.Value, error) {
 	b.WriteByte('}')
 	return b.String(), nil
 }
+
+type textSlice []string
+
+func (a textSlice) Value() (driver.Value, error) {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, v := range a {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteByte('"')
+		for _, r := range v {
+			if r == '"' || r == '\\' {
+				b.WriteByte('\\')
+			}
+			b.WriteRune(r)
+		}
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String(), nil
+}
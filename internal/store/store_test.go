@@ -3,10 +3,15 @@ package store
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"regexp"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/Mutter0815/MassMailer/internal/campaign"
 )
 
 func TestInsertCampaign_WithTx(t *testing.T) {
@@ -21,17 +26,37 @@ func TestInsertCampaign_WithTx(t *testing.T) {
 
 	mock.ExpectBegin()
 	mock.ExpectQuery(regexp.QuoteMeta(`
-		INSERT INTO campaigns (name,body,scheduled_at,status)
-		VALUES ($1,$2,$3,'queued') RETURNING id
+		INSERT INTO campaigns (name,body,scheduled_at,status,subject,from_name,from_address,reply_to,headers,text_body,retry_policy,track_opens,body_format)
+		VALUES ($1,$2,$3,$11,$4,$5,$6,$7,$8,$9,$10,$12,$13) RETURNING id
 	`)).
-		WithArgs("n", "b", sqlmock.AnyArg()).
+		WithArgs("n", "b", sqlmock.AnyArg(), "Hi", "News", "news@x.com", "", `{"X-Campaign":"n"}`, "",
+			`{"max_attempts":5,"base_delay":"2s","max_delay":"1m0s","jitter":0.2,"deadline":"1h0m0s"}`, "queued", true, "text").
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
 	mock.ExpectCommit()
 
 	var id int64
 	err = s.WithTx(ctx, func(tx *sql.Tx) error {
 		var e error
-		id, e = s.InsertCampaign(ctx, tx, "n", "b", "2025-10-02T12:00:00Z")
+		id, e = s.InsertCampaign(ctx, tx, NewCampaign{
+			Name:        "n",
+			Body:        "b",
+			Format:      "text",
+			ScheduledAt: time.Date(2025, 10, 2, 12, 0, 0, 0, time.UTC),
+			Retry: campaign.RetryPolicy{
+				MaxAttempts: 5,
+				BaseDelay:   2 * time.Second,
+				MaxDelay:    time.Minute,
+				Jitter:      0.2,
+				Deadline:    time.Hour,
+			},
+			TrackOpens: true,
+			Envelope: Envelope{
+				Subject:     "Hi",
+				FromName:    "News",
+				FromAddress: "news@x.com",
+				Headers:     map[string]string{"X-Campaign": "n"},
+			},
+		})
 		return e
 	})
 	if err != nil {
@@ -45,7 +70,7 @@ func TestInsertCampaign_WithTx(t *testing.T) {
 	}
 }
 
-func TestInsertRecipient_And_Message(t *testing.T) {
+func TestInsertRecipients_Batched(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Fatal(err)
@@ -55,27 +80,278 @@ func TestInsertRecipient_And_Message(t *testing.T) {
 	s := New(db)
 	ctx := context.Background()
 
+	rs := make([]NewRecipient, recipientChunk+2)
+	for i := range rs {
+		rs[i] = NewRecipient{Address: fmt.Sprintf("u%d@x.com", i)}
+	}
+	rs[recipientChunk] = NewRecipient{Address: `a"b@x.com`, Name: "Ann Lee", Vars: map[string]any{"first_name": "Ann"}}
+
+	query := `(?s)WITH r AS \(.*INSERT INTO recipients.*unnest\(\$2::text\[\], \$3::text\[\], \$4::text\[\]\).*ON CONFLICT \(campaign_id, address\) DO NOTHING.*INSERT INTO messages.*FROM suppressions s`
 	mock.ExpectBegin()
-	mock.ExpectQuery(regexp.QuoteMeta(`
-		INSERT INTO recipients (campaign_id, address)
-		VALUES ($1,$2) RETURNING id
-	`)).
-		WithArgs(int64(7), "a@x.com").
-		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(101))
-	mock.ExpectExec(regexp.QuoteMeta(`
-		INSERT INTO messages (campaign_id, recipient_id, status)
-		VALUES ($1,$2,'pending')
-	`)).
-		WithArgs(int64(7), int64(101)).
+	mock.ExpectQuery(query).
+		WithArgs(int64(7), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "suppressed"}).AddRow(recipientChunk, 3))
+	mock.ExpectQuery(query).
+		WithArgs(int64(7), `{"a\"b@x.com","u5001@x.com"}`, `{"{\"first_name\":\"Ann\"}","{}"}`, `{"Ann Lee",""}`).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "suppressed"}).AddRow(2, 1))
+	mock.ExpectCommit()
+
+	var n, suppressed int
+	err = s.WithTx(ctx, func(tx *sql.Tx) error {
+		var e error
+		n, suppressed, e = s.InsertRecipients(ctx, tx, 7, rs)
+		return e
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(rs) || suppressed != 4 {
+		t.Fatalf("want %d inserted and 4 suppressed, got %d and %d", len(rs), n, suppressed)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEnqueuePendingJobs(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+
+	s := New(db)
+	ctx := context.Background()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`(?s)UPDATE messages.*status='pending'.*queued_at IS NULL OR queued_at <= NOW\(\).*INSERT INTO outbox.*jsonb_build_object`).
+		WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	mock.ExpectCommit()
+
+	var n int
+	err = s.WithTx(ctx, func(tx *sql.Tx) error {
+		var e error
+		n, e = s.EnqueuePendingJobs(ctx, tx, 7)
+		return e
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Fatalf("want 3 jobs, got %d", n)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClaimDueCampaign_SkipLocked(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+
+	s := New(db)
+	ctx := context.Background()
+	now := time.Date(2025, 10, 2, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`(?s)FROM campaigns.*status='queued' AND scheduled_at <= \$1.*FOR UPDATE SKIP LOCKED`).
+		WithArgs(now).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+	mock.ExpectExec(`(?s)UPDATE campaigns.*WHERE id=\$1 AND status = ANY\(\$3\)`).
+		WithArgs(int64(9), "processing", `{"paused","queued"}`, false).
 		WillReturnResult(sqlmock.NewResult(0, 1))
 	mock.ExpectCommit()
 
 	err = s.WithTx(ctx, func(tx *sql.Tx) error {
-		rid, e := s.InsertRecipient(ctx, tx, 7, "a@x.com")
+		id, e := s.ClaimDueCampaign(ctx, tx, now)
 		if e != nil {
 			return e
 		}
-		return s.InsertMessagePending(ctx, tx, 7, rid)
+		return s.SetCampaignStatus(ctx, tx, id, campaign.StatusProcessing)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSetCampaignStatus_RejectsIllegalTransition(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+
+	s := New(db)
+	ctx := context.Background()
+
+	// в importing не ведёт ни один переход campaign.transitions, в БД не ходим
+	if err := s.SetCampaignStatus(ctx, db, 1, campaign.StatusImporting); !errors.Is(err, campaign.ErrInvalidTransition) {
+		t.Fatalf("want ErrInvalidTransition, got %v", err)
+	}
+
+	// кампания уже не в processing — UPDATE ничего не затронул
+	mock.ExpectExec(`(?s)UPDATE campaigns.*status = ANY\(\$3\)`).
+		WithArgs(int64(1), "done", `{"processing"}`, true).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	if err := s.SetCampaignStatus(ctx, db, 1, campaign.StatusDone); !errors.Is(err, campaign.ErrInvalidTransition) {
+		t.Fatalf("want ErrInvalidTransition, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestListAttempts(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+
+	s := New(db)
+	ctx := context.Background()
+	started := time.Date(2025, 10, 2, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`SELECT EXISTS`).
+		WithArgs(int64(900), int64(5)).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(`(?s)FROM message_attempts.*ORDER BY started_at, id`).
+		WithArgs(int64(900)).
+		WillReturnRows(sqlmock.NewRows([]string{"attempt", "started_at", "duration_ms", "transport", "provider",
+			"response_code", "response_text", "error_class", "error"}).
+			AddRow(1, started, 1500, "smtp", "mx:25", 451, "4.3.0 later", "temporary", "smtp rcpt to: 451").
+			AddRow(2, started.Add(time.Second), 40, "smtp", "mx:25", 250, "2.0.0 ok", "", ""))
+
+	got, err := s.ListAttempts(ctx, 5, 900)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Duration != 1500*time.Millisecond || got[0].ErrorClass != "temporary" ||
+		got[1].ResponseCode != 250 {
+		t.Fatalf("unexpected attempts: %+v", got)
+	}
+
+	// сообщение другой кампании
+	mock.ExpectQuery(`SELECT EXISTS`).
+		WithArgs(int64(901), int64(5)).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	if _, err := s.ListAttempts(ctx, 5, 901); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("want sql.ErrNoRows, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClaimMessage(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+
+	s := New(db)
+	ctx := context.Background()
+
+	claim := `(?s)UPDATE messages.*SET status='sending'.*status='pending' OR \(status='sending' AND claim_expires_at < NOW\(\)\)`
+	mock.ExpectExec(claim).
+		WithArgs(int64(7), int64(101), "w1", float64(90)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(claim).
+		WithArgs(int64(7), int64(101), "w2", float64(90)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if ok, err := s.ClaimMessage(ctx, db, 7, 101, "w1", 90*time.Second); err != nil || !ok {
+		t.Fatalf("first claim: ok=%v err=%v", ok, err)
+	}
+	if ok, err := s.ClaimMessage(ctx, db, 7, 101, "w2", 90*time.Second); err != nil || ok {
+		t.Fatalf("second claim must fail: ok=%v err=%v", ok, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRequeueStuckMessages(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+
+	s := New(db)
+
+	mock.ExpectQuery(`(?s)WITH stuck AS.*c.status='processing'.*FOR UPDATE OF m SKIP LOCKED.*UPDATE messages m.*INSERT INTO outbox`).
+		WithArgs(float64(600), 50).
+		WillReturnRows(sqlmock.NewRows([]string{"pending", "sending"}).AddRow(4, 1))
+
+	got, err := s.RequeueStuckMessages(context.Background(), 10*time.Minute, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != (Requeued{Pending: 4, Sending: 1}) {
+		t.Fatalf("unexpected result: %+v", got)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCreateImport_OnlyForImportingCampaign(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+
+	s := New(db)
+
+	mock.ExpectQuery(`(?s)INSERT INTO imports.*status='importing'.*ON CONFLICT \(campaign_id\) DO NOTHING`).
+		WithArgs(int64(7), "ndjson").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	if _, err := s.CreateImport(context.Background(), 7, "ndjson"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("want sql.ErrNoRows, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAddImportProgress(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+
+	s := New(db)
+	ctx := context.Background()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`(?s)UPDATE imports.*processed = processed \+ \$2.*errors    = errors \|\| \$5::jsonb`).
+		WithArgs(int64(3), 5000, 4998, 1, `[{"row":17,"error":"address is required"},{"row":40,"error":"duplicate of row 2"}]`, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`(?s)UPDATE imports.*errors    = errors \|\| \$5::jsonb`).
+		WithArgs(int64(3), 10, 10, 0, `[]`, 0).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err = s.WithTx(ctx, func(tx *sql.Tx) error {
+		if err := s.AddImportProgress(ctx, tx, 3, ImportProgress{
+			Processed: 5000, Accepted: 4998, Rejected: 1, Duplicates: 1,
+			Errors: []campaign.ImportRowError{{Row: 17, Error: "address is required"}, {Row: 40, Error: "duplicate of row 2"}},
+		}); err != nil {
+			return err
+		}
+		return s.AddImportProgress(ctx, tx, 3, ImportProgress{Processed: 10, Accepted: 10})
 	})
 	if err != nil {
 		t.Fatal(err)
@@ -84,3 +360,224 @@ func TestInsertRecipient_And_Message(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestFailStaleImports(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+
+	s := New(db)
+
+	mock.ExpectQuery(`(?s)UPDATE imports.*status IN \('pending','running'\).*UPDATE campaigns c.*status='canceled'.*c.status='importing'.*UPDATE messages m.*SET status='canceled'`).
+		WithArgs(float64(300)).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+
+	n, err := s.FailStaleImports(context.Background(), 5*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("want 2, got %d", n)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCancelMessages(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+
+	s := New(db)
+	mock.ExpectBegin()
+	mock.ExpectExec(`(?s)UPDATE campaigns.*SET status=\$2`).
+		WithArgs(int64(7), campaign.StatusCanceled, sqlmock.AnyArg(), true).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`(?s)UPDATE messages\s+SET status='canceled', claimed_by=NULL, claim_expires_at=NULL\s+WHERE campaign_id=\$1 AND status IN \('pending','sending'\)`).
+		WithArgs(int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectCommit()
+
+	var n int
+	err = s.WithTx(context.Background(), func(tx *sql.Tx) error {
+		if err := s.SetCampaignStatus(context.Background(), tx, 7, campaign.StatusCanceled); err != nil {
+			return err
+		}
+		var err error
+		n, err = s.CancelMessages(context.Background(), tx, 7)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Fatalf("want 3 canceled messages, got %d", n)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUpsertSuppressions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+
+	s := New(db)
+	exp := time.Date(2026, 1, 2, 3, 4, 5, 0, time.FixedZone("MSK", 3*3600))
+
+	mock.ExpectExec(`(?s)INSERT INTO suppressions.*DISTINCT ON \(t.address\).*ORDER BY t.address, t.n DESC.*ON CONFLICT \(address\) DO UPDATE`).
+		WithArgs(`{"a@x.com","b@x.com"}`, `{"manual","unsubscribe"}`, `{"api",""}`, `{"","2026-01-02T00:04:05Z"}`).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	n, err := s.UpsertSuppressions(context.Background(), db, []Suppression{
+		{Address: "a@x.com", Reason: campaign.SuppressionManual, Source: "api"},
+		{Address: "b@x.com", Reason: campaign.SuppressionUnsubscribe, ExpiresAt: &exp},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("want 2 upserted, got %d", n)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestListSuppressions_Keyset(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+
+	s := New(db)
+	created := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`(?s)FROM suppressions.*WHERE address > \$1 AND \(\$2 = '' OR reason = \$2\).*ORDER BY address.*LIMIT \$3`).
+		WithArgs("a@x.com", "complaint", 100).
+		WillReturnRows(sqlmock.NewRows([]string{"address", "reason", "source", "created_at", "expires_at"}).
+			AddRow("b@x.com", "complaint", "arf", created, nil))
+
+	got, err := s.ListSuppressions(context.Background(), SuppressionQuery{Reason: "complaint", After: "a@x.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Address != "b@x.com" || got[0].Source != "arf" || got[0].ExpiresAt != nil {
+		t.Fatalf("unexpected suppressions: %+v", got)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRecordBounce(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+
+	s := New(db)
+	mock.ExpectQuery(`(?s)INSERT INTO message_events.*ON CONFLICT \(message_id, report_id\) DO NOTHING.*SET status='bounced'.*INSERT INTO suppressions.*n >= \$6`).
+		WithArgs(int64(7), int64(101), campaign.EventHardBounce, "5.1.1", "550 user unknown", 2, "r1@mx.example.net").
+		WillReturnRows(sqlmock.NewRows([]string{"address", "n", "suppressed", "duplicate"}).AddRow("ann@example.org", 2, true, false))
+
+	b, err := s.RecordBounce(context.Background(), MessageEvent{
+		CampaignID: 7, RecipientID: 101, Type: campaign.EventHardBounce, Status: "5.1.1", Detail: "550 user unknown",
+		ReportID: "r1@mx.example.net",
+	}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b != (BounceResult{Address: "ann@example.org", HardBounces: 2, Suppressed: true}) {
+		t.Fatalf("unexpected result: %+v", b)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRecordComplaint(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+
+	s := New(db)
+	mock.ExpectQuery(`(?s)INSERT INTO message_events.*'complained'.*INSERT INTO suppressions.*'complaint', 'fbl'`).
+		WithArgs(int64(7), int64(101), "abuse", "Yahoo!-Mail-Feedback/2.0").
+		WillReturnRows(sqlmock.NewRows([]string{"address"}).AddRow("ann@example.org"))
+
+	addr, err := s.RecordComplaint(context.Background(), 7, 101, "abuse", "Yahoo!-Mail-Feedback/2.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr != "ann@example.org" {
+		t.Fatalf("unexpected address %q", addr)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRecordOpens(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+
+	s := New(db)
+	first := time.Date(2025, 10, 2, 12, 0, 0, 0, time.UTC)
+	mock.ExpectExec(`(?s)UPDATE messages m.*open_count = m.open_count \+ t.n.*COALESCE\(m.first_opened_at.*GREATEST\(m.last_opened_at.*unnest`).
+		WithArgs("{7,7}", "{101,102}", "{3,1}",
+			`{"2025-10-02T12:00:00Z","2025-10-02T12:00:00Z"}`, `{"2025-10-02T12:05:00Z","2025-10-02T12:00:00Z"}`).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	err = s.RecordOpens(context.Background(), []MessageOpen{
+		{CampaignID: 7, RecipientID: 101, Count: 3, First: first, Last: first.Add(5 * time.Minute)},
+		{CampaignID: 7, RecipientID: 102, Count: 1, First: first, Last: first},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetCampaignStats_JoinsEventsOnce(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+
+	// события сворачиваются одним подзапросом по кампании, а не на каждое сообщение
+	mock.ExpectQuery(`(?s)COALESCE\(SUM\(ev.soft_bounces\), 0\).*FILTER \(WHERE ev.complained\).*FROM messages\s+LEFT JOIN \(.*FROM message_events e.*em.campaign_id = \$1.*GROUP BY e.message_id\s+\) ev ON ev.message_id = messages.id\s+WHERE campaign_id = \$1`).
+		WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"total", "pending", "sent", "failed", "suppressed", "bounced", "canceled",
+			"soft_bounces", "complaints", "opens", "opened", "fp", "ft", "fr", "fb"}).
+			AddRow(4, 0, 2, 1, 0, 1, 0, 3, 1, 5, 2, 1, 0, 0, 0))
+
+	st, err := New(db).GetCampaignStats(context.Background(), 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.SoftBounces != 3 || st.Complaints != 1 || st.Delivered() != 3 {
+		t.Fatalf("unexpected stats: %+v", st)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
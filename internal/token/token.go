@@ -0,0 +1,137 @@
+// Package token подписывает ссылки и адреса из писем: отписка, адрес
+// возврата, Message-ID и подобные им обращения получателя к API без логина. Токен —
+// вид токена, id кампании и получателя и усечённый HMAC-SHA256 над ними,
+// поэтому подделать или перенести токен на другого получателя нельзя, а
+// хранить выданные токены не нужно.
+package token
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"strings"
+)
+
+// Kind отделяет токены разных ссылок: токен отписки не подойдёт к другой ссылке.
+type Kind byte
+
+const (
+	KindUnsubscribe Kind = 'u'
+	KindBounce      Kind = 'b'
+	KindMessageID   Kind = 'm'
+	KindOpen        Kind = 'o'
+)
+
+const macLen = 16
+
+var ErrInvalid = errors.New("token: invalid or tampered token")
+
+// lowerBase32 переживает смену регистра: серверы вправе менять регистр
+// локальной части адреса.
+var lowerBase32 = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+type Signer struct {
+	key []byte
+}
+
+// NewSigner возвращает nil для пустого секрета: ссылки выключены.
+func NewSigner(secret string) *Signer {
+	if secret == "" {
+		return nil
+	}
+	return &Signer{key: []byte(secret)}
+}
+
+// Sign возвращает токен для URL.
+func (s *Signer) Sign(kind Kind, campaignID, recipientID int64) string {
+	return base64.RawURLEncoding.EncodeToString(s.sign(kind, campaignID, recipientID))
+}
+
+// Verify проверяет подпись и вид токена и возвращает зашитые в него id.
+func (s *Signer) Verify(kind Kind, tok string) (campaignID, recipientID int64, err error) {
+	buf, err := base64.RawURLEncoding.DecodeString(tok)
+	if err != nil {
+		return 0, 0, ErrInvalid
+	}
+	return s.verify(kind, buf)
+}
+
+// VERP возвращает адрес возврата local+токен@domain для MAIL FROM письма
+// получателю; bounce — базовый адрес local@domain.
+func (s *Signer) VERP(bounce string, campaignID, recipientID int64) string {
+	at := strings.LastIndexByte(bounce, '@')
+	return bounce[:at] + "+" + s.signLower(KindBounce, campaignID, recipientID) + bounce[at:]
+}
+
+// ParseVERP достаёт id из адреса, собранного VERP.
+func (s *Signer) ParseVERP(addr string) (campaignID, recipientID int64, err error) {
+	at := strings.LastIndexByte(addr, '@')
+	plus := strings.LastIndexByte(addr[:max(at, 0)], '+')
+	if plus < 0 {
+		return 0, 0, ErrInvalid
+	}
+	return s.verifyLower(KindBounce, addr[plus+1:at])
+}
+
+// MessageID возвращает Message-ID письма получателю (без угловых скобок),
+// по которому письмо узнаётся в жалобах и отчётах, вернувших его заголовки.
+func (s *Signer) MessageID(domain string, campaignID, recipientID int64) string {
+	return s.signLower(KindMessageID, campaignID, recipientID) + "@" + domain
+}
+
+// ParseMessageID достаёт id из Message-ID, в скобках или без.
+func (s *Signer) ParseMessageID(id string) (campaignID, recipientID int64, err error) {
+	id = strings.Trim(strings.TrimSpace(id), "<>")
+	at := strings.LastIndexByte(id, '@')
+	if at < 0 {
+		return 0, 0, ErrInvalid
+	}
+	return s.verifyLower(KindMessageID, id[:at])
+}
+
+func (s *Signer) signLower(kind Kind, campaignID, recipientID int64) string {
+	return lowerBase32.EncodeToString(s.sign(kind, campaignID, recipientID))
+}
+
+func (s *Signer) verifyLower(kind Kind, tok string) (campaignID, recipientID int64, err error) {
+	buf, err := lowerBase32.DecodeString(strings.ToLower(tok))
+	if err != nil {
+		return 0, 0, ErrInvalid
+	}
+	return s.verify(kind, buf)
+}
+
+func (s *Signer) sign(kind Kind, campaignID, recipientID int64) []byte {
+	buf := []byte{byte(kind)}
+	buf = binary.AppendUvarint(buf, uint64(campaignID))
+	buf = binary.AppendUvarint(buf, uint64(recipientID))
+	return append(buf, s.mac(buf)...)
+}
+
+func (s *Signer) verify(kind Kind, buf []byte) (campaignID, recipientID int64, err error) {
+	if len(buf) < 1+2+macLen {
+		return 0, 0, ErrInvalid
+	}
+	payload := buf[:len(buf)-macLen]
+	if !hmac.Equal(buf[len(payload):], s.mac(payload)) || Kind(payload[0]) != kind {
+		return 0, 0, ErrInvalid
+	}
+	c, n := binary.Uvarint(payload[1:])
+	if n <= 0 {
+		return 0, 0, ErrInvalid
+	}
+	r, m := binary.Uvarint(payload[1+n:])
+	if m <= 0 || 1+n+m != len(payload) {
+		return 0, 0, ErrInvalid
+	}
+	return int64(c), int64(r), nil
+}
+
+func (s *Signer) mac(payload []byte) []byte {
+	m := hmac.New(sha256.New, s.key)
+	m.Write(payload)
+	return m.Sum(nil)[:macLen]
+}
@@ -0,0 +1,91 @@
+package token
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSignVerify(t *testing.T) {
+	s := NewSigner("secret")
+	tok := s.Sign(KindUnsubscribe, 42, 1<<40)
+	if strings.ContainsAny(tok, "+/=") {
+		t.Fatalf("token is not URL-safe: %q", tok)
+	}
+	c, r, err := s.Verify(KindUnsubscribe, tok)
+	if err != nil || c != 42 || r != 1<<40 {
+		t.Fatalf("Verify = %d, %d, %v", c, r, err)
+	}
+}
+
+func TestVerify_Rejects(t *testing.T) {
+	s := NewSigner("secret")
+	tok := s.Sign(KindUnsubscribe, 1, 2)
+	other := NewSigner("other").Sign(KindUnsubscribe, 1, 2)
+
+	tampered := []byte(tok)
+	tampered[3] ^= 1
+
+	for name, tc := range map[string]struct {
+		kind Kind
+		tok  string
+	}{
+		"other key":  {KindUnsubscribe, other},
+		"tampered":   {KindUnsubscribe, string(tampered)},
+		"truncated":  {KindUnsubscribe, tok[:len(tok)-2]},
+		"garbage":    {KindUnsubscribe, "not a token!"},
+		"empty":      {KindUnsubscribe, ""},
+		"other kind": {Kind('x'), tok},
+	} {
+		if _, _, err := s.Verify(tc.kind, tc.tok); err != ErrInvalid {
+			t.Errorf("%s: want ErrInvalid, got %v", name, err)
+		}
+	}
+}
+
+func TestNewSigner_EmptySecret(t *testing.T) {
+	if NewSigner("") != nil {
+		t.Fatal("empty secret must disable signing")
+	}
+}
+
+func TestVERP(t *testing.T) {
+	s := NewSigner("secret")
+	addr := s.VERP("bounces@mail.example.com", 123456, 987654321)
+	at := strings.IndexByte(addr, '@')
+	if !strings.HasPrefix(addr, "bounces+") || addr[at:] != "@mail.example.com" || at > 64 {
+		t.Fatalf("unexpected VERP address %q", addr)
+	}
+
+	// регистр локальной части может поменяться по пути
+	c, r, err := s.ParseVERP(strings.ToUpper(addr[:at]) + addr[at:])
+	if err != nil || c != 123456 || r != 987654321 {
+		t.Fatalf("ParseVERP = %d, %d, %v", c, r, err)
+	}
+
+	for _, bad := range []string{"bounces@mail.example.com", "bounces+abc@mail.example.com", "nobody", ""} {
+		if _, _, err := s.ParseVERP(bad); err != ErrInvalid {
+			t.Errorf("%q: want ErrInvalid, got %v", bad, err)
+		}
+	}
+	if _, _, err := s.Verify(KindUnsubscribe, s.Sign(KindBounce, 1, 2)); err != ErrInvalid {
+		t.Fatalf("bounce token must not verify as unsubscribe: %v", err)
+	}
+}
+
+func TestMessageID(t *testing.T) {
+	s := NewSigner("secret")
+	id := s.MessageID("example.com", 7, 101)
+	if !strings.HasSuffix(id, "@example.com") {
+		t.Fatalf("unexpected Message-ID %q", id)
+	}
+	c, r, err := s.ParseMessageID(" <" + id + "> ")
+	if err != nil || c != 7 || r != 101 {
+		t.Fatalf("ParseMessageID = %d, %d, %v", c, r, err)
+	}
+	verp := s.VERP("b@example.com", 7, 101)
+	for _, bad := range []string{"0011aabb@example.com", "no-at", verp} {
+		if _, _, err := s.ParseMessageID(bad); err != ErrInvalid {
+			t.Errorf("%q: want ErrInvalid, got %v", bad, err)
+		}
+	}
+}
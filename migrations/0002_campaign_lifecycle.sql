@@ -0,0 +1,4 @@
+ALTER TABLE campaigns
+    ADD COLUMN IF NOT EXISTS finished_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_campaigns_status ON campaigns (status);
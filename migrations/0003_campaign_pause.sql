@@ -0,0 +1,3 @@
+ALTER TABLE campaigns DROP CONSTRAINT IF EXISTS campaigns_status_chk;
+ALTER TABLE campaigns ADD CONSTRAINT campaigns_status_chk
+  CHECK (status IN ('queued','processing','paused','done','failed','canceled'));
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS outbox (
+    id            BIGSERIAL PRIMARY KEY,
+    payload       JSONB       NOT NULL,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    dispatched_at TIMESTAMPTZ,
+    attempts      INT         NOT NULL DEFAULT 0,
+    last_error    TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_undispatched
+  ON outbox (id) WHERE dispatched_at IS NULL;
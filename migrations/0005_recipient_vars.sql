@@ -0,0 +1,2 @@
+ALTER TABLE recipients
+    ADD COLUMN IF NOT EXISTS vars JSONB NOT NULL DEFAULT '{}'::jsonb;
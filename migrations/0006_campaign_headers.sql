@@ -0,0 +1,6 @@
+ALTER TABLE campaigns
+    ADD COLUMN IF NOT EXISTS subject      TEXT  NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS from_name    TEXT  NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS from_address TEXT  NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS reply_to     TEXT  NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS headers      JSONB NOT NULL DEFAULT '{}'::jsonb;
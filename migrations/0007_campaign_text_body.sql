@@ -0,0 +1,2 @@
+ALTER TABLE campaigns
+    ADD COLUMN IF NOT EXISTS text_body TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,6 @@
+ALTER TABLE messages
+    ADD COLUMN IF NOT EXISTS error_class TEXT;
+
+ALTER TABLE messages DROP CONSTRAINT IF EXISTS messages_error_class_chk;
+ALTER TABLE messages ADD CONSTRAINT messages_error_class_chk
+  CHECK (error_class IN ('temporary','permanent','rate_limited','policy_blocked'));
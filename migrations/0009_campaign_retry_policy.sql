@@ -0,0 +1,4 @@
+-- значения совпадают с прежними зашитыми в воркер: 4 попытки, паузы 1s, 2s, 4s
+ALTER TABLE campaigns
+    ADD COLUMN IF NOT EXISTS retry_policy JSONB NOT NULL
+    DEFAULT '{"max_attempts":4,"base_delay":"1s","max_delay":"5m0s","jitter":0,"deadline":"24h0m0s"}'::jsonb;
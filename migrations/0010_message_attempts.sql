@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS message_attempts (
+    id            BIGSERIAL PRIMARY KEY,
+    message_id    BIGINT      NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    attempt       INT         NOT NULL,
+    started_at    TIMESTAMPTZ NOT NULL,
+    duration_ms   BIGINT      NOT NULL,
+    transport     TEXT        NOT NULL DEFAULT '',
+    provider      TEXT        NOT NULL DEFAULT '',
+    response_code INT,
+    response_text TEXT        NOT NULL DEFAULT '',
+    error_class   TEXT,
+    error         TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_message_attempts_message
+  ON message_attempts (message_id, attempt);
@@ -0,0 +1,11 @@
+-- sending: воркер взял сообщение в отправку и держит его до claim_expires_at
+ALTER TABLE messages
+    ADD COLUMN IF NOT EXISTS claimed_by       TEXT,
+    ADD COLUMN IF NOT EXISTS claim_expires_at TIMESTAMPTZ;
+
+ALTER TABLE messages DROP CONSTRAINT IF EXISTS messages_status_chk;
+ALTER TABLE messages ADD CONSTRAINT messages_status_chk
+  CHECK (status IN ('pending','sending','sent','failed'));
+
+CREATE INDEX IF NOT EXISTS idx_messages_claim_expires
+  ON messages (claim_expires_at) WHERE status='sending';
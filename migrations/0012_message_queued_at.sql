@@ -0,0 +1,13 @@
+-- queued_at — когда задание сообщения должно оказаться в очереди; по нему
+-- reconciler находит pending-сообщения, задание которых потерялось
+ALTER TABLE messages
+    ADD COLUMN IF NOT EXISTS queued_at TIMESTAMPTZ;
+
+UPDATE messages m
+   SET queued_at = NOW()
+  FROM campaigns c
+ WHERE c.id = m.campaign_id AND c.status IN ('processing','paused') AND m.status='pending'
+   AND m.queued_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_messages_queued_at
+  ON messages (queued_at) WHERE status='pending';
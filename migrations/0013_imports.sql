@@ -0,0 +1,24 @@
+-- importing — кампания ждёт, пока асинхронный импорт загрузит получателей
+ALTER TABLE campaigns DROP CONSTRAINT IF EXISTS campaigns_status_chk;
+ALTER TABLE campaigns ADD CONSTRAINT campaigns_status_chk
+  CHECK (status IN ('importing','queued','processing','paused','done','failed','canceled'));
+
+CREATE TABLE IF NOT EXISTS imports (
+    id          BIGSERIAL PRIMARY KEY,
+    campaign_id BIGINT      NOT NULL UNIQUE REFERENCES campaigns(id) ON DELETE CASCADE,
+    format      TEXT        NOT NULL,
+    status      TEXT        NOT NULL DEFAULT 'pending',
+    processed   INT         NOT NULL DEFAULT 0,
+    accepted    INT         NOT NULL DEFAULT 0,
+    rejected    INT         NOT NULL DEFAULT 0,
+    errors      JSONB       NOT NULL DEFAULT '[]',
+    error       TEXT,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMPTZ,
+    CONSTRAINT imports_status_chk
+      CHECK (status IN ('pending','running','completed','failed'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_imports_active
+  ON imports (updated_at) WHERE status IN ('pending','running');
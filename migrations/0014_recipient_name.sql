@@ -0,0 +1,3 @@
+-- name — отображаемое имя получателя для заголовка To
+ALTER TABLE recipients
+    ADD COLUMN IF NOT EXISTS name TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,17 @@
+-- suppressions — адреса, на которые нельзя слать ни из одной кампании.
+-- address хранится нормализованным и в нижнем регистре; запись без
+-- expires_at действует бессрочно.
+CREATE TABLE IF NOT EXISTS suppressions (
+    address    TEXT        PRIMARY KEY,
+    reason     TEXT        NOT NULL,
+    source     TEXT        NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ,
+    CONSTRAINT suppressions_reason_chk
+      CHECK (reason IN ('hard_bounce','complaint','unsubscribe','manual'))
+);
+
+-- suppressed: получатель был в списке блокировки при создании кампании или перед отправкой
+ALTER TABLE messages DROP CONSTRAINT IF EXISTS messages_status_chk;
+ALTER TABLE messages ADD CONSTRAINT messages_status_chk
+  CHECK (status IN ('pending','sending','sent','failed','suppressed'));
@@ -0,0 +1,22 @@
+-- message_events — что стало с письмом после отправки: отчёты о недоставке
+-- и подобные им события. address — адрес получателя в нижнем регистре, по
+-- нему считаются повторные отказы.
+CREATE TABLE IF NOT EXISTS message_events (
+    id         BIGSERIAL   PRIMARY KEY,
+    message_id BIGINT      NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    type       TEXT        NOT NULL,
+    address    TEXT        NOT NULL,
+    status     TEXT        NOT NULL DEFAULT '',
+    detail     TEXT        NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT message_events_type_chk
+      CHECK (type IN ('hard_bounce','soft_bounce'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_message_events_message ON message_events (message_id, type);
+CREATE INDEX IF NOT EXISTS idx_message_events_address ON message_events (address, type);
+
+-- bounced: письмо принято сервером, но позже вернулось с постоянным отказом
+ALTER TABLE messages DROP CONSTRAINT IF EXISTS messages_status_chk;
+ALTER TABLE messages ADD CONSTRAINT messages_status_chk
+  CHECK (status IN ('pending','sending','sent','failed','suppressed','bounced'));
@@ -0,0 +1,5 @@
+-- complained: получатель пожаловался на письмо через feedback loop
+-- почтового провайдера (ARF); статус сообщения при этом не меняется.
+ALTER TABLE message_events DROP CONSTRAINT IF EXISTS message_events_type_chk;
+ALTER TABLE message_events ADD CONSTRAINT message_events_type_chk
+  CHECK (type IN ('hard_bounce','soft_bounce','complained'));
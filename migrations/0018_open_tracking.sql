@@ -0,0 +1,7 @@
+-- track_opens: воркер вставляет в HTML-письма кампании пиксель открытия
+ALTER TABLE campaigns ADD COLUMN IF NOT EXISTS track_opens BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- открытия письма по пикселю: сколько раз, первое и последнее
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS open_count      INT NOT NULL DEFAULT 0;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS first_opened_at TIMESTAMPTZ;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS last_opened_at  TIMESTAMPTZ;
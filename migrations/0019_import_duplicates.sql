@@ -0,0 +1,3 @@
+-- duplicates — строки импорта с адресом, уже встречавшимся в этой загрузке
+ALTER TABLE imports
+    ADD COLUMN IF NOT EXISTS duplicates INT NOT NULL DEFAULT 0;
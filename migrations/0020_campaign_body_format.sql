@@ -0,0 +1,10 @@
+-- body_format — формат тела кампании, заданный при создании: text или html.
+-- Существующим кампаниям проставляем то, что раньше угадывалось по началу
+-- тела (http.DetectContentType), чтобы письма уходили как прежде.
+ALTER TABLE campaigns
+    ADD COLUMN IF NOT EXISTS body_format TEXT NOT NULL DEFAULT 'text'
+        CHECK (body_format IN ('text', 'html'));
+
+UPDATE campaigns
+   SET body_format = 'html'
+ WHERE body ~* '^[\t\n\f\r ]*<(!doctype html|html|head|script|iframe|h1|div|font|table|a|style|title|b|body|br|p|!--)[ >]';
@@ -0,0 +1,9 @@
+-- report_id — ключ отчёта, из которого записано событие (Message-ID
+-- уведомления о недоставке): повторно доставленный отчёт не учитывается
+-- второй раз. События без ключа не сравниваются (NULL в уникальном индексе
+-- различны).
+ALTER TABLE message_events
+    ADD COLUMN IF NOT EXISTS report_id TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_message_events_report
+    ON message_events (message_id, report_id);
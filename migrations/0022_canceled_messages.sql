@@ -0,0 +1,10 @@
+-- canceled: сообщение снято с отправки вместе с кампанией. Сообщения уже
+-- отменённых кампаний, застрявшие в pending/sending, закрываем сразу.
+ALTER TABLE messages DROP CONSTRAINT IF EXISTS messages_status_chk;
+ALTER TABLE messages ADD CONSTRAINT messages_status_chk
+  CHECK (status IN ('pending','sending','sent','failed','suppressed','bounced','canceled'));
+
+UPDATE messages m
+   SET status='canceled', claimed_by=NULL, claim_expires_at=NULL
+  FROM campaigns c
+ WHERE c.id = m.campaign_id AND c.status='canceled' AND m.status IN ('pending','sending');
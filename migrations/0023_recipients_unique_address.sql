@@ -0,0 +1,14 @@
+-- Адрес получателя уникален в пределах кампании: повтор отсекает сама
+-- вставка (ON CONFLICT DO NOTHING), и импорт не держит в памяти все адреса
+-- загрузки. Адреса хранятся нормализованными (домен в нижнем регистре,
+-- локальная часть как есть), поэтому индекс по address совпадает с
+-- дедупликацией POST /campaigns. Повторы, записанные до индекса, удаляем
+-- вместе с их сообщениями; остаётся первая запись адреса.
+DELETE FROM recipients r
+ USING recipients first
+ WHERE first.campaign_id = r.campaign_id
+   AND first.address = r.address
+   AND first.id < r.id;
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_recipients_campaign_address
+    ON recipients (campaign_id, address);
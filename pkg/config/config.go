@@ -1,8 +1,12 @@
 package config
 
 import (
+	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type APIConfig struct {
@@ -10,12 +14,84 @@ type APIConfig struct {
 	DBDSN  string
 	RMQURL string
 	Queue  string
+
+	SchedulerInterval time.Duration
+	OutboxInterval    time.Duration
+
+	// Import* — асинхронная загрузка получателей: каталог временных файлов,
+	// предел размера загрузки, число одновременных импортов и через сколько
+	// замерший импорт считается прерванным.
+	ImportDir         string
+	ImportMaxBytes    int64
+	ImportConcurrency int
+	ImportStaleAfter  time.Duration
+
+	// Retry — политика повторов для кампаний, которые не задали свою.
+	Retry RetryConfig
+
+	// LinkSecret — ключ подписи ссылок отписки и адресов возврата; пусто —
+	// ссылки и приём отказов выключены.
+	LinkSecret string
+	// BounceSuppressAfter — после скольких постоянных отказов адрес блокируется.
+	BounceSuppressAfter int
+	// OpenFlushInterval — как часто открытия по пикселю пишутся в базу.
+	OpenFlushInterval time.Duration
 }
 
 type WorkerConfig struct {
 	DBDSN  string
 	RMQURL string
 	Queue  string
+
+	Transport string
+	MailFrom  string
+	SMTP      SMTPConfig
+
+	FinalizeInterval time.Duration
+	FailureThreshold float64
+
+	ReconcileInterval time.Duration
+	StuckAfter        time.Duration
+
+	Concurrency  int
+	Prefetch     int
+	DrainTimeout time.Duration
+
+	// WorkerID — владелец взятых в отправку сообщений, ClaimLease — срок заявки.
+	WorkerID   string
+	ClaimLease time.Duration
+
+	// Retry применяется к кампаниям, созданным до появления retry_policy.
+	Retry RetryConfig
+
+	// LinkSecret и PublicURL — ключ подписи и внешний адрес campaign-api для
+	// ссылок отписки; без любого из них письма уходят без List-Unsubscribe.
+	LinkSecret string
+	PublicURL  string
+	// BounceAddress — базовый адрес возврата для VERP; пусто — MAIL FROM
+	// совпадает с отправителем письма.
+	BounceAddress string
+}
+
+// RetryConfig повторяет поля campaign.RetryPolicy и приводится к нему напрямую.
+type RetryConfig struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	Jitter      float64
+	Deadline    time.Duration
+}
+
+type SMTPConfig struct {
+	Host               string
+	Port               int
+	Username           string
+	Password           string
+	Auth               string
+	TLSMode            string
+	HelloName          string
+	Timeout            time.Duration
+	InsecureSkipVerify bool
 }
 
 var (
@@ -30,6 +106,54 @@ func getenv(k, def string) string {
 	return def
 }
 
+func getenvInt(k string, def int) int {
+	v := os.Getenv(k)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Fatalf("env %s: invalid integer %q", k, v)
+	}
+	return n
+}
+
+func getenvBool(k string, def bool) bool {
+	v := os.Getenv(k)
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		log.Fatalf("env %s: invalid boolean %q", k, v)
+	}
+	return b
+}
+
+func getenvFloat(k string, def float64) float64 {
+	v := os.Getenv(k)
+	if v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		log.Fatalf("env %s: invalid number %q", k, v)
+	}
+	return f
+}
+
+func getenvDuration(k string, def time.Duration) time.Duration {
+	v := os.Getenv(k)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Fatalf("env %s: invalid duration %q", k, v)
+	}
+	return d
+}
+
 func mustEnv(k string) string {
 	v := os.Getenv(k)
 	if v == "" {
@@ -44,6 +168,20 @@ func MustLoadAPI() {
 		DBDSN:  mustEnv("DB_DSN"),
 		RMQURL: mustEnv("RMQ_URL"),
 		Queue:  getenv("QUEUE", "send_jobs"),
+
+		SchedulerInterval: getenvDuration("SCHEDULER_INTERVAL", time.Second),
+		OutboxInterval:    getenvDuration("OUTBOX_INTERVAL", 500*time.Millisecond),
+
+		ImportDir:         getenv("IMPORT_DIR", os.TempDir()),
+		ImportMaxBytes:    int64(getenvInt("IMPORT_MAX_BYTES", 1<<30)),
+		ImportConcurrency: getenvInt("IMPORT_CONCURRENCY", 2),
+		ImportStaleAfter:  getenvDuration("IMPORT_STALE_AFTER", 5*time.Minute),
+
+		Retry: loadRetry(),
+
+		LinkSecret:          os.Getenv("LINK_SECRET"),
+		BounceSuppressAfter: getenvInt("BOUNCE_SUPPRESS_AFTER", 2),
+		OpenFlushInterval:   getenvDuration("OPEN_FLUSH_INTERVAL", 5*time.Second),
 	}
 }
 
@@ -52,5 +190,63 @@ func MustLoadWorker() {
 		DBDSN:  mustEnv("DB_DSN"),
 		RMQURL: mustEnv("RMQ_URL"),
 		Queue:  getenv("QUEUE", "send_jobs"),
+
+		Transport: getenv("MAIL_TRANSPORT", "smtp"),
+		MailFrom:  mustEnv("MAIL_FROM"),
+		SMTP: SMTPConfig{
+			Host:               getenv("SMTP_HOST", "localhost"),
+			Port:               getenvInt("SMTP_PORT", 587),
+			Username:           os.Getenv("SMTP_USERNAME"),
+			Password:           os.Getenv("SMTP_PASSWORD"),
+			Auth:               os.Getenv("SMTP_AUTH"),
+			TLSMode:            getenv("SMTP_TLS", "starttls"),
+			HelloName:          os.Getenv("SMTP_HELO"),
+			Timeout:            getenvDuration("SMTP_TIMEOUT", 30*time.Second),
+			InsecureSkipVerify: getenvBool("SMTP_INSECURE_SKIP_VERIFY", false),
+		},
+
+		FinalizeInterval: getenvDuration("FINALIZE_INTERVAL", 5*time.Second),
+		FailureThreshold: getenvFloat("CAMPAIGN_FAILURE_THRESHOLD", 0.5),
+
+		ReconcileInterval: getenvDuration("RECONCILE_INTERVAL", 30*time.Second),
+		StuckAfter:        getenvDuration("RECONCILE_STUCK_AFTER", 15*time.Minute),
+
+		Concurrency:  getenvInt("WORKER_CONCURRENCY", 10),
+		DrainTimeout: getenvDuration("WORKER_DRAIN_TIMEOUT", 30*time.Second),
+
+		WorkerID:   getenv("WORKER_ID", defaultWorkerID()),
+		ClaimLease: getenvDuration("WORKER_CLAIM_LEASE", 2*time.Minute),
+
+		Retry: loadRetry(),
+
+		LinkSecret: os.Getenv("LINK_SECRET"),
+		PublicURL:  strings.TrimRight(os.Getenv("PUBLIC_URL"), "/"),
+
+		BounceAddress: os.Getenv("BOUNCE_ADDRESS"),
+	}
+	if Worker.Concurrency < 1 {
+		Worker.Concurrency = 1
+	}
+	// prefetch по умолчанию равен числу обработчиков: брокер не держит
+	// за воркером больше доставок, чем тот может обрабатывать одновременно
+	Worker.Prefetch = getenvInt("WORKER_PREFETCH", Worker.Concurrency)
+}
+
+// defaultWorkerID различает воркеры на одном хосте и перезапуски одного воркера.
+func defaultWorkerID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "worker"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+func loadRetry() RetryConfig {
+	return RetryConfig{
+		MaxAttempts: getenvInt("RETRY_MAX_ATTEMPTS", 4),
+		BaseDelay:   getenvDuration("RETRY_BASE_DELAY", time.Second),
+		MaxDelay:    getenvDuration("RETRY_MAX_DELAY", 5*time.Minute),
+		Jitter:      getenvFloat("RETRY_JITTER", 0),
+		Deadline:    getenvDuration("RETRY_DEADLINE", 24*time.Hour),
 	}
 }
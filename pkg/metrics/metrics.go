@@ -23,6 +23,21 @@ var (
 	PublishedJobsTotal = prometheus.NewCounter(
 		prometheus.CounterOpts{Name: "api_published_jobs_total", Help: "Jobs published to queue"},
 	)
+	OutboxPublishErrors = prometheus.NewCounter(
+		prometheus.CounterOpts{Name: "api_outbox_publish_errors_total", Help: "Failed outbox publish attempts"},
+	)
+	APIUnsubscribes = prometheus.NewCounter(
+		prometheus.CounterOpts{Name: "api_unsubscribes_total", Help: "Recipients unsubscribed via signed links"},
+	)
+	APIComplaints = prometheus.NewCounter(
+		prometheus.CounterOpts{Name: "api_complaints_total", Help: "Spam complaints received via feedback loops"},
+	)
+	APIOpens = prometheus.NewCounter(
+		prometheus.CounterOpts{Name: "api_opens_total", Help: "Tracking pixel opens"},
+	)
+	APIOpensDropped = prometheus.NewCounter(
+		prometheus.CounterOpts{Name: "api_opens_dropped_total", Help: "Opens dropped because the pending buffer was full"},
+	)
 
 	WorkerJobsConsumed = prometheus.NewCounter(
 		prometheus.CounterOpts{Name: "worker_jobs_consumed_total", Help: "Jobs consumed"},
@@ -33,9 +48,22 @@ var (
 	WorkerJobsFailed = prometheus.NewCounter(
 		prometheus.CounterOpts{Name: "worker_jobs_failed_total", Help: "Jobs failed"},
 	)
+	WorkerJobsSuppressed = prometheus.NewCounter(
+		prometheus.CounterOpts{Name: "worker_jobs_suppressed_total", Help: "Jobs skipped because the address is suppressed"},
+	)
 	WorkerJobRetries = prometheus.NewCounter(
 		prometheus.CounterOpts{Name: "worker_job_retries_total", Help: "Retries performed"},
 	)
+	WorkerJobsDeadLettered = prometheus.NewCounter(
+		prometheus.CounterOpts{Name: "worker_jobs_dead_lettered_total", Help: "Jobs moved to the dead-letter queue"},
+	)
+	WorkerJobsInFlight = prometheus.NewGauge(
+		prometheus.GaugeOpts{Name: "worker_jobs_in_flight", Help: "Jobs being processed right now"},
+	)
+	WorkerMessagesReconciled = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "worker_messages_reconciled_total", Help: "Stuck messages requeued by the reconciler"},
+		[]string{"status"},
+	)
 	WorkerProcessDuration = prometheus.NewHistogram(
 		prometheus.HistogramOpts{
 			Name:    "worker_job_process_duration_seconds",
@@ -47,8 +75,9 @@ var (
 
 func init() {
 	prometheus.MustRegister(
-		APIRequestsTotal, APIRequestDuration, PublishedJobsTotal,
-		WorkerJobsConsumed, WorkerJobsSent, WorkerJobsFailed, WorkerJobRetries, WorkerProcessDuration,
+		APIRequestsTotal, APIRequestDuration, PublishedJobsTotal, OutboxPublishErrors, APIUnsubscribes, APIComplaints, APIOpens, APIOpensDropped,
+		WorkerJobsConsumed, WorkerJobsSent, WorkerJobsFailed, WorkerJobsSuppressed, WorkerJobRetries, WorkerJobsDeadLettered, WorkerJobsInFlight,
+		WorkerMessagesReconciled, WorkerProcessDuration,
 	)
 }
 
@@ -0,0 +1,297 @@
+package rmq
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Заголовки, которые добавляются к заданию при отправке в dead-letter очередь.
+const (
+	HeaderDeadID        = "x-dead-id"
+	HeaderDeadReason    = "x-dead-reason"
+	HeaderDeadAt        = "x-dead-at"
+	HeaderOriginalQueue = "x-original-queue"
+)
+
+var ErrDeadLetterNotFound = errors.New("rmq: dead letter not found")
+
+func DeadLetterQueueName(queue string) string {
+	return queue + ".dead"
+}
+
+// PublishDead кладёт задание в dead-letter очередь, сохраняя исходные
+// заголовки и добавляя причину и время.
+func (p *Publisher) PublishDead(ctx context.Context, body []byte, headers amqp.Table, reason string) error {
+	p.mu.Lock()
+	if !p.deadDeclared {
+		if _, err := p.ch.QueueDeclare(DeadLetterQueueName(p.queue), true, false, false, false, nil); err != nil {
+			p.mu.Unlock()
+			return err
+		}
+		p.deadDeclared = true
+	}
+	p.mu.Unlock()
+
+	id, err := newDeadID()
+	if err != nil {
+		return err
+	}
+	h := amqp.Table{}
+	for k, v := range headers {
+		h[k] = v
+	}
+	h[HeaderDeadID] = id
+	h[HeaderDeadReason] = reason
+	h[HeaderDeadAt] = time.Now().UTC().Format(time.RFC3339)
+	h[HeaderOriginalQueue] = p.queue
+	return p.publish(ctx, DeadLetterQueueName(p.queue), body, h)
+}
+
+type DeadLetter struct {
+	ID            string
+	Reason        string
+	FailedAt      time.Time
+	OriginalQueue string
+	Headers       amqp.Table
+	Body          []byte
+}
+
+// DeadLetters — административный доступ к dead-letter очереди. У AMQP нет
+// просмотра без извлечения, поэтому сообщения забираются basic.get и
+// возвращаются в очередь nack'ом; операции сериализуются мьютексом.
+type DeadLetters struct {
+	conn  *amqp.Connection
+	ch    *amqp.Channel
+	queue string
+	mu    sync.Mutex
+}
+
+func NewDeadLetters(url, queue string) (*DeadLetters, error) {
+	conn, err := amqp.Dial(url)
+	if err != nil {
+		return nil, err
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	for _, q := range []string{queue, DeadLetterQueueName(queue)} {
+		if _, err := ch.QueueDeclare(q, true, false, false, false, nil); err != nil {
+			_ = ch.Close()
+			_ = conn.Close()
+			return nil, err
+		}
+	}
+
+	if err := ch.Confirm(false); err != nil {
+		_ = ch.Close()
+		_ = conn.Close()
+		return nil, err
+	}
+
+	return &DeadLetters{conn: conn, ch: ch, queue: queue}, nil
+}
+
+func (d *DeadLetters) Close() error {
+	var cerr error
+	if d.ch != nil {
+		if err := d.ch.Close(); err != nil && !errors.Is(err, amqp.ErrClosed) {
+			cerr = err
+		}
+	}
+	if d.conn != nil {
+		if err := d.conn.Close(); err != nil && !errors.Is(err, amqp.ErrClosed) && cerr == nil {
+			cerr = err
+		}
+	}
+	return cerr
+}
+
+// List возвращает до limit сообщений из головы очереди, не удаляя их.
+func (d *DeadLetters) List(limit int) ([]DeadLetter, error) {
+	var out []DeadLetter
+	err := d.scan(func(dl DeadLetter) (action, bool) {
+		out = append(out, dl)
+		return keep, len(out) < limit
+	})
+	return out, err
+}
+
+func (d *DeadLetters) Get(id string) (DeadLetter, error) {
+	var found *DeadLetter
+	err := d.scan(func(dl DeadLetter) (action, bool) {
+		if dl.ID == id {
+			found = &dl
+			return keep, false
+		}
+		return keep, true
+	})
+	if err != nil {
+		return DeadLetter{}, err
+	}
+	if found == nil {
+		return DeadLetter{}, ErrDeadLetterNotFound
+	}
+	return *found, nil
+}
+
+// Replay возвращает сообщение в основную очередь со сброшенным счётчиком ретраев.
+func (d *DeadLetters) Replay(ctx context.Context, id string) error {
+	n, err := d.replay(ctx, id)
+	if err == nil && n == 0 {
+		return ErrDeadLetterNotFound
+	}
+	return err
+}
+
+func (d *DeadLetters) ReplayAll(ctx context.Context) (int, error) {
+	return d.replay(ctx, "")
+}
+
+func (d *DeadLetters) Delete(id string) error {
+	deleted := false
+	err := d.scan(func(dl DeadLetter) (action, bool) {
+		if dl.ID == id {
+			deleted = true
+			return drop, false
+		}
+		return keep, true
+	})
+	if err == nil && !deleted {
+		return ErrDeadLetterNotFound
+	}
+	return err
+}
+
+func (d *DeadLetters) Purge() (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.ch.QueuePurge(DeadLetterQueueName(d.queue), false)
+}
+
+func (d *DeadLetters) replay(ctx context.Context, id string) (int, error) {
+	replayed := 0
+	var pubErr error
+	err := d.scan(func(dl DeadLetter) (action, bool) {
+		if id != "" && dl.ID != id {
+			return keep, true
+		}
+		if pubErr = d.republish(ctx, dl); pubErr != nil {
+			return keep, false
+		}
+		replayed++
+		return drop, id == ""
+	})
+	if err == nil {
+		err = pubErr
+	}
+	return replayed, err
+}
+
+func (d *DeadLetters) republish(ctx context.Context, dl DeadLetter) error {
+	h := amqp.Table{}
+	for k, v := range dl.Headers {
+		switch k {
+		case HeaderDeadID, HeaderDeadReason, HeaderDeadAt, HeaderOriginalQueue, "x-retries", "x-first-failure-at":
+			continue
+		}
+		h[k] = v
+	}
+	queue := dl.OriginalQueue
+	if queue == "" {
+		queue = d.queue
+	}
+
+	pubCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	dc, err := d.ch.PublishWithDeferredConfirmWithContext(pubCtx, "", queue, false, false, amqp.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent,
+		Timestamp:    time.Now(),
+		Body:         dl.Body,
+		Headers:      h,
+	})
+	if err != nil {
+		return err
+	}
+	ok, err := dc.WaitContext(pubCtx)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNacked
+	}
+	return nil
+}
+
+type action int
+
+const (
+	keep action = iota
+	drop
+)
+
+// scan забирает сообщения по одному и отдаёт их fn, пока та не вернёт
+// false или очередь не опустеет. Удалённые (drop) подтверждаются,
+// остальные в конце возвращаются в очередь.
+func (d *DeadLetters) scan(fn func(DeadLetter) (action, bool)) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var lastTag uint64
+	defer func() {
+		if lastTag > 0 {
+			_ = d.ch.Nack(lastTag, true, true)
+		}
+	}()
+
+	for {
+		msg, ok, err := d.ch.Get(DeadLetterQueueName(d.queue), false)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+
+		act, more := fn(toDeadLetter(msg))
+		if act == drop {
+			if err := msg.Ack(false); err != nil {
+				return err
+			}
+		} else {
+			lastTag = msg.DeliveryTag
+		}
+		if !more {
+			return nil
+		}
+	}
+}
+
+func toDeadLetter(msg amqp.Delivery) DeadLetter {
+	dl := DeadLetter{Headers: msg.Headers, Body: msg.Body}
+	dl.ID, _ = msg.Headers[HeaderDeadID].(string)
+	dl.Reason, _ = msg.Headers[HeaderDeadReason].(string)
+	dl.OriginalQueue, _ = msg.Headers[HeaderOriginalQueue].(string)
+	if s, ok := msg.Headers[HeaderDeadAt].(string); ok {
+		dl.FailedAt, _ = time.Parse(time.RFC3339, s)
+	}
+	return dl
+}
+
+func newDeadID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -3,6 +3,8 @@ package rmq
 import (
 	"context"
 	"errors"
+	"fmt"
+	"sync"
 	"time"
 
 	amqp "github.com/rabbitmq/amqp091-go"
@@ -12,6 +14,10 @@ type Publisher struct {
 	conn  *amqp.Connection
 	ch    *amqp.Channel
 	queue string
+
+	mu           sync.Mutex
+	retryQueues  map[time.Duration]string
+	deadDeclared bool
 }
 
 func NewPublisher(url, queue string) (*Publisher, error) {
@@ -32,7 +38,14 @@ func NewPublisher(url, queue string) (*Publisher, error) {
 		return nil, err
 	}
 
-	return &Publisher{conn: conn, ch: ch, queue: queue}, nil
+	// publisher confirms: публикация считается успешной только после ack брокера
+	if err := ch.Confirm(false); err != nil {
+		_ = ch.Close()
+		_ = conn.Close()
+		return nil, err
+	}
+
+	return &Publisher{conn: conn, ch: ch, queue: queue, retryQueues: map[time.Duration]string{}}, nil
 }
 
 func (p *Publisher) Close() error {
@@ -54,19 +67,159 @@ func (p *Publisher) PublishJSON(ctx context.Context, body []byte) error {
 	return p.PublishJSONWithHeaders(ctx, body, nil)
 }
 
+var ErrNacked = errors.New("rmq: publish nacked by broker")
+
 func (p *Publisher) PublishJSONWithHeaders(ctx context.Context, body []byte, headers amqp.Table) error {
-	return p.ch.PublishWithContext(
+	return p.publish(ctx, p.queue, body, headers)
+}
+
+// PublishBatch публикует bodies в основную очередь, не дожидаясь подтверждения
+// каждого сообщения по отдельности: сначала уходит вся пачка, затем собираются
+// ack'и брокера. Возвращает число сообщений с начала пачки, подтверждённых
+// подряд, и ошибку первого неподтверждённого.
+func (p *Publisher) PublishBatch(ctx context.Context, bodies [][]byte) (int, error) {
+	confirms := make([]*amqp.DeferredConfirmation, 0, len(bodies))
+	var pubErr error
+	for _, body := range bodies {
+		dc, err := p.ch.PublishWithDeferredConfirmWithContext(ctx, "", p.queue, false, false, persistent(body, nil))
+		if err != nil {
+			pubErr = err
+			break
+		}
+		confirms = append(confirms, dc)
+	}
+
+	for i, dc := range confirms {
+		ok, err := dc.WaitContext(ctx)
+		if err != nil {
+			return i, err
+		}
+		if !ok {
+			return i, ErrNacked
+		}
+	}
+	return len(confirms), pubErr
+}
+
+// RetryLadder — паузы очередей ожидания. Очередей ровно столько, сколько
+// ступеней, какие бы задержки ни выбирали политики повторов.
+var RetryLadder = []time.Duration{
+	time.Second,
+	5 * time.Second,
+	30 * time.Second,
+	2 * time.Minute,
+	10 * time.Minute,
+	time.Hour,
+	6 * time.Hour,
+}
+
+// HeaderDelayUntil — момент в миллисекундах Unix, раньше которого отложенное
+// сообщение не обрабатывается.
+const HeaderDelayUntil = "x-delay-until"
+
+// RetryQueueName — очередь ожидания для задержки delay перед возвратом в queue.
+func RetryQueueName(queue string, delay time.Duration) string {
+	return fmt.Sprintf("%s.retry.%s", queue, delay)
+}
+
+// RetryStep — ступень RetryLadder для оставшейся паузы: наибольшая, которая
+// её не превышает, но не меньше первой.
+func RetryStep(remaining time.Duration) time.Duration {
+	step := RetryLadder[0]
+	for _, d := range RetryLadder[1:] {
+		if d > remaining {
+			break
+		}
+		step = d
+	}
+	return step
+}
+
+// DelayRemaining — сколько ещё должно ждать сообщение с заголовками h.
+// Остаток меньше первой ступени считается истёкшим: на такую паузу
+// очереди нет.
+func DelayRemaining(h amqp.Table, now time.Time) time.Duration {
+	until, ok := h[HeaderDelayUntil].(int64)
+	if !ok {
+		return 0
+	}
+	if rest := time.UnixMilli(until).Sub(now); rest >= RetryLadder[0] {
+		return rest
+	}
+	return 0
+}
+
+// PublishDelayed откладывает сообщение на delay. Оно уходит в очередь
+// ожидания ступени RetryStep(delay), по истечении TTL брокер через
+// dead-letter exchange возвращает его в основную очередь, так что консьюмер
+// не держит доставку на время паузы. Точный срок пишется в HeaderDelayUntil:
+// консьюмер, получивший сообщение раньше (DelayRemaining > 0), откладывает
+// его снова на остаток. Очередь ожидания объявляется при первом использовании.
+func (p *Publisher) PublishDelayed(ctx context.Context, body []byte, headers amqp.Table, delay time.Duration) error {
+	if delay <= 0 {
+		return p.publish(ctx, p.queue, body, headers)
+	}
+	h := make(amqp.Table, len(headers)+1)
+	for k, v := range headers {
+		h[k] = v
+	}
+	h[HeaderDelayUntil] = time.Now().Add(delay).UnixMilli()
+
+	q, err := p.retryQueue(RetryStep(delay))
+	if err != nil {
+		return err
+	}
+	return p.publish(ctx, q, body, h)
+}
+
+func (p *Publisher) retryQueue(delay time.Duration) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if q, ok := p.retryQueues[delay]; ok {
+		return q, nil
+	}
+
+	q := RetryQueueName(p.queue, delay)
+	_, err := p.ch.QueueDeclare(q, true, false, false, false, amqp.Table{
+		"x-message-ttl":             delay.Milliseconds(),
+		"x-dead-letter-exchange":    "",
+		"x-dead-letter-routing-key": p.queue,
+	})
+	if err != nil {
+		return "", err
+	}
+	p.retryQueues[delay] = q
+	return q, nil
+}
+
+func (p *Publisher) publish(ctx context.Context, queue string, body []byte, headers amqp.Table) error {
+	dc, err := p.ch.PublishWithDeferredConfirmWithContext(
 		ctx,
-		"", p.queue, // exchange, key
+		"", queue, // exchange, key
 		false, false,
-		amqp.Publishing{
-			ContentType:  "application/json",
-			DeliveryMode: amqp.Persistent,
-			Timestamp:    time.Now(),
-			Body:         body,
-			Headers:      headers,
-		},
+		persistent(body, headers),
 	)
+	if err != nil {
+		return err
+	}
+	ok, err := dc.WaitContext(ctx)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNacked
+	}
+	return nil
+}
+
+func persistent(body []byte, headers amqp.Table) amqp.Publishing {
+	return amqp.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent,
+		Timestamp:    time.Now(),
+		Body:         body,
+		Headers:      headers,
+	}
 }
 
 type Consumer struct {
@@ -75,7 +228,9 @@ type Consumer struct {
 	Queue string
 }
 
-func NewConsumer(url, queue string) (*Consumer, error) {
+// NewConsumer открывает канал с QoS prefetch: столько неподтверждённых
+// доставок брокер отдаёт консьюмеру одновременно.
+func NewConsumer(url, queue string, prefetch int) (*Consumer, error) {
 	conn, err := amqp.Dial(url)
 	if err != nil {
 		return nil, err
@@ -93,7 +248,7 @@ func NewConsumer(url, queue string) (*Consumer, error) {
 		return nil, err
 	}
 
-	if err := ch.Qos(10, 0, false); err != nil {
+	if err := ch.Qos(prefetch, 0, false); err != nil {
 		_ = ch.Close()
 		_ = conn.Close()
 		return nil, err
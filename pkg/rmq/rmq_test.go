@@ -0,0 +1,41 @@
+package rmq
+
+import (
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func TestRetryStep(t *testing.T) {
+	cases := []struct{ rest, want time.Duration }{
+		{0, time.Second},
+		{4 * time.Second, time.Second},
+		{90 * time.Second, 30 * time.Second},
+		{10 * time.Minute, 10 * time.Minute},
+		{48 * time.Hour, 6 * time.Hour},
+	}
+	for _, c := range cases {
+		if got := RetryStep(c.rest); got != c.want {
+			t.Errorf("RetryStep(%s) = %s, want %s", c.rest, got, c.want)
+		}
+	}
+}
+
+func TestDelayRemaining(t *testing.T) {
+	now := time.Date(2025, 10, 2, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		h    amqp.Table
+		want time.Duration
+	}{
+		{nil, 0},
+		{amqp.Table{HeaderDelayUntil: now.Add(90 * time.Second).UnixMilli()}, 90 * time.Second},
+		{amqp.Table{HeaderDelayUntil: now.Add(300 * time.Millisecond).UnixMilli()}, 0},
+		{amqp.Table{HeaderDelayUntil: now.Add(-time.Minute).UnixMilli()}, 0},
+	}
+	for _, c := range cases {
+		if got := DelayRemaining(c.h, now); got != c.want {
+			t.Errorf("DelayRemaining(%v) = %s, want %s", c.h, got, c.want)
+		}
+	}
+}
@@ -5,15 +5,22 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
+	"github.com/Mutter0815/MassMailer/internal/campaign"
 	"github.com/Mutter0815/MassMailer/internal/store"
+	"github.com/Mutter0815/MassMailer/internal/token"
 	"github.com/Mutter0815/MassMailer/pkg/config"
 	"github.com/Mutter0815/MassMailer/pkg/db"
 	"github.com/Mutter0815/MassMailer/pkg/logx"
 	"github.com/Mutter0815/MassMailer/pkg/rmq"
+	"github.com/Mutter0815/MassMailer/services/campaign-api/imports"
+	"github.com/Mutter0815/MassMailer/services/campaign-api/outbox"
+	"github.com/Mutter0815/MassMailer/services/campaign-api/scheduler"
 	"github.com/Mutter0815/MassMailer/services/campaign-api/server"
+	"github.com/Mutter0815/MassMailer/services/campaign-api/tracking"
 )
 
 func main() {
@@ -49,7 +56,46 @@ func main() {
 		}
 	}()
 
-	h := server.NewHandlers(st, pub)
+	dlq, err := rmq.NewDeadLetters(cfg.RMQURL, cfg.Queue)
+	if err != nil {
+		logx.L().Fatalw("rmq_dlq_init_error", "error", err)
+	}
+	defer func() {
+		if err := dlq.Close(); err != nil {
+			logx.L().Warnw("rmq_dlq_close_error", "error", err)
+		}
+	}()
+
+	bgCtx, stopBg := context.WithCancel(context.Background())
+	var bg sync.WaitGroup
+	im := imports.New(st, cfg.ImportDir, cfg.ImportMaxBytes, cfg.ImportConcurrency, cfg.ImportStaleAfter)
+	opens := tracking.New(st, cfg.OpenFlushInterval)
+
+	bg.Add(4)
+	go func() {
+		defer bg.Done()
+		scheduler.New(st, cfg.SchedulerInterval).Run(bgCtx)
+	}()
+	go func() {
+		defer bg.Done()
+		outbox.NewRelay(st, pub, cfg.OutboxInterval).Run(bgCtx)
+	}()
+	go func() {
+		defer bg.Done()
+		im.Run(bgCtx)
+	}()
+	go func() {
+		defer bg.Done()
+		opens.Run(bgCtx)
+	}()
+
+	links := token.NewSigner(cfg.LinkSecret)
+	if links == nil {
+		logx.L().Warnw("unsubscribe_links_disabled", "reason", "LINK_SECRET is not set")
+	}
+	h := server.NewHandlers(st, dlq, im, campaign.RetryPolicy(cfg.Retry), links)
+	h.BounceSuppressAfter = cfg.BounceSuppressAfter
+	h.Opens = opens
 	srv := server.NewHTTPServer(":"+cfg.Port, h)
 
 	go func() {
@@ -73,5 +119,8 @@ func main() {
 		logx.L().Infow("server_shutdown_success")
 	}
 
+	stopBg()
+	bg.Wait()
+
 	logx.L().Infow("campaign-api stopped gracefully")
 }
@@ -0,0 +1,328 @@
+package imports
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Mutter0815/MassMailer/internal/campaign"
+	"github.com/Mutter0815/MassMailer/internal/store"
+	"github.com/Mutter0815/MassMailer/pkg/logx"
+)
+
+type storeAPI interface {
+	WithTx(ctx context.Context, fn func(tx *sql.Tx) error) error
+	InsertRecipients(ctx context.Context, tx *sql.Tx, campaignID int64, rs []store.NewRecipient) (int, int, error)
+	StartImport(ctx context.Context, id int64) error
+	AddImportProgress(ctx context.Context, tx *sql.Tx, id int64, p store.ImportProgress) error
+	FinishImport(ctx context.Context, q store.Querier, id int64, status, lastErr string) error
+	FailStaleImports(ctx context.Context, staleAfter time.Duration) (int, error)
+	SetCampaignStatus(ctx context.Context, q store.Querier, id int64, to string) error
+	LockCampaignStatus(ctx context.Context, tx *sql.Tx, id int64) (string, error)
+	CancelMessages(ctx context.Context, q store.Querier, campaignID int64) (int, error)
+}
+
+// ErrTooLarge — загрузка больше MaxBytes.
+var ErrTooLarge = errors.New("import body is too large")
+
+// errNotImporting — кампанию отменили, пока шёл импорт.
+var errNotImporting = errors.New("campaign is no longer importing")
+
+// maxRowErrors — сколько отклонённых и повторных строк импорт хранит с
+// текстом ошибки; счётчики rejected и duplicates учитывают все.
+const maxRowErrors = 100
+
+// Job — загруженный во временный файл импорт, ждущий обработки.
+type Job struct {
+	ID         int64
+	CampaignID int64
+	Path       string
+	Spec
+}
+
+// Importer разбирает загруженные получателей в фоне. Тело запроса сначала
+// целиком пишется во временный файл (Spool), так что ответ 202 не ждёт
+// записи в БД, а память не зависит от размера списка. Получатели пишутся
+// пачками по BatchSize, каждая в своей транзакции вместе с прогрессом.
+//
+// Файл живёт только на этом процессе: импорт, который дольше StaleAfter
+// не двигался, любой экземпляр API закрывает как прерванный.
+type Importer struct {
+	Store      storeAPI
+	Dir        string
+	MaxBytes   int64
+	BatchSize  int
+	StaleAfter time.Duration
+	Interval   time.Duration
+
+	slots  chan struct{}
+	wg     sync.WaitGroup
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// New создаёт Importer, который обрабатывает не больше concurrency импортов одновременно.
+func New(st storeAPI, dir string, maxBytes int64, concurrency int, staleAfter time.Duration) *Importer {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Importer{
+		Store:      st,
+		Dir:        dir,
+		MaxBytes:   maxBytes,
+		BatchSize:  5000,
+		StaleAfter: staleAfter,
+		Interval:   time.Minute,
+		slots:      make(chan struct{}, concurrency),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+}
+
+// Acquire занимает место под новый импорт; false — все места заняты.
+// Место освобождает Release или завершение импорта, запущенного Start.
+func (im *Importer) Acquire() bool {
+	select {
+	case im.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (im *Importer) Release() { <-im.slots }
+
+// Spool копирует тело загрузки во временный файл и возвращает его путь.
+func (im *Importer) Spool(r io.Reader) (string, error) {
+	f, err := os.CreateTemp(im.Dir, "import-*")
+	if err != nil {
+		return "", err
+	}
+	n, err := io.Copy(f, io.LimitReader(r, im.MaxBytes+1))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && n > im.MaxBytes {
+		err = ErrTooLarge
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// Start обрабатывает импорт в фоне и по завершении освобождает место,
+// занятое Acquire.
+func (im *Importer) Start(j Job) {
+	im.wg.Add(1)
+	go func() {
+		defer im.wg.Done()
+		defer im.Release()
+		defer func() { _ = os.Remove(j.Path) }()
+		im.Process(im.ctx, j)
+	}()
+}
+
+// Run закрывает зависшие импорты раз в Interval; при остановке прерывает
+// идущие импорты и ждёт их завершения.
+func (im *Importer) Run(ctx context.Context) {
+	logx.L().Infow("importer_started", "interval", im.Interval.String(), "stale_after", im.StaleAfter.String())
+	t := time.NewTicker(im.Interval)
+	defer t.Stop()
+
+	for {
+		if n, err := im.Store.FailStaleImports(ctx, im.StaleAfter); err != nil && !errors.Is(err, context.Canceled) {
+			logx.L().Errorw("importer_sweep_error", "error", err)
+		} else if n > 0 {
+			logx.L().Warnw("stale_imports_failed", "count", n)
+		}
+		select {
+		case <-ctx.Done():
+			im.cancel()
+			im.wg.Wait()
+			logx.L().Infow("importer_stopped")
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Process загружает получателей из файла задания. Ошибка чтения файла или
+// записи в БД завершает импорт как failed и отменяет кампанию; импорт без
+// единого принятого получателя тоже считается неудачным. Если кампанию
+// отменили по ходу импорта, он прекращается, а записанные сообщения
+// отменяются вместе с ней.
+func (im *Importer) Process(ctx context.Context, j Job) {
+	if err := im.Store.StartImport(ctx, j.ID); err != nil {
+		logx.L().Errorw("import_start_error", "import_id", j.ID, "error", err)
+		return
+	}
+	logx.L().Infow("import_started", "import_id", j.ID, "campaign_id", j.CampaignID, "format", j.Format)
+
+	accepted, rejected, err := im.load(ctx, j)
+	if err == nil && accepted == 0 {
+		err = errors.New("no valid recipients")
+	}
+
+	// итог записываем и после остановки процесса, иначе импорт дождётся FailStaleImports
+	finCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+	if err != nil && !errors.Is(err, errNotImporting) {
+		im.fail(finCtx, j, err)
+		logx.L().Errorw("import_failed", "import_id", j.ID, "campaign_id", j.CampaignID,
+			"accepted", accepted, "rejected", rejected, "error", err)
+		return
+	}
+
+	if err == nil {
+		err = im.Store.WithTx(finCtx, func(tx *sql.Tx) error {
+			if err := im.Store.SetCampaignStatus(finCtx, tx, j.CampaignID, campaign.StatusQueued); err != nil {
+				return err
+			}
+			return im.Store.FinishImport(finCtx, tx, j.ID, campaign.ImportCompleted, "")
+		})
+	}
+	if errors.Is(err, errNotImporting) || errors.Is(err, campaign.ErrInvalidTransition) {
+		// кампанию отменили, пока шёл импорт
+		var canceled int
+		err = im.Store.WithTx(finCtx, func(tx *sql.Tx) error {
+			if err := im.Store.FinishImport(finCtx, tx, j.ID, campaign.ImportFailed, errNotImporting.Error()); err != nil {
+				return err
+			}
+			var err error
+			canceled, err = im.Store.CancelMessages(finCtx, tx, j.CampaignID)
+			return err
+		})
+		if err == nil {
+			logx.L().Warnw("import_abandoned", "import_id", j.ID, "campaign_id", j.CampaignID,
+				"accepted", accepted, "messages_canceled", canceled)
+			return
+		}
+	}
+	if err != nil {
+		logx.L().Errorw("import_finish_error", "import_id", j.ID, "error", err)
+		return
+	}
+	logx.L().Infow("import_completed", "import_id", j.ID, "campaign_id", j.CampaignID,
+		"accepted", accepted, "rejected", rejected)
+}
+
+func (im *Importer) load(ctx context.Context, j Job) (accepted, rejected int, err error) {
+	f, err := os.Open(j.Path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer func() { _ = f.Close() }()
+
+	src, err := NewSource(f, j.Spec)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	batch := make([]store.NewRecipient, 0, im.BatchSize)
+	var progress store.ImportProgress
+	// seen — первая строка каждого адреса в текущей пачке; повтор адреса из
+	// прошлых пачек отсекает уникальный индекс при вставке, так что память
+	// не растёт с размером загрузки
+	seen := make(map[string]int)
+	listed := 0
+	rowError := func(e campaign.ImportRowError) {
+		if listed < maxRowErrors {
+			progress.Errors = append(progress.Errors, e)
+			listed++
+		}
+	}
+	flush := func() error {
+		err := im.Store.WithTx(ctx, func(tx *sql.Tx) error {
+			// статус держим до коммита: отмена не проскочит между проверкой и записью
+			status, err := im.Store.LockCampaignStatus(ctx, tx, j.CampaignID)
+			if err != nil {
+				return err
+			}
+			if status != campaign.StatusImporting {
+				return errNotImporting
+			}
+			n, _, err := im.Store.InsertRecipients(ctx, tx, j.CampaignID, batch)
+			if err != nil {
+				return err
+			}
+			// не записанные — адреса, уже попавшие в кампанию из прошлых пачек
+			progress.Accepted -= len(batch) - n
+			progress.Duplicates += len(batch) - n
+			return im.Store.AddImportProgress(ctx, tx, j.ID, progress)
+		})
+		if err != nil {
+			return err
+		}
+		accepted += progress.Accepted
+		rejected += progress.Rejected
+		batch, progress = batch[:0], store.ImportProgress{}
+		clear(seen)
+		return nil
+	}
+
+	for {
+		r, err := src.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var rowErr *RowError
+		switch {
+		case errors.As(err, &rowErr):
+			progress.Processed++
+			progress.Rejected++
+			rowError(campaign.ImportRowError{Row: rowErr.Row, Error: rowErr.Err.Error()})
+		case err != nil:
+			return accepted, rejected, err
+		default:
+			progress.Processed++
+			if first, ok := seen[r.Address]; ok {
+				progress.Duplicates++
+				rowError(campaign.ImportRowError{Row: src.Row(), Error: fmt.Sprintf("duplicate of row %d", first)})
+				break
+			}
+			seen[r.Address] = src.Row()
+			progress.Accepted++
+			batch = append(batch, store.NewRecipient{Address: r.Address, Name: r.Name, Vars: r.Vars})
+		}
+		if progress.Processed >= im.BatchSize {
+			if err := flush(); err != nil {
+				return accepted, rejected, err
+			}
+		}
+	}
+	if progress.Processed > 0 {
+		if err := flush(); err != nil {
+			return accepted, rejected, err
+		}
+	}
+	return accepted, rejected, nil
+}
+
+// fail закрывает импорт с ошибкой и отменяет кампанию, если она ещё ждёт импорта.
+func (im *Importer) fail(ctx context.Context, j Job, cause error) {
+	err := im.Store.WithTx(ctx, func(tx *sql.Tx) error {
+		if err := im.Store.FinishImport(ctx, tx, j.ID, campaign.ImportFailed, cause.Error()); err != nil {
+			return err
+		}
+		err := im.Store.SetCampaignStatus(ctx, tx, j.CampaignID, campaign.StatusCanceled)
+		if errors.Is(err, campaign.ErrInvalidTransition) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		_, err = im.Store.CancelMessages(ctx, tx, j.CampaignID)
+		return err
+	})
+	if err != nil {
+		logx.L().Errorw("import_finish_error", "import_id", j.ID, "error", err, "cause", cause)
+	}
+}
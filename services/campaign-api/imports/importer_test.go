@@ -0,0 +1,309 @@
+package imports
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Mutter0815/MassMailer/internal/campaign"
+	"github.com/Mutter0815/MassMailer/internal/store"
+)
+
+type fakeStore struct {
+	campaignStatus string
+	importStatus   string
+	importErr      string
+	batches        []int
+	recipients     []store.NewRecipient
+	progress       store.ImportProgress
+	rowErrors      []campaign.ImportRowError
+	failInsert     bool
+	canceled       int
+	// addresses — уже записанные адреса, как уникальный индекс в БД
+	addresses map[string]bool
+	// cancelAfter — после стольких пачек кампанию отменяют снаружи
+	cancelAfter int
+}
+
+func (f *fakeStore) WithTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	return fn(&sql.Tx{})
+}
+
+func (f *fakeStore) InsertRecipients(ctx context.Context, tx *sql.Tx, campaignID int64, rs []store.NewRecipient) (int, int, error) {
+	if f.failInsert {
+		return 0, 0, fmt.Errorf("db down")
+	}
+	f.batches = append(f.batches, len(rs))
+	if f.addresses == nil {
+		f.addresses = make(map[string]bool)
+	}
+	n := 0
+	for _, r := range rs {
+		if f.addresses[r.Address] {
+			continue
+		}
+		f.addresses[r.Address] = true
+		f.recipients = append(f.recipients, r)
+		n++
+	}
+	return n, 0, nil
+}
+
+func (f *fakeStore) StartImport(ctx context.Context, id int64) error {
+	if f.importStatus != campaign.ImportPending {
+		return sql.ErrNoRows
+	}
+	f.importStatus = campaign.ImportRunning
+	return nil
+}
+
+func (f *fakeStore) AddImportProgress(ctx context.Context, tx *sql.Tx, id int64, p store.ImportProgress) error {
+	f.progress.Processed += p.Processed
+	f.progress.Accepted += p.Accepted
+	f.progress.Rejected += p.Rejected
+	f.progress.Duplicates += p.Duplicates
+	f.rowErrors = append(f.rowErrors, p.Errors...)
+	return nil
+}
+
+func (f *fakeStore) FinishImport(ctx context.Context, q store.Querier, id int64, status, lastErr string) error {
+	f.importStatus, f.importErr = status, lastErr
+	return nil
+}
+
+func (f *fakeStore) FailStaleImports(ctx context.Context, staleAfter time.Duration) (int, error) {
+	return 0, nil
+}
+
+func (f *fakeStore) SetCampaignStatus(ctx context.Context, q store.Querier, id int64, to string) error {
+	if !campaign.CanTransition(f.campaignStatus, to) {
+		return campaign.ErrInvalidTransition
+	}
+	f.campaignStatus = to
+	return nil
+}
+
+func (f *fakeStore) LockCampaignStatus(ctx context.Context, tx *sql.Tx, id int64) (string, error) {
+	if f.cancelAfter > 0 && len(f.batches) >= f.cancelAfter {
+		f.campaignStatus = campaign.StatusCanceled
+	}
+	return f.campaignStatus, nil
+}
+
+func (f *fakeStore) CancelMessages(ctx context.Context, q store.Querier, campaignID int64) (int, error) {
+	f.canceled = len(f.recipients)
+	return f.canceled, nil
+}
+
+func newJob(t *testing.T, format, body string) Job {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "import")
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return Job{ID: 1, CampaignID: 7, Path: path, Spec: Spec{Format: format}}
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{campaignStatus: campaign.StatusImporting, importStatus: campaign.ImportPending}
+}
+
+func TestProcess_LoadsInBatchesAndQueuesCampaign(t *testing.T) {
+	var b strings.Builder
+	for i := 0; i < 7; i++ {
+		fmt.Fprintf(&b, "{\"address\":\"u%d@x.com\",\"vars\":{\"i\":%d}}\n", i, i)
+	}
+	b.WriteString("{\"vars\":{}}\n")
+
+	fs := newFakeStore()
+	im := New(fs, t.TempDir(), 1<<20, 1, time.Minute)
+	im.BatchSize = 3
+	im.Process(context.Background(), newJob(t, campaign.ImportFormatNDJSON, b.String()))
+
+	if fs.importStatus != campaign.ImportCompleted || fs.campaignStatus != campaign.StatusQueued {
+		t.Fatalf("import=%s campaign=%s err=%q", fs.importStatus, fs.campaignStatus, fs.importErr)
+	}
+	if fmt.Sprint(fs.batches) != "[3 3 1]" {
+		t.Fatalf("unexpected batches: %v", fs.batches)
+	}
+	if p := fs.progress; p.Processed != 8 || p.Accepted != 7 || p.Rejected != 1 {
+		t.Fatalf("unexpected progress: %+v", fs.progress)
+	}
+	if len(fs.rowErrors) != 1 || fs.rowErrors[0].Row != 8 {
+		t.Fatalf("unexpected row errors: %+v", fs.rowErrors)
+	}
+	if fs.recipients[6].Address != "u6@x.com" || fs.recipients[6].Vars["i"] != float64(6) {
+		t.Fatalf("recipient not passed through: %+v", fs.recipients[6])
+	}
+}
+
+func TestProcess_CSVWithMapping(t *testing.T) {
+	fs := newFakeStore()
+	j := newJob(t, campaign.ImportFormatCSV, "mail,full name,plan\na@x.com,Ann Lee,pro\nbroken,\n")
+	j.Mapping = &campaign.ImportMapping{Address: "mail", Name: "full name"}
+	New(fs, t.TempDir(), 1<<20, 1, time.Minute).Process(context.Background(), j)
+
+	if fs.importStatus != campaign.ImportCompleted {
+		t.Fatalf("import=%s err=%q", fs.importStatus, fs.importErr)
+	}
+	if len(fs.recipients) != 1 || fs.recipients[0].Name != "Ann Lee" || fs.recipients[0].Vars["plan"] != "pro" {
+		t.Fatalf("recipient not mapped: %+v", fs.recipients)
+	}
+	if len(fs.rowErrors) != 1 || fs.rowErrors[0].Row != 3 {
+		t.Fatalf("unexpected row errors: %+v", fs.rowErrors)
+	}
+}
+
+func TestProcess_CapsStoredRowErrors(t *testing.T) {
+	body := strings.Repeat("\"\"\n", maxRowErrors+20) + "\"a@x.com\"\n"
+
+	fs := newFakeStore()
+	im := New(fs, t.TempDir(), 1<<20, 1, time.Minute)
+	im.BatchSize = 7
+	im.Process(context.Background(), newJob(t, campaign.ImportFormatNDJSON, body))
+
+	if fs.progress.Rejected != maxRowErrors+20 || len(fs.rowErrors) != maxRowErrors {
+		t.Fatalf("rejected=%d stored=%d", fs.progress.Rejected, len(fs.rowErrors))
+	}
+	if fs.importStatus != campaign.ImportCompleted {
+		t.Fatalf("import=%s err=%q", fs.importStatus, fs.importErr)
+	}
+}
+
+func TestProcess_Failures(t *testing.T) {
+	cases := map[string]struct {
+		body       string
+		failInsert bool
+		wantErr    string
+	}{
+		"broken json":   {body: `["a@x.com", {`, wantErr: "unexpected EOF"},
+		"no recipients": {body: `[42]`, wantErr: "no valid recipients"},
+		"db error":      {body: `["a@x.com"]`, failInsert: true, wantErr: "db down"},
+	}
+	for name, tc := range cases {
+		fs := newFakeStore()
+		fs.failInsert = tc.failInsert
+		New(fs, t.TempDir(), 1<<20, 1, time.Minute).Process(context.Background(), newJob(t, campaign.ImportFormatJSON, tc.body))
+
+		if fs.importStatus != campaign.ImportFailed || !strings.Contains(fs.importErr, tc.wantErr) {
+			t.Errorf("%s: import=%s err=%q", name, fs.importStatus, fs.importErr)
+		}
+		if fs.campaignStatus != campaign.StatusCanceled {
+			t.Errorf("%s: campaign must be canceled, got %s", name, fs.campaignStatus)
+		}
+	}
+}
+
+func TestProcess_CampaignCanceledDuringImport(t *testing.T) {
+	fs := newFakeStore()
+	fs.campaignStatus = campaign.StatusCanceled
+	New(fs, t.TempDir(), 1<<20, 1, time.Minute).Process(context.Background(), newJob(t, campaign.ImportFormatJSON, `["a@x.com"]`))
+
+	if fs.importStatus != campaign.ImportFailed || fs.importErr != "campaign is no longer importing" {
+		t.Fatalf("import=%s err=%q", fs.importStatus, fs.importErr)
+	}
+	if len(fs.batches) != 0 {
+		t.Fatalf("nothing must be written for a canceled campaign, got batches %v", fs.batches)
+	}
+}
+
+func TestProcess_CanceledMidImportStopsAndCancelsMessages(t *testing.T) {
+	fs := newFakeStore()
+	fs.cancelAfter = 1
+	im := New(fs, t.TempDir(), 1<<20, 1, time.Minute)
+	im.BatchSize = 1
+	im.Process(context.Background(), newJob(t, campaign.ImportFormatJSON, `["a@x.com", "b@x.com", "c@x.com"]`))
+
+	if fs.importStatus != campaign.ImportFailed || fs.importErr != "campaign is no longer importing" {
+		t.Fatalf("import=%s err=%q", fs.importStatus, fs.importErr)
+	}
+	if fmt.Sprint(fs.batches) != "[1]" {
+		t.Fatalf("import must stop after the cancel, got batches %v", fs.batches)
+	}
+	if fs.canceled != 1 {
+		t.Fatalf("written messages must be canceled, got %d", fs.canceled)
+	}
+}
+
+func TestSpool(t *testing.T) {
+	dir := t.TempDir()
+	im := New(newFakeStore(), dir, 8, 1, time.Minute)
+
+	path, err := im.Spool(strings.NewReader("12345678"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(path); string(b) != "12345678" {
+		t.Fatalf("unexpected spool content %q", b)
+	}
+
+	if _, err := im.Spool(strings.NewReader("123456789")); err != ErrTooLarge {
+		t.Fatalf("want ErrTooLarge, got %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Fatalf("oversized spool must be removed, dir has %d files", len(entries))
+	}
+}
+
+func TestAcquire_LimitsConcurrency(t *testing.T) {
+	im := New(newFakeStore(), t.TempDir(), 1, 2, time.Minute)
+	if !im.Acquire() || !im.Acquire() {
+		t.Fatal("two slots must be available")
+	}
+	if im.Acquire() {
+		t.Fatal("third import must be refused")
+	}
+	im.Release()
+	if !im.Acquire() {
+		t.Fatal("released slot must be reusable")
+	}
+}
+
+func TestProcess_DropsDuplicatesAcrossBatches(t *testing.T) {
+	fs := newFakeStore()
+	im := New(fs, t.TempDir(), 1<<20, 1, time.Minute)
+	im.BatchSize = 2
+	// повтор во второй пачке, повтор, отличающийся только регистром домена,
+	// и повтор внутри одной пачки
+	im.Process(context.Background(), newJob(t, campaign.ImportFormatCSV,
+		"email\na@x.com\nb@x.com\nc@x.com\na@x.com\nb@X.COM\nB@x.com\nd@x.com\nd@x.com\n"))
+
+	if fs.importStatus != campaign.ImportCompleted {
+		t.Fatalf("import=%s err=%q", fs.importStatus, fs.importErr)
+	}
+	if p := fs.progress; p.Processed != 8 || p.Accepted != 5 || p.Duplicates != 3 || p.Rejected != 0 {
+		t.Fatalf("unexpected progress: %+v", p)
+	}
+	var got []string
+	for _, r := range fs.recipients {
+		got = append(got, r.Address)
+	}
+	if fmt.Sprint(got) != "[a@x.com b@x.com c@x.com B@x.com d@x.com]" {
+		t.Fatalf("unexpected recipients: %v", got)
+	}
+	// повторы прошлых пачек только считаются, внутри пачки — и перечисляются
+	want := []campaign.ImportRowError{{Row: 9, Error: "duplicate of row 8"}}
+	if fmt.Sprint(fs.rowErrors) != fmt.Sprint(want) {
+		t.Fatalf("unexpected row errors: %+v", fs.rowErrors)
+	}
+}
+
+func TestProcess_FailureCancelsWrittenMessages(t *testing.T) {
+	fs := newFakeStore()
+	im := New(fs, t.TempDir(), 1<<20, 1, time.Minute)
+	im.BatchSize = 1
+	// две пачки уже записаны, когда массив обрывается
+	im.Process(context.Background(), newJob(t, campaign.ImportFormatJSON, `["a@x.com", "b@x.com", {`))
+
+	if fs.importStatus != campaign.ImportFailed || fs.campaignStatus != campaign.StatusCanceled {
+		t.Fatalf("import=%s campaign=%s", fs.importStatus, fs.campaignStatus)
+	}
+	if fs.canceled != 2 {
+		t.Fatalf("written messages must be canceled, got %d", fs.canceled)
+	}
+}
@@ -0,0 +1,347 @@
+package imports
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Mutter0815/MassMailer/internal/campaign"
+)
+
+// maxLineSize ограничивает одну строку NDJSON.
+const maxLineSize = 1 << 20
+
+// RowError — строка, которую нельзя принять; импорт продолжается со следующей.
+type RowError struct {
+	Row int
+	Err error
+}
+
+func (e *RowError) Error() string { return fmt.Sprintf("row %d: %v", e.Row, e.Err) }
+
+func (e *RowError) Unwrap() error { return e.Err }
+
+// Source отдаёт получателей по одному. Next возвращает *RowError для
+// отклонённой строки, io.EOF в конце потока; любая другая ошибка означает,
+// что поток дальше читать нельзя.
+type Source interface {
+	Next() (campaign.Recipient, error)
+	// Row — номер строки, которую последней вернул Next.
+	Row() int
+}
+
+// Spec описывает, как разбирать загрузку.
+type Spec struct {
+	Format string
+	// Mapping задаёт колонки CSV или поля плоских объектов JSON/NDJSON.
+	// Без него JSON/NDJSON разбираются как recipients в POST /campaigns,
+	// а в CSV адрес ищется в колонке address или email.
+	Mapping *campaign.ImportMapping
+	// Delimiter — разделитель колонок CSV; ноль — запятая.
+	Delimiter rune
+}
+
+// Validate проверяет спецификацию до того, как импорт уйдёт в фон.
+func (s Spec) Validate() error {
+	switch s.Format {
+	case campaign.ImportFormatJSON, campaign.ImportFormatNDJSON, campaign.ImportFormatCSV:
+	default:
+		return fmt.Errorf("unsupported import format %q", s.Format)
+	}
+	if s.Delimiter != 0 {
+		if s.Format != campaign.ImportFormatCSV {
+			return errors.New("delimiter is only allowed for csv")
+		}
+		if s.Delimiter == '"' || s.Delimiter == '\r' || s.Delimiter == '\n' || s.Delimiter == utf8.RuneError {
+			return fmt.Errorf("invalid delimiter %q", s.Delimiter)
+		}
+	}
+	if m := s.Mapping; m != nil {
+		for name, col := range m.Vars {
+			if name == "" || col == "" {
+				return errors.New("mapping: vars must map non-empty names to non-empty columns")
+			}
+		}
+	}
+	return nil
+}
+
+// NewSource выбирает разбор по формату загрузки.
+func NewSource(r io.Reader, spec Spec) (Source, error) {
+	parse := parseRow
+	if spec.Mapping != nil {
+		m := newMapper(spec.Mapping)
+		parse = m.parseObject
+	}
+
+	switch spec.Format {
+	case campaign.ImportFormatJSON:
+		return &jsonSource{dec: json.NewDecoder(bufio.NewReader(r)), parse: parse}, nil
+	case campaign.ImportFormatNDJSON:
+		sc := bufio.NewScanner(r)
+		sc.Buffer(make([]byte, 64*1024), maxLineSize)
+		return &ndjsonSource{sc: sc, parse: parse}, nil
+	case campaign.ImportFormatCSV:
+		return newCSVSource(r, spec)
+	}
+	return nil, fmt.Errorf("unsupported import format %q", spec.Format)
+}
+
+// jsonSource читает JSON-массив поэлементно, не держа его целиком в памяти.
+type jsonSource struct {
+	dec     *json.Decoder
+	parse   func(row int, raw []byte) (campaign.Recipient, error)
+	row     int
+	started bool
+	done    bool
+}
+
+func (s *jsonSource) Next() (campaign.Recipient, error) {
+	if s.done {
+		return campaign.Recipient{}, io.EOF
+	}
+	if !s.started {
+		tok, err := s.dec.Token()
+		if err != nil {
+			return campaign.Recipient{}, fmt.Errorf("read json array: %w", noEOF(err))
+		}
+		if d, ok := tok.(json.Delim); !ok || d != '[' {
+			return campaign.Recipient{}, errors.New("body must be a JSON array")
+		}
+		s.started = true
+	}
+	if !s.dec.More() {
+		if _, err := s.dec.Token(); err != nil {
+			return campaign.Recipient{}, fmt.Errorf("read json array: %w", noEOF(err))
+		}
+		s.done = true
+		return campaign.Recipient{}, io.EOF
+	}
+
+	s.row++
+	var raw json.RawMessage
+	if err := s.dec.Decode(&raw); err != nil {
+		return campaign.Recipient{}, fmt.Errorf("row %d: %w", s.row, noEOF(err))
+	}
+	return s.parse(s.row, raw)
+}
+
+func (s *jsonSource) Row() int { return s.row }
+
+type ndjsonSource struct {
+	sc    *bufio.Scanner
+	parse func(row int, raw []byte) (campaign.Recipient, error)
+	row   int
+}
+
+func (s *ndjsonSource) Next() (campaign.Recipient, error) {
+	for s.sc.Scan() {
+		s.row++
+		line := bytes.TrimSpace(s.sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		return s.parse(s.row, line)
+	}
+	if err := s.sc.Err(); err != nil {
+		return campaign.Recipient{}, fmt.Errorf("line %d: %w", s.row+1, err)
+	}
+	return campaign.Recipient{}, io.EOF
+}
+
+func (s *ndjsonSource) Row() int { return s.row }
+
+// csvSource читает CSV с заголовком; Row — номер строки файла, заголовок — первая.
+type csvSource struct {
+	r      *csv.Reader
+	header []string
+	m      mapper
+	row    int
+}
+
+func newCSVSource(r io.Reader, spec Spec) (*csvSource, error) {
+	cr := csv.NewReader(bufio.NewReader(r))
+	cr.ReuseRecord = true
+	if spec.Delimiter != 0 {
+		cr.Comma = spec.Delimiter
+	}
+
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("csv: header row is required")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("csv header: %w", err)
+	}
+	s := &csvSource{r: cr, header: make([]string, len(header))}
+	seen := make(map[string]bool, len(header))
+	for i, h := range header {
+		if i == 0 {
+			h = strings.TrimPrefix(h, "\ufeff") // BOM из выгрузок Excel
+		}
+		h = strings.TrimSpace(h)
+		if seen[h] {
+			return nil, fmt.Errorf("csv header: duplicate column %q", h)
+		}
+		seen[h] = true
+		s.header[i] = h
+	}
+
+	mapping := campaign.ImportMapping{}
+	if spec.Mapping != nil {
+		mapping = *spec.Mapping
+	}
+	if mapping.Address == "" {
+		mapping.Address = s.column("address", "email")
+		if mapping.Address == "" {
+			return nil, errors.New("csv: no address or email column; set mapping.address")
+		}
+	}
+	if mapping.Name == "" && spec.Mapping == nil {
+		mapping.Name = s.column("name")
+	}
+	for _, col := range append([]string{mapping.Address, mapping.Name}, varColumns(mapping.Vars)...) {
+		if col != "" && !seen[col] {
+			return nil, fmt.Errorf("mapping: column %q not found in csv header", col)
+		}
+	}
+	s.m = newMapper(&mapping)
+	return s, nil
+}
+
+// column ищет колонку по одному из имён без учёта регистра.
+func (s *csvSource) column(names ...string) string {
+	for _, name := range names {
+		for _, h := range s.header {
+			if strings.EqualFold(h, name) {
+				return h
+			}
+		}
+	}
+	return ""
+}
+
+func (s *csvSource) Next() (campaign.Recipient, error) {
+	rec, err := s.r.Read()
+	if errors.Is(err, io.EOF) {
+		return campaign.Recipient{}, io.EOF
+	}
+	var pe *csv.ParseError
+	if errors.As(err, &pe) {
+		s.row = pe.StartLine
+		return campaign.Recipient{}, &RowError{Row: pe.StartLine, Err: pe.Err}
+	}
+	if err != nil {
+		return campaign.Recipient{}, err
+	}
+	s.row, _ = s.r.FieldPos(0)
+
+	fields := make(map[string]any, len(rec))
+	for i, v := range rec {
+		fields[s.header[i]] = v
+	}
+	return s.m.recipient(s.row, fields)
+}
+
+func (s *csvSource) Row() int { return s.row }
+
+// mapper собирает получателя из плоского набора полей по ImportMapping.
+type mapper struct {
+	address string
+	name    string
+	vars    map[string]string
+}
+
+func newMapper(m *campaign.ImportMapping) mapper {
+	out := mapper{address: m.Address, name: m.Name, vars: m.Vars}
+	if out.address == "" {
+		out.address = "address"
+	}
+	return out
+}
+
+// parseObject разбирает строку JSON/NDJSON как плоский объект; строка
+// целиком считается адресом.
+func (m mapper) parseObject(row int, raw []byte) (campaign.Recipient, error) {
+	var v any
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return campaign.Recipient{}, &RowError{Row: row, Err: err}
+	}
+	switch t := v.(type) {
+	case string:
+		return validRow(row, campaign.Recipient{Address: t})
+	case map[string]any:
+		return m.recipient(row, t)
+	}
+	return campaign.Recipient{}, &RowError{Row: row, Err: errors.New("row must be an object or a string")}
+}
+
+func (m mapper) recipient(row int, fields map[string]any) (campaign.Recipient, error) {
+	var r campaign.Recipient
+	var ok bool
+	if v, present := fields[m.address]; present {
+		if r.Address, ok = v.(string); !ok {
+			return campaign.Recipient{}, &RowError{Row: row, Err: fmt.Errorf("%s must be a string", m.address)}
+		}
+	}
+	if v, present := fields[m.name]; present && m.name != "" {
+		if r.Name, ok = v.(string); !ok {
+			return campaign.Recipient{}, &RowError{Row: row, Err: fmt.Errorf("%s must be a string", m.name)}
+		}
+	}
+
+	if m.vars != nil {
+		r.Vars = make(map[string]any, len(m.vars))
+		for name, col := range m.vars {
+			if v, present := fields[col]; present {
+				r.Vars[name] = v
+			}
+		}
+	} else {
+		r.Vars = make(map[string]any, len(fields))
+		for col, v := range fields {
+			if col != m.address && col != m.name {
+				r.Vars[col] = v
+			}
+		}
+	}
+	return validRow(row, r)
+}
+
+func parseRow(row int, raw []byte) (campaign.Recipient, error) {
+	var r campaign.Recipient
+	if err := json.Unmarshal(raw, &r); err != nil {
+		return campaign.Recipient{}, &RowError{Row: row, Err: err}
+	}
+	return validRow(row, r)
+}
+
+func validRow(row int, r campaign.Recipient) (campaign.Recipient, error) {
+	r, err := r.Normalize()
+	if err != nil {
+		return campaign.Recipient{}, &RowError{Row: row, Err: err}
+	}
+	return r, nil
+}
+
+func varColumns(vars map[string]string) []string {
+	out := make([]string, 0, len(vars))
+	for _, col := range vars {
+		out = append(out, col)
+	}
+	return out
+}
+
+// noEOF превращает io.EOF посреди массива в понятную ошибку обрыва.
+func noEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
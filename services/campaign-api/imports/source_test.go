@@ -0,0 +1,215 @@
+package imports
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/Mutter0815/MassMailer/internal/campaign"
+)
+
+// drain читает источник до конца и раскладывает принятые адреса и номера
+// отклонённых строк.
+func drain(t *testing.T, src Source) (addrs []string, rejected []int, err error) {
+	t.Helper()
+	for {
+		r, err := src.Next()
+		if errors.Is(err, io.EOF) {
+			return addrs, rejected, nil
+		}
+		var rowErr *RowError
+		if errors.As(err, &rowErr) {
+			rejected = append(rejected, rowErr.Row)
+			continue
+		}
+		if err != nil {
+			return addrs, rejected, err
+		}
+		addrs = append(addrs, r.Address)
+	}
+}
+
+func TestJSONSource(t *testing.T) {
+	src, err := NewSource(strings.NewReader(
+		`["a@x.com", {"address":"b@x.com","vars":{"n":1}}, 42, {"vars":{}}, "c@x.com"]`), Spec{Format: campaign.ImportFormatJSON})
+	if err != nil {
+		t.Fatal(err)
+	}
+	addrs, rejected, err := drain(t, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(addrs, ",") != "a@x.com,b@x.com,c@x.com" {
+		t.Fatalf("unexpected addresses: %v", addrs)
+	}
+	if len(rejected) != 2 || rejected[0] != 3 || rejected[1] != 4 {
+		t.Fatalf("want rows 3 and 4 rejected, got %v", rejected)
+	}
+}
+
+func TestJSONSource_Broken(t *testing.T) {
+	cases := map[string]string{
+		"not an array": `{"address":"a@x.com"}`,
+		"truncated":    `["a@x.com", "b@x`,
+		"unterminated": `["a@x.com"`,
+		"empty":        ``,
+	}
+	for name, body := range cases {
+		src, _ := NewSource(strings.NewReader(body), Spec{Format: campaign.ImportFormatJSON})
+		if _, _, err := drain(t, src); err == nil {
+			t.Errorf("%s: want fatal error", name)
+		}
+	}
+}
+
+func TestNDJSONSource(t *testing.T) {
+	body := "{\"address\":\"a@x.com\"}\n\n\"b@x.com\"\n{broken\n{\"address\":\"\"}\n\"c@x.com\""
+	src, err := NewSource(strings.NewReader(body), Spec{Format: campaign.ImportFormatNDJSON})
+	if err != nil {
+		t.Fatal(err)
+	}
+	addrs, rejected, err := drain(t, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(addrs, ",") != "a@x.com,b@x.com,c@x.com" {
+		t.Fatalf("unexpected addresses: %v", addrs)
+	}
+	// номера строк считаются по файлу, включая пустые
+	if len(rejected) != 2 || rejected[0] != 4 || rejected[1] != 5 {
+		t.Fatalf("want rows 4 and 5 rejected, got %v", rejected)
+	}
+}
+
+func TestNewSource_UnknownFormat(t *testing.T) {
+	if _, err := NewSource(strings.NewReader(""), Spec{Format: "xml"}); err == nil {
+		t.Fatal("want error for unknown format")
+	}
+}
+
+func TestCSVSource(t *testing.T) {
+	body := "\ufeffEmail, name ,city\n" +
+		"a@x.com,Ann Lee,Riga\n" +
+		",Nobody,Oslo\n" +
+		"b@x.com,Bob\n" +
+		"\"c@x.com\",\"Lee, Carl\",\"Kyiv\"\n"
+	src, err := NewSource(strings.NewReader(body), Spec{Format: campaign.ImportFormatCSV})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []campaign.Recipient
+	var rejected []int
+	for {
+		r, err := src.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var rowErr *RowError
+		if errors.As(err, &rowErr) {
+			rejected = append(rejected, rowErr.Row)
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, r)
+	}
+
+	if len(got) != 2 || got[0].Address != "a@x.com" || got[0].Name != "Ann Lee" || got[0].Vars["city"] != "Riga" ||
+		got[1].Name != "Lee, Carl" || got[1].Vars["city"] != "Kyiv" {
+		t.Fatalf("unexpected recipients: %+v", got)
+	}
+	if _, ok := got[0].Vars["Email"]; ok {
+		t.Fatalf("address column must not become a variable: %+v", got[0].Vars)
+	}
+	// пустой адрес и строка с неверным числом колонок
+	if len(rejected) != 2 || rejected[0] != 3 || rejected[1] != 4 {
+		t.Fatalf("want rows 3 and 4 rejected, got %v", rejected)
+	}
+}
+
+func TestCSVSource_Mapping(t *testing.T) {
+	body := "mail;full name;plan;city\na@x.com;Ann;pro;Riga\n"
+	src, err := NewSource(strings.NewReader(body), Spec{
+		Format:    campaign.ImportFormatCSV,
+		Delimiter: ';',
+		Mapping: &campaign.ImportMapping{
+			Address: "mail",
+			Name:    "full name",
+			Vars:    map[string]string{"tariff": "plan"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := src.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Address != "a@x.com" || r.Name != "Ann" || len(r.Vars) != 1 || r.Vars["tariff"] != "pro" {
+		t.Fatalf("unexpected recipient: %+v", r)
+	}
+}
+
+func TestCSVSource_BadHeader(t *testing.T) {
+	cases := map[string]struct {
+		body    string
+		mapping *campaign.ImportMapping
+	}{
+		"empty":          {"", nil},
+		"no address":     {"name,city\nAnn,Riga\n", nil},
+		"duplicate":      {"email,email\n", nil},
+		"missing mapped": {"email,name\n", &campaign.ImportMapping{Vars: map[string]string{"city": "city"}}},
+	}
+	for name, tc := range cases {
+		if _, err := NewSource(strings.NewReader(tc.body), Spec{Format: campaign.ImportFormatCSV, Mapping: tc.mapping}); err == nil {
+			t.Errorf("%s: want error", name)
+		}
+	}
+}
+
+func TestNDJSONSource_Mapping(t *testing.T) {
+	body := "{\"mail\":\"a@x.com\",\"who\":\"Ann\",\"plan\":\"pro\",\"extra\":1}\n" +
+		"{\"mail\":42}\n" +
+		"\"b@x.com\"\n"
+	src, err := NewSource(strings.NewReader(body), Spec{
+		Format:  campaign.ImportFormatNDJSON,
+		Mapping: &campaign.ImportMapping{Address: "mail", Name: "who", Vars: map[string]string{"plan": "plan"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := src.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Address != "a@x.com" || r.Name != "Ann" || len(r.Vars) != 1 || r.Vars["plan"] != "pro" {
+		t.Fatalf("unexpected recipient: %+v", r)
+	}
+	var rowErr *RowError
+	if _, err := src.Next(); !errors.As(err, &rowErr) || rowErr.Row != 2 {
+		t.Fatalf("want row 2 rejected, got %v", err)
+	}
+	if r, err := src.Next(); err != nil || r.Address != "b@x.com" {
+		t.Fatalf("string row must be an address: %+v, %v", r, err)
+	}
+}
+
+func TestSpecValidate(t *testing.T) {
+	bad := map[string]Spec{
+		"format":         {Format: "xml"},
+		"json delimiter": {Format: campaign.ImportFormatJSON, Delimiter: ';'},
+		"quote":          {Format: campaign.ImportFormatCSV, Delimiter: '"'},
+		"empty var":      {Format: campaign.ImportFormatCSV, Mapping: &campaign.ImportMapping{Vars: map[string]string{"x": ""}}},
+	}
+	for name, s := range bad {
+		if err := s.Validate(); err == nil {
+			t.Errorf("%s: want error", name)
+		}
+	}
+	if err := (Spec{Format: campaign.ImportFormatCSV, Delimiter: '\t'}).Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
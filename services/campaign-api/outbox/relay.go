@@ -0,0 +1,106 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Mutter0815/MassMailer/internal/store"
+	"github.com/Mutter0815/MassMailer/pkg/logx"
+	"github.com/Mutter0815/MassMailer/pkg/metrics"
+	"github.com/Mutter0815/MassMailer/pkg/rmq"
+)
+
+type storeAPI interface {
+	WithTx(ctx context.Context, fn func(tx *sql.Tx) error) error
+	ClaimOutbox(ctx context.Context, tx *sql.Tx, limit int) ([]store.OutboxRow, error)
+	MarkOutboxDispatched(ctx context.Context, tx *sql.Tx, ids []int64) error
+	RecordOutboxError(ctx context.Context, tx *sql.Tx, id int64, lastErr string) error
+}
+
+type publisherAPI interface {
+	PublishBatch(ctx context.Context, bodies [][]byte) (int, error)
+}
+
+// Relay переносит записи outbox в RabbitMQ. Запись помечается отправленной
+// только после подтверждения брокера, поэтому падение процесса между
+// публикацией и коммитом приводит к повторной публикации, но не к потере.
+// Пачка уходит в брокер целиком, а подтверждения собираются после неё.
+type Relay struct {
+	Store     storeAPI
+	Pub       publisherAPI
+	Interval  time.Duration
+	BatchSize int
+}
+
+func NewRelay(st *store.Store, pub *rmq.Publisher, interval time.Duration) *Relay {
+	return &Relay{Store: st, Pub: pub, Interval: interval, BatchSize: 500}
+}
+
+func (r *Relay) Run(ctx context.Context) {
+	logx.L().Infow("outbox_relay_started", "interval", r.Interval.String())
+	t := time.NewTicker(r.Interval)
+	defer t.Stop()
+
+	for {
+		// пока outbox отдаёт полные пачки, не ждём следующего тика
+		for {
+			n, err := r.Tick(ctx)
+			if err != nil && !errors.Is(err, context.Canceled) {
+				logx.L().Errorw("outbox_relay_error", "error", err)
+			}
+			if err != nil || n < r.BatchSize {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			logx.L().Infow("outbox_relay_stopped")
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Tick публикует одну пачку и возвращает число отправленных записей.
+func (r *Relay) Tick(ctx context.Context) (int, error) {
+	dispatched := 0
+	var pubErr error
+	err := r.Store.WithTx(ctx, func(tx *sql.Tx) error {
+		rows, err := r.Store.ClaimOutbox(ctx, tx, r.BatchSize)
+		if err != nil {
+			return err
+		}
+
+		bodies := make([][]byte, len(rows))
+		for i, row := range rows {
+			bodies[i] = row.Payload
+		}
+		pubCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		n, err := r.Pub.PublishBatch(pubCtx, bodies)
+		cancel()
+		metrics.PublishedJobsTotal.Add(float64(n))
+		if err != nil {
+			pubErr = err
+			metrics.OutboxPublishErrors.Inc()
+			if err := r.Store.RecordOutboxError(ctx, tx, rows[n].ID, err.Error()); err != nil {
+				return err
+			}
+		}
+
+		ids := make([]int64, n)
+		for i, row := range rows[:n] {
+			ids[i] = row.ID
+		}
+		if err := r.Store.MarkOutboxDispatched(ctx, tx, ids); err != nil {
+			return err
+		}
+		dispatched = len(ids)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return dispatched, pubErr
+}
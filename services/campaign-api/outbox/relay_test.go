@@ -0,0 +1,132 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/Mutter0815/MassMailer/internal/store"
+)
+
+type fakeStore struct {
+	rows       []store.OutboxRow
+	dispatched map[int64]bool
+	errors     map[int64]string
+}
+
+func (f *fakeStore) WithTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	return fn(&sql.Tx{})
+}
+
+func (f *fakeStore) ClaimOutbox(ctx context.Context, tx *sql.Tx, limit int) ([]store.OutboxRow, error) {
+	var out []store.OutboxRow
+	for _, r := range f.rows {
+		if !f.dispatched[r.ID] && len(out) < limit {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeStore) MarkOutboxDispatched(ctx context.Context, tx *sql.Tx, ids []int64) error {
+	for _, id := range ids {
+		f.dispatched[id] = true
+	}
+	return nil
+}
+
+func (f *fakeStore) RecordOutboxError(ctx context.Context, tx *sql.Tx, id int64, lastErr string) error {
+	f.errors[id] = lastErr
+	return nil
+}
+
+type fakePublisher struct {
+	published []string
+	down      bool
+	// nackAfter — сколько сообщений брокер подтвердит перед отказом; 0 — без отказа.
+	nackAfter int
+}
+
+func (p *fakePublisher) PublishBatch(ctx context.Context, bodies [][]byte) (int, error) {
+	if p.down {
+		return 0, errors.New("broker down")
+	}
+	for i, body := range bodies {
+		if p.nackAfter > 0 && len(p.published) == p.nackAfter {
+			return i, errors.New("nacked")
+		}
+		p.published = append(p.published, string(body))
+	}
+	return len(bodies), nil
+}
+
+func newFakeStore(n int) *fakeStore {
+	fs := &fakeStore{dispatched: map[int64]bool{}, errors: map[int64]string{}}
+	for i := 1; i <= n; i++ {
+		fs.rows = append(fs.rows, store.OutboxRow{ID: int64(i), Payload: []byte{byte('0' + i)}})
+	}
+	return fs
+}
+
+func TestRelay_DispatchesInBatches(t *testing.T) {
+	fs := newFakeStore(5)
+	fp := &fakePublisher{}
+	r := &Relay{Store: fs, Pub: fp, BatchSize: 3}
+
+	if n, err := r.Tick(context.Background()); err != nil || n != 3 {
+		t.Fatalf("first tick: n=%d err=%v", n, err)
+	}
+	if n, err := r.Tick(context.Background()); err != nil || n != 2 {
+		t.Fatalf("second tick: n=%d err=%v", n, err)
+	}
+	if n, _ := r.Tick(context.Background()); n != 0 {
+		t.Fatalf("outbox must be empty, got %d", n)
+	}
+	if len(fp.published) != 5 || fp.published[0] != "1" || fp.published[4] != "5" {
+		t.Fatalf("unexpected publish order: %v", fp.published)
+	}
+}
+
+func TestRelay_BrokerDownKeepsRows(t *testing.T) {
+	fs := newFakeStore(2)
+	fp := &fakePublisher{down: true}
+	r := &Relay{Store: fs, Pub: fp, BatchSize: 10}
+
+	if _, err := r.Tick(context.Background()); err == nil {
+		t.Fatal("expected publish error")
+	}
+	if len(fs.dispatched) != 0 {
+		t.Fatalf("nothing must be marked dispatched, got %v", fs.dispatched)
+	}
+	if fs.errors[1] == "" {
+		t.Fatal("publish error must be recorded on the row")
+	}
+
+	fp.down = false
+	if n, err := r.Tick(context.Background()); err != nil || n != 2 {
+		t.Fatalf("retry after recovery: n=%d err=%v", n, err)
+	}
+}
+
+func TestRelay_PartialBatchMarksConfirmedPrefix(t *testing.T) {
+	fs := newFakeStore(4)
+	fp := &fakePublisher{nackAfter: 2}
+	r := &Relay{Store: fs, Pub: fp, BatchSize: 10}
+
+	n, err := r.Tick(context.Background())
+	if err == nil || n != 2 {
+		t.Fatalf("want 2 dispatched and an error, got n=%d err=%v", n, err)
+	}
+	if !fs.dispatched[1] || !fs.dispatched[2] || fs.dispatched[3] || fs.dispatched[4] {
+		t.Fatalf("only the confirmed prefix must be dispatched: %v", fs.dispatched)
+	}
+	if fs.errors[3] == "" {
+		t.Fatal("error must be recorded on the first unconfirmed row")
+	}
+
+	fp.nackAfter = 0
+	if n, err := r.Tick(context.Background()); err != nil || n != 2 {
+		t.Fatalf("retry after recovery: n=%d err=%v", n, err)
+	}
+}
@@ -0,0 +1,97 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Mutter0815/MassMailer/internal/campaign"
+	"github.com/Mutter0815/MassMailer/internal/store"
+	"github.com/Mutter0815/MassMailer/pkg/logx"
+)
+
+type storeAPI interface {
+	WithTx(ctx context.Context, fn func(tx *sql.Tx) error) error
+	ClaimDueCampaign(ctx context.Context, tx *sql.Tx, now time.Time) (int64, error)
+	EnqueuePendingJobs(ctx context.Context, tx *sql.Tx, campaignID int64) (int, error)
+	SetCampaignStatus(ctx context.Context, q store.Querier, id int64, to string) error
+}
+
+// Scheduler периодически забирает queued-кампании, у которых наступил
+// scheduled_at, кладёт их задания в outbox и переводит кампанию в processing.
+type Scheduler struct {
+	Store    storeAPI
+	Interval time.Duration
+	// MaxPerTick ограничивает число кампаний, запускаемых за один проход.
+	MaxPerTick int
+	Now        func() time.Time
+}
+
+func New(st *store.Store, interval time.Duration) *Scheduler {
+	return &Scheduler{Store: st, Interval: interval, MaxPerTick: 10, Now: time.Now}
+}
+
+func (s *Scheduler) Run(ctx context.Context) {
+	logx.L().Infow("scheduler_started", "interval", s.Interval.String())
+	t := time.NewTicker(s.Interval)
+	defer t.Stop()
+
+	for {
+		if _, err := s.Tick(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			logx.L().Errorw("scheduler_tick_error", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			logx.L().Infow("scheduler_stopped")
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Tick запускает все наступившие кампании (не больше MaxPerTick) и
+// возвращает число запущенных.
+func (s *Scheduler) Tick(ctx context.Context) (int, error) {
+	started := 0
+	for started < s.MaxPerTick {
+		ok, err := s.startNext(ctx)
+		if err != nil {
+			return started, err
+		}
+		if !ok {
+			break
+		}
+		started++
+	}
+	return started, nil
+}
+
+// startNext обрабатывает одну кампанию в отдельной транзакции: строка
+// кампании остаётся заблокированной до коммита, поэтому параллельный
+// планировщик её не увидит, а задания попадают в outbox атомарно со сменой статуса.
+func (s *Scheduler) startNext(ctx context.Context) (bool, error) {
+	found := false
+	err := s.Store.WithTx(ctx, func(tx *sql.Tx) error {
+		id, err := s.Store.ClaimDueCampaign(ctx, tx, s.Now())
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		found = true
+
+		n, err := s.Store.EnqueuePendingJobs(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		if err := s.Store.SetCampaignStatus(ctx, tx, id, campaign.StatusProcessing); err != nil {
+			return err
+		}
+		logx.L().Infow("campaign_started", "campaign_id", id, "jobs", n)
+		return nil
+	})
+	return found, err
+}
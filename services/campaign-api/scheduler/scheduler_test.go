@@ -0,0 +1,164 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/Mutter0815/MassMailer/internal/campaign"
+	"github.com/Mutter0815/MassMailer/internal/store"
+)
+
+type fakeCampaign struct {
+	id          int64
+	scheduledAt time.Time
+	status      string
+	jobs        []campaign.JobMessage
+}
+
+// fakeStore имитирует транзакции: статусы и outbox применяются только при успешном fn.
+type fakeStore struct {
+	campaigns []*fakeCampaign
+	failOn    int64
+
+	outbox        []campaign.JobMessage
+	pending       map[int64]string
+	pendingOutbox []campaign.JobMessage
+}
+
+func (f *fakeStore) WithTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	f.pending = map[int64]string{}
+	f.pendingOutbox = nil
+	if err := fn(&sql.Tx{}); err != nil {
+		return err
+	}
+	for id, st := range f.pending {
+		f.byID(id).status = st
+	}
+	f.outbox = append(f.outbox, f.pendingOutbox...)
+	return nil
+}
+
+func (f *fakeStore) byID(id int64) *fakeCampaign {
+	for _, c := range f.campaigns {
+		if c.id == id {
+			return c
+		}
+	}
+	return nil
+}
+
+func (f *fakeStore) ClaimDueCampaign(ctx context.Context, tx *sql.Tx, now time.Time) (int64, error) {
+	due := make([]*fakeCampaign, 0)
+	for _, c := range f.campaigns {
+		if c.status == "queued" && !c.scheduledAt.After(now) {
+			due = append(due, c)
+		}
+	}
+	if len(due) == 0 {
+		return 0, sql.ErrNoRows
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].scheduledAt.Before(due[j].scheduledAt) })
+	return due[0].id, nil
+}
+
+func (f *fakeStore) EnqueuePendingJobs(ctx context.Context, tx *sql.Tx, campaignID int64) (int, error) {
+	if campaignID == f.failOn {
+		return 0, errors.New("outbox insert failed")
+	}
+	jobs := f.byID(campaignID).jobs
+	f.pendingOutbox = append(f.pendingOutbox, jobs...)
+	return len(jobs), nil
+}
+
+func (f *fakeStore) SetCampaignStatus(ctx context.Context, q store.Querier, id int64, to string) error {
+	if !campaign.CanTransition(f.byID(id).status, to) {
+		return campaign.ErrInvalidTransition
+	}
+	f.pending[id] = to
+	return nil
+}
+
+func jobsFor(campaignID int64, n int) []campaign.JobMessage {
+	out := make([]campaign.JobMessage, n)
+	for i := range out {
+		out[i] = campaign.JobMessage{CampaignID: campaignID, RecipientID: campaignID*100 + int64(i), Address: "u@example.com"}
+	}
+	return out
+}
+
+func TestTick_PublishesOnlyDueCampaigns(t *testing.T) {
+	now := time.Date(2025, 10, 2, 12, 0, 0, 0, time.UTC)
+	fs := &fakeStore{campaigns: []*fakeCampaign{
+		{id: 1, scheduledAt: now.Add(-time.Minute), status: "queued", jobs: jobsFor(1, 2)},
+		{id: 2, scheduledAt: now.Add(time.Hour), status: "queued", jobs: jobsFor(2, 3)},
+		{id: 3, scheduledAt: now.Add(-time.Hour), status: "processing", jobs: jobsFor(3, 1)},
+	}}
+	s := &Scheduler{Store: fs, MaxPerTick: 10, Now: func() time.Time { return now }}
+
+	n, err := s.Tick(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("want 1 campaign started, got %d", n)
+	}
+	if len(fs.outbox) != 2 || fs.outbox[0].CampaignID != 1 {
+		t.Fatalf("unexpected outbox jobs: %+v", fs.outbox)
+	}
+	if got := fs.byID(1).status; got != "processing" {
+		t.Fatalf("campaign 1 status=%s", got)
+	}
+	if got := fs.byID(2).status; got != "queued" {
+		t.Fatalf("campaign 2 must stay queued, got %s", got)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if n, err := s.Tick(context.Background()); err != nil || n != 1 {
+		t.Fatalf("second tick: n=%d err=%v", n, err)
+	}
+	if len(fs.outbox) != 5 {
+		t.Fatalf("want 5 outbox jobs total, got %d", len(fs.outbox))
+	}
+	if got := fs.byID(2).status; got != "processing" {
+		t.Fatalf("campaign 2 status=%s", got)
+	}
+
+	if n, _ := s.Tick(context.Background()); n != 0 {
+		t.Fatalf("nothing should be started again, got %d", n)
+	}
+}
+
+func TestTick_EnqueueErrorKeepsCampaignQueued(t *testing.T) {
+	now := time.Date(2025, 10, 2, 12, 0, 0, 0, time.UTC)
+	fs := &fakeStore{failOn: 1, campaigns: []*fakeCampaign{
+		{id: 1, scheduledAt: now, status: "queued", jobs: jobsFor(1, 3)},
+	}}
+	s := &Scheduler{Store: fs, MaxPerTick: 10, Now: func() time.Time { return now }}
+
+	if _, err := s.Tick(context.Background()); err == nil {
+		t.Fatal("expected enqueue error")
+	}
+	if got := fs.byID(1).status; got != "queued" {
+		t.Fatalf("campaign must stay queued after failed enqueue, got %s", got)
+	}
+	if len(fs.outbox) != 0 {
+		t.Fatalf("outbox must be rolled back, got %d rows", len(fs.outbox))
+	}
+}
+
+func TestTick_RespectsMaxPerTick(t *testing.T) {
+	now := time.Date(2025, 10, 2, 12, 0, 0, 0, time.UTC)
+	fs := &fakeStore{}
+	for i := int64(1); i <= 5; i++ {
+		fs.campaigns = append(fs.campaigns, &fakeCampaign{id: i, scheduledAt: now, status: "queued", jobs: jobsFor(i, 1)})
+	}
+	s := &Scheduler{Store: fs, MaxPerTick: 3, Now: func() time.Time { return now }}
+
+	if n, err := s.Tick(context.Background()); err != nil || n != 3 {
+		t.Fatalf("want 3 started, got n=%d err=%v", n, err)
+	}
+}
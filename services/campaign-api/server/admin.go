@@ -0,0 +1,134 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Mutter0815/MassMailer/pkg/logx"
+	"github.com/Mutter0815/MassMailer/pkg/rmq"
+)
+
+type dlqAPI interface {
+	List(limit int) ([]rmq.DeadLetter, error)
+	Get(id string) (rmq.DeadLetter, error)
+	Replay(ctx context.Context, id string) error
+	ReplayAll(ctx context.Context) (int, error)
+	Delete(id string) error
+	Purge() (int, error)
+}
+
+type deadLetterResp struct {
+	ID            string         `json:"id"`
+	Reason        string         `json:"reason"`
+	FailedAt      *time.Time     `json:"failed_at,omitempty"`
+	OriginalQueue string         `json:"original_queue,omitempty"`
+	Headers       map[string]any `json:"headers"`
+	Body          string         `json:"body"`
+}
+
+func toDeadLetterResp(dl rmq.DeadLetter) deadLetterResp {
+	resp := deadLetterResp{
+		ID:            dl.ID,
+		Reason:        dl.Reason,
+		OriginalQueue: dl.OriginalQueue,
+		Headers:       map[string]any(dl.Headers),
+		Body:          string(dl.Body),
+	}
+	if !dl.FailedAt.IsZero() {
+		resp.FailedAt = &dl.FailedAt
+	}
+	return resp
+}
+
+func (h *Handlers) ListDeadLetters(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit <= 0 || limit > 1000 {
+		limit = 50
+	}
+
+	list, err := h.DLQ.List(limit)
+	if err != nil {
+		logx.L().Errorw("dlq_list_error", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "dlq error"})
+		return
+	}
+
+	out := make([]deadLetterResp, 0, len(list))
+	for _, dl := range list {
+		out = append(out, toDeadLetterResp(dl))
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+func (h *Handlers) GetDeadLetter(c *gin.Context) {
+	dl, err := h.DLQ.Get(c.Param("dlq_id"))
+	if h.dlqError(c, "dlq_get_error", err) {
+		return
+	}
+	c.JSON(http.StatusOK, toDeadLetterResp(dl))
+}
+
+func (h *Handlers) ReplayDeadLetter(c *gin.Context) {
+	id := c.Param("dlq_id")
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	if h.dlqError(c, "dlq_replay_error", h.DLQ.Replay(ctx, id)) {
+		return
+	}
+	logx.L().Infow("dlq_replayed", "id", id)
+	c.JSON(http.StatusOK, gin.H{"replayed": 1})
+}
+
+func (h *Handlers) ReplayAllDeadLetters(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 60*time.Second)
+	defer cancel()
+
+	n, err := h.DLQ.ReplayAll(ctx)
+	if err != nil {
+		logx.L().Errorw("dlq_replay_error", "replayed", n, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "dlq error", "replayed": n})
+		return
+	}
+	logx.L().Infow("dlq_replayed", "count", n)
+	c.JSON(http.StatusOK, gin.H{"replayed": n})
+}
+
+func (h *Handlers) DeleteDeadLetter(c *gin.Context) {
+	id := c.Param("dlq_id")
+	if h.dlqError(c, "dlq_delete_error", h.DLQ.Delete(id)) {
+		return
+	}
+	logx.L().Infow("dlq_deleted", "id", id)
+	c.Status(http.StatusNoContent)
+}
+
+func (h *Handlers) PurgeDeadLetters(c *gin.Context) {
+	n, err := h.DLQ.Purge()
+	if err != nil {
+		logx.L().Errorw("dlq_purge_error", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "dlq error"})
+		return
+	}
+	logx.L().Infow("dlq_purged", "count", n)
+	c.JSON(http.StatusOK, gin.H{"purged": n})
+}
+
+// dlqError отвечает клиенту, если err != nil, и сообщает, что ответ уже отправлен.
+func (h *Handlers) dlqError(c *gin.Context, event string, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, rmq.ErrDeadLetterNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "dead letter not found"})
+	default:
+		logx.L().Errorw(event, "id", c.Param("dlq_id"), "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "dlq error"})
+	}
+	return true
+}
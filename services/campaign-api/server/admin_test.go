@@ -0,0 +1,130 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+
+	"github.com/Mutter0815/MassMailer/pkg/rmq"
+)
+
+type fakeDLQ struct {
+	items    []rmq.DeadLetter
+	replayed []string
+}
+
+func (f *fakeDLQ) List(limit int) ([]rmq.DeadLetter, error) {
+	if len(f.items) > limit {
+		return f.items[:limit], nil
+	}
+	return f.items, nil
+}
+
+func (f *fakeDLQ) Get(id string) (rmq.DeadLetter, error) {
+	for _, dl := range f.items {
+		if dl.ID == id {
+			return dl, nil
+		}
+	}
+	return rmq.DeadLetter{}, rmq.ErrDeadLetterNotFound
+}
+
+func (f *fakeDLQ) Replay(ctx context.Context, id string) error {
+	if err := f.Delete(id); err != nil {
+		return err
+	}
+	f.replayed = append(f.replayed, id)
+	return nil
+}
+
+func (f *fakeDLQ) ReplayAll(ctx context.Context) (int, error) {
+	n := len(f.items)
+	for _, dl := range f.items {
+		f.replayed = append(f.replayed, dl.ID)
+	}
+	f.items = nil
+	return n, nil
+}
+
+func (f *fakeDLQ) Delete(id string) error {
+	for i, dl := range f.items {
+		if dl.ID == id {
+			f.items = append(f.items[:i], f.items[i+1:]...)
+			return nil
+		}
+	}
+	return rmq.ErrDeadLetterNotFound
+}
+
+func (f *fakeDLQ) Purge() (int, error) {
+	n := len(f.items)
+	f.items = nil
+	return n, nil
+}
+
+func newDLQServer() (*http.Server, *fakeDLQ) {
+	dlq := &fakeDLQ{items: []rmq.DeadLetter{
+		{
+			ID:       "a1",
+			Reason:   "retries_exhausted: smtp rcpt: 451",
+			FailedAt: time.Date(2025, 10, 2, 12, 0, 0, 0, time.UTC),
+			Headers:  amqp.Table{"x-retries": int32(3)},
+			Body:     []byte(`{"campaign_id":7}`),
+		},
+		{ID: "b2", Reason: "malformed_payload: invalid character", Body: []byte(`not json`)},
+	}}
+	return NewHTTPServer(":0", &Handlers{Store: &fakeStore{}, DLQ: dlq}), dlq
+}
+
+func doRequest(srv *http.Server, method, path string) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rr, httptest.NewRequest(method, path, nil))
+	return rr
+}
+
+func TestDeadLetters_ListAndInspect(t *testing.T) {
+	srv, _ := newDLQServer()
+
+	rr := doRequest(srv, http.MethodGet, "/admin/dlq")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("list: status=%d body=%s", rr.Code, rr.Body.String())
+	}
+	var list []deadLetterResp
+	if err := json.Unmarshal(rr.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].Reason == "" || list[0].FailedAt == nil || list[1].Body != "not json" {
+		t.Fatalf("unexpected list: %+v", list)
+	}
+
+	if rr := doRequest(srv, http.MethodGet, "/admin/dlq/a1"); rr.Code != http.StatusOK {
+		t.Fatalf("inspect: status=%d", rr.Code)
+	}
+	if rr := doRequest(srv, http.MethodGet, "/admin/dlq/zz"); rr.Code != http.StatusNotFound {
+		t.Fatalf("inspect missing: status=%d", rr.Code)
+	}
+}
+
+func TestDeadLetters_ReplayAndPurge(t *testing.T) {
+	srv, dlq := newDLQServer()
+
+	if rr := doRequest(srv, http.MethodPost, "/admin/dlq/a1/replay"); rr.Code != http.StatusOK {
+		t.Fatalf("replay: status=%d", rr.Code)
+	}
+	if len(dlq.replayed) != 1 || dlq.replayed[0] != "a1" || len(dlq.items) != 1 {
+		t.Fatalf("a1 must be replayed: replayed=%v left=%d", dlq.replayed, len(dlq.items))
+	}
+	if rr := doRequest(srv, http.MethodPost, "/admin/dlq/a1/replay"); rr.Code != http.StatusNotFound {
+		t.Fatalf("replay twice: status=%d", rr.Code)
+	}
+
+	rr := doRequest(srv, http.MethodDelete, "/admin/dlq")
+	if rr.Code != http.StatusOK || rr.Body.String() != `{"purged":1}` {
+		t.Fatalf("purge: status=%d body=%s", rr.Code, rr.Body.String())
+	}
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func campaignWithRecipients(n int) []byte {
+	var b bytes.Buffer
+	b.WriteString(`{"name":"bulk","body":"Hi {{.first_name}}","scheduled_at":"2025-10-02T12:00:00Z","recipients":[`)
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `{"address":"user%d@example.com","vars":{"first_name":"U%d"}}`, i, i)
+	}
+	b.WriteString(`]}`)
+	return b.Bytes()
+}
+
+// BenchmarkCreateCampaign_100k измеряет разбор, проверку и передачу в store
+// кампании на 100 тысяч получателей; стоимость самой записи в БД меряет
+// BenchmarkInsertRecipients_100k в internal/store.
+func BenchmarkCreateCampaign_100k(b *testing.B) {
+	body := campaignWithRecipients(100_000)
+	b.SetBytes(int64(len(body)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		fs := &fakeStore{}
+		srv := NewHTTPServer(":0", &Handlers{Store: fs, Retry: testRetry})
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/campaigns", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+
+		start := time.Now()
+		srv.Handler.ServeHTTP(rr, req)
+		if elapsed := time.Since(start); elapsed > createTimeout {
+			b.Fatalf("request took %s, budget is %s", elapsed, createTimeout)
+		}
+		if rr.Code != http.StatusOK {
+			b.Fatalf("status=%d, body=%s", rr.Code, rr.Body.String())
+		}
+		if fs.recipientsN != 100_000 || fs.recipientCalls != 1 {
+			b.Fatalf("want 100000 recipients in one call, got %d in %d", fs.recipientsN, fs.recipientCalls)
+		}
+	}
+}
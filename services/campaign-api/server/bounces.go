@@ -0,0 +1,111 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Mutter0815/MassMailer/internal/campaign"
+	"github.com/Mutter0815/MassMailer/internal/feedback"
+	"github.com/Mutter0815/MassMailer/internal/store"
+	"github.com/Mutter0815/MassMailer/pkg/logx"
+)
+
+// maxReportBytes ограничивает размер принимаемого отчёта: DSN с копией
+// большого письма бывает в несколько мегабайт.
+const maxReportBytes = 10 << 20
+
+// reportTarget находит письмо, к которому относится отчёт: по адресам
+// возврата VERP в порядке доверия, затем по подписанному Message-ID
+// исходного письма, если отчёт вернул его заголовки.
+func (h *Handlers) reportTarget(addrs []string, messageID string) (campaignID, recipientID int64, ok bool) {
+	for _, addr := range addrs {
+		if addr == "" {
+			continue
+		}
+		if c, r, err := h.Links.ParseVERP(addr); err == nil {
+			return c, r, true
+		}
+	}
+	if messageID != "" {
+		if c, r, err := h.Links.ParseMessageID(messageID); err == nil {
+			return c, r, true
+		}
+	}
+	return 0, 0, false
+}
+
+// IngestBounce принимает уведомление о недоставке (RFC 3464) целиком, как
+// оно пришло на адрес возврата. Постоянный отказ помечает письмо bounced
+// и при повторах блокирует адрес, временный только учитывается; отчёты
+// об успешной доставке игнорируются с ответом 204.
+func (h *Handlers) IngestBounce(c *gin.Context) {
+	if h.Links == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "bounce processing is disabled: LINK_SECRET is not set"})
+		return
+	}
+	dsn, err := feedback.ParseDSN(http.MaxBytesReader(c.Writer, c.Request.Body, maxReportBytes))
+	if err != nil {
+		var tooBig *http.MaxBytesError
+		if errors.As(err, &tooBig) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "report is too large"})
+			return
+		}
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	// rcpt — получатель конверта, если MTA его передал; в неэкранированном
+	// rcpt «+» из VERP превращается в пробел
+	rcpt := strings.ReplaceAll(c.Query("rcpt"), " ", "+")
+	campaignID, recipientID, ok := h.reportTarget(append([]string{rcpt}, dsn.To...), dsn.OriginalMessageID)
+	if !ok {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "report does not reference a message sent by us"})
+		return
+	}
+	failure, ok := dsn.Failure()
+	if !ok {
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	typ := campaign.EventSoftBounce
+	if failure.Bounce() == feedback.BounceHard {
+		typ = campaign.EventHardBounce
+	}
+	res, err := h.Store.RecordBounce(c.Request.Context(), store.MessageEvent{
+		CampaignID:  campaignID,
+		RecipientID: recipientID,
+		Type:        typ,
+		Status:      failure.Status,
+		Detail:      failure.DiagnosticCode,
+		ReportID:    dsn.ReportID(),
+	}, max(h.BounceSuppressAfter, 1))
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "message not found"})
+		return
+	}
+	if err != nil {
+		logx.L().Errorw("db_record_bounce_error", "campaign_id", campaignID, "recipient_id", recipientID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error"})
+		return
+	}
+
+	if res.Duplicate {
+		logx.L().Infow("bounce_duplicate", "campaign_id", campaignID, "recipient_id", recipientID, "report_id", dsn.ReportID())
+	} else {
+		logx.L().Infow("bounce_recorded", "campaign_id", campaignID, "recipient_id", recipientID,
+			"type", typ, "status", failure.Status, "hard_bounces", res.HardBounces, "suppressed", res.Suppressed)
+	}
+	c.JSON(http.StatusOK, campaign.BounceResp{
+		CampaignID:  campaignID,
+		RecipientID: recipientID,
+		Type:        typ,
+		Status:      failure.Status,
+		HardBounces: res.HardBounces,
+		Suppressed:  res.Suppressed,
+		Duplicate:   res.Duplicate,
+	})
+}
@@ -0,0 +1,197 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Mutter0815/MassMailer/internal/campaign"
+	"github.com/Mutter0815/MassMailer/internal/store"
+	"github.com/Mutter0815/MassMailer/internal/token"
+)
+
+func (f *fakeStore) RecordBounce(ctx context.Context, e store.MessageEvent, suppressAfter int) (store.BounceResult, error) {
+	if e.CampaignID != 42 || e.RecipientID < 1 || int(e.RecipientID) > len(f.recipients) {
+		return store.BounceResult{}, sql.ErrNoRows
+	}
+	addr := strings.ToLower(f.recipients[e.RecipientID-1].Address)
+	for _, ev := range f.events {
+		if e.ReportID != "" && ev.ReportID == e.ReportID && ev.RecipientID == e.RecipientID {
+			_, suppressed := f.suppressions[addr]
+			return store.BounceResult{Address: addr, HardBounces: f.hardBounces(addr), Suppressed: suppressed, Duplicate: true}, nil
+		}
+	}
+	f.events = append(f.events, e)
+	hard := f.hardBounces(addr)
+	res := store.BounceResult{Address: addr, HardBounces: hard}
+	if e.Type == campaign.EventHardBounce && hard >= suppressAfter {
+		if f.suppressions == nil {
+			f.suppressions = map[string]store.Suppression{}
+		}
+		f.suppressions[addr] = store.Suppression{Address: addr, Reason: campaign.SuppressionHardBounce, Source: "bounce"}
+		res.Suppressed = true
+	}
+	return res, nil
+}
+
+func (f *fakeStore) hardBounces(addr string) int {
+	n := 0
+	for _, ev := range f.events {
+		if ev.Type == campaign.EventHardBounce && strings.EqualFold(f.recipients[ev.RecipientID-1].Address, addr) {
+			n++
+		}
+	}
+	return n
+}
+
+func dsnReport(to, action, status string) string {
+	return strings.ReplaceAll(`From: MAILER-DAEMON@mx.example.net
+To: `+to+`
+Subject: Undelivered Mail Returned to Sender
+MIME-Version: 1.0
+Content-Type: multipart/report; report-type=delivery-status; boundary=B
+
+--B
+Content-Type: text/plain
+
+Delivery failed.
+--B
+Content-Type: message/delivery-status
+
+Reporting-MTA: dns; mx.example.net
+
+Final-Recipient: rfc822; ann@example.org
+Action: `+action+`
+Status: `+status+`
+Diagnostic-Code: smtp; 550 `+status+` nope
+--B--
+`, "\n", "\r\n")
+}
+
+func postBounce(h *Handlers, query, body string) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/bounces"+query, strings.NewReader(body))
+	req.Header.Set("Content-Type", "message/rfc822")
+	NewHTTPServer(":0", h).Handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestIngestBounce_HardBouncesSuppressAfterLimit(t *testing.T) {
+	links := token.NewSigner("secret")
+	fs := &fakeStore{recipients: []store.NewRecipient{{Address: "Ann@example.org"}}}
+	h := &Handlers{Store: fs, Links: links, BounceSuppressAfter: 2}
+	verp := links.VERP("bounces@mail.example.com", 42, 1)
+
+	for i, wantSuppressed := range []bool{false, true} {
+		rr := postBounce(h, "", dsnReport(verp, "failed", "5.1.1"))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("#%d: status=%d, body=%s", i+1, rr.Code, rr.Body.String())
+		}
+		var resp campaign.BounceResp
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		want := campaign.BounceResp{CampaignID: 42, RecipientID: 1, Type: campaign.EventHardBounce,
+			Status: "5.1.1", HardBounces: i + 1, Suppressed: wantSuppressed}
+		if resp != want {
+			t.Fatalf("#%d: got %+v, want %+v", i+1, resp, want)
+		}
+	}
+	if fs.events[0].Detail != "550 5.1.1 nope" {
+		t.Fatalf("unexpected detail %q", fs.events[0].Detail)
+	}
+	if _, ok := fs.suppressions["ann@example.org"]; !ok {
+		t.Fatal("address must be suppressed")
+	}
+}
+
+func TestIngestBounce_SoftAndIgnored(t *testing.T) {
+	links := token.NewSigner("secret")
+	fs := &fakeStore{recipients: []store.NewRecipient{{Address: "ann@example.org"}}}
+	h := &Handlers{Store: fs, Links: links, BounceSuppressAfter: 1}
+	verp := links.VERP("bounces@mail.example.com", 42, 1)
+
+	// получатель конверта из rcpt важнее заголовков
+	rr := postBounce(h, "?rcpt="+verp, dsnReport("postmaster@mail.example.com", "delayed", "4.4.1"))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"type":"soft_bounce"`) {
+		t.Fatalf("soft: status=%d, body=%s", rr.Code, rr.Body.String())
+	}
+	if rr := postBounce(h, "", dsnReport(verp, "delivered", "2.0.0")); rr.Code != http.StatusNoContent {
+		t.Fatalf("delivered: want 204, got %d", rr.Code)
+	}
+	if len(fs.events) != 1 || len(fs.suppressions) != 0 {
+		t.Fatalf("unexpected state: events=%+v suppressions=%+v", fs.events, fs.suppressions)
+	}
+}
+
+func TestIngestBounce_Rejects(t *testing.T) {
+	links := token.NewSigner("secret")
+	fs := &fakeStore{recipients: []store.NewRecipient{{Address: "ann@example.org"}}}
+	h := &Handlers{Store: fs, Links: links}
+
+	cases := map[string]struct {
+		h    *Handlers
+		body string
+		want int
+	}{
+		"not a report":    {h, "Subject: hi\r\n\r\nhello\r\n", http.StatusUnprocessableEntity},
+		"foreign address": {h, dsnReport("someone@example.com", "failed", "5.1.1"), http.StatusUnprocessableEntity},
+		"forged token":    {h, dsnReport(token.NewSigner("x").VERP("b@example.com", 42, 1), "failed", "5.1.1"), http.StatusUnprocessableEntity},
+		"unknown message": {h, dsnReport(links.VERP("b@example.com", 42, 9), "failed", "5.1.1"), http.StatusNotFound},
+		"disabled":        {&Handlers{Store: fs}, dsnReport(links.VERP("b@example.com", 42, 1), "failed", "5.1.1"), http.StatusNotFound},
+	}
+	for name, tc := range cases {
+		if rr := postBounce(tc.h, "", tc.body); rr.Code != tc.want {
+			t.Errorf("%s: want %d, got %d (%s)", name, tc.want, rr.Code, rr.Body.String())
+		}
+	}
+	if len(fs.events) != 0 {
+		t.Fatalf("nothing must be recorded: %+v", fs.events)
+	}
+}
+
+func TestIngestBounce_ByMessageID(t *testing.T) {
+	links := token.NewSigner("secret")
+	fs := &fakeStore{recipients: []store.NewRecipient{{Address: "ann@example.org"}}}
+	h := &Handlers{Store: fs, Links: links, BounceSuppressAfter: 1}
+
+	// отчёт ушёл не на VERP-адрес, но вернул заголовки письма
+	body := strings.Replace(dsnReport("postmaster@mail.example.com", "failed", "5.1.1"), "--B--",
+		"--B\r\nContent-Type: text/rfc822-headers\r\n\r\nMessage-ID: <"+links.MessageID("example.com", 42, 1)+">\r\n\r\n--B--", 1)
+	if rr := postBounce(h, "", body); rr.Code != http.StatusOK {
+		t.Fatalf("status=%d, body=%s", rr.Code, rr.Body.String())
+	}
+	if len(fs.events) != 1 || fs.events[0].RecipientID != 1 {
+		t.Fatalf("unexpected events: %+v", fs.events)
+	}
+}
+
+func TestIngestBounce_DuplicateReportCountedOnce(t *testing.T) {
+	links := token.NewSigner("secret")
+	fs := &fakeStore{recipients: []store.NewRecipient{{Address: "ann@example.org"}}}
+	h := &Handlers{Store: fs, Links: links, BounceSuppressAfter: 2}
+	verp := links.VERP("bounces@mail.example.com", 42, 1)
+	body := strings.Replace(dsnReport(verp, "failed", "5.1.1"), "Subject:",
+		"Message-ID: <20251002120001.4F2A1@mx.example.net>\r\nSubject:", 1)
+
+	for i, wantDuplicate := range []bool{false, true} {
+		rr := postBounce(h, "", body)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("#%d: status=%d, body=%s", i+1, rr.Code, rr.Body.String())
+		}
+		var resp campaign.BounceResp
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Duplicate != wantDuplicate || resp.HardBounces != 1 || resp.Suppressed {
+			t.Fatalf("#%d: unexpected response %+v", i+1, resp)
+		}
+	}
+	if len(fs.events) != 1 || fs.events[0].ReportID != "20251002120001.4F2A1@mx.example.net" {
+		t.Fatalf("want one event keyed by the report Message-ID, got %+v", fs.events)
+	}
+}
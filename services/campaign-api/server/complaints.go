@@ -0,0 +1,65 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Mutter0815/MassMailer/internal/campaign"
+	"github.com/Mutter0815/MassMailer/internal/feedback"
+	"github.com/Mutter0815/MassMailer/pkg/logx"
+	"github.com/Mutter0815/MassMailer/pkg/metrics"
+)
+
+// IngestComplaint принимает отчёт feedback loop в формате ARF (RFC 5965)
+// целиком, как он пришёл от почтового провайдера. Жалоба (abuse, fraud)
+// записывается событием complained и бессрочно блокирует адрес; прочие
+// виды отчётов игнорируются с ответом 204.
+func (h *Handlers) IngestComplaint(c *gin.Context) {
+	if h.Links == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "complaint processing is disabled: LINK_SECRET is not set"})
+		return
+	}
+	arf, err := feedback.ParseARF(http.MaxBytesReader(c.Writer, c.Request.Body, maxReportBytes))
+	if err != nil {
+		var tooBig *http.MaxBytesError
+		if errors.As(err, &tooBig) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "report is too large"})
+			return
+		}
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	campaignID, recipientID, ok := h.reportTarget([]string{arf.OriginalMailFrom, arf.OriginalReturnPath}, arf.OriginalMessageID)
+	if !ok {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "report does not reference a message sent by us"})
+		return
+	}
+	if !arf.Complaint() {
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	addr, err := h.Store.RecordComplaint(c.Request.Context(), campaignID, recipientID, arf.FeedbackType, arf.UserAgent)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "message not found"})
+		return
+	}
+	if err != nil {
+		logx.L().Errorw("db_record_complaint_error", "campaign_id", campaignID, "recipient_id", recipientID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error"})
+		return
+	}
+
+	metrics.APIComplaints.Inc()
+	logx.L().Infow("complaint_recorded", "campaign_id", campaignID, "recipient_id", recipientID,
+		"feedback_type", arf.FeedbackType, "user_agent", arf.UserAgent)
+	c.JSON(http.StatusOK, campaign.ComplaintResp{
+		CampaignID:   campaignID,
+		RecipientID:  recipientID,
+		FeedbackType: arf.FeedbackType,
+		Address:      addr,
+	})
+}
@@ -0,0 +1,164 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Mutter0815/MassMailer/internal/campaign"
+	"github.com/Mutter0815/MassMailer/internal/store"
+	"github.com/Mutter0815/MassMailer/internal/token"
+)
+
+func (f *fakeStore) RecordComplaint(ctx context.Context, campaignID, recipientID int64, feedbackType, detail string) (string, error) {
+	if campaignID != 42 || recipientID < 1 || int(recipientID) > len(f.recipients) {
+		return "", sql.ErrNoRows
+	}
+	f.events = append(f.events, store.MessageEvent{CampaignID: campaignID, RecipientID: recipientID,
+		Type: campaign.EventComplained, Status: feedbackType, Detail: detail})
+	addr := strings.ToLower(f.recipients[recipientID-1].Address)
+	if f.suppressions == nil {
+		f.suppressions = map[string]store.Suppression{}
+	}
+	if _, ok := f.suppressions[addr]; !ok {
+		f.suppressions[addr] = store.Suppression{Address: addr, Reason: campaign.SuppressionComplaint, Source: "fbl"}
+	}
+	return addr, nil
+}
+
+// arfReport собирает ARF-отчёт; mailFrom и messageID можно оставить пустыми,
+// как делают провайдеры, вырезающие часть данных.
+func arfReport(feedbackType, mailFrom, messageID string) string {
+	fields := "Feedback-Type: " + feedbackType + "\nUser-Agent: ExampleFBL/1.0\nVersion: 1\n"
+	if mailFrom != "" {
+		fields += "Original-Mail-From: <" + mailFrom + ">\n"
+	}
+	orig := "From: <team@example.com>\nTo: redacted@example.net\nSubject: Sale\n"
+	if messageID != "" {
+		orig += "Message-ID: <" + messageID + ">\n"
+	}
+	return strings.ReplaceAll(`From: fbl@example.net
+To: complaints@mail.example.com
+Subject: Abuse report
+MIME-Version: 1.0
+Content-Type: multipart/report; report-type=feedback-report; boundary=B
+
+--B
+Content-Type: text/plain
+
+This is an abuse report.
+--B
+Content-Type: message/feedback-report
+
+`+fields+`
+--B
+Content-Type: text/rfc822-headers
+
+`+orig+`
+--B--
+`, "\n", "\r\n")
+}
+
+func postComplaint(h *Handlers, body string) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/complaints", strings.NewReader(body))
+	req.Header.Set("Content-Type", "message/rfc822")
+	NewHTTPServer(":0", h).Handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestIngestComplaint_SuppressesAddress(t *testing.T) {
+	links := token.NewSigner("secret")
+	fs := &fakeStore{recipients: []store.NewRecipient{{Address: "ann@example.org"}, {Address: "Bob@example.org"}}}
+	h := &Handlers{Store: fs, Links: links}
+
+	// по VERP-адресу из Original-Mail-From и по Message-ID, когда адрес вырезан
+	for i, body := range []string{
+		arfReport("abuse", links.VERP("bounces@mail.example.com", 42, 2), ""),
+		arfReport("fraud", "", links.MessageID("example.com", 42, 2)),
+	} {
+		rr := postComplaint(h, body)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("#%d: status=%d, body=%s", i+1, rr.Code, rr.Body.String())
+		}
+		var resp campaign.ComplaintResp
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		want := campaign.ComplaintResp{CampaignID: 42, RecipientID: 2, FeedbackType: []string{"abuse", "fraud"}[i], Address: "bob@example.org"}
+		if resp != want {
+			t.Fatalf("#%d: got %+v, want %+v", i+1, resp, want)
+		}
+	}
+	if len(fs.events) != 2 || fs.events[0].Detail != "ExampleFBL/1.0" {
+		t.Fatalf("unexpected events: %+v", fs.events)
+	}
+	if sp := fs.suppressions["bob@example.org"]; sp.Reason != campaign.SuppressionComplaint {
+		t.Fatalf("unexpected suppression: %+v", sp)
+	}
+}
+
+func TestIngestComplaint_Rejects(t *testing.T) {
+	links := token.NewSigner("secret")
+	fs := &fakeStore{recipients: []store.NewRecipient{{Address: "ann@example.org"}}}
+	h := &Handlers{Store: fs, Links: links}
+	verp := links.VERP("b@example.com", 42, 1)
+
+	cases := map[string]struct {
+		h    *Handlers
+		body string
+		want int
+	}{
+		"not spam":        {h, arfReport("not-spam", verp, ""), http.StatusNoContent},
+		"bounce report":   {h, dsnReport(verp, "failed", "5.1.1"), http.StatusUnprocessableEntity},
+		"foreign message": {h, arfReport("abuse", "news@example.com", "123@example.com"), http.StatusUnprocessableEntity},
+		"forged id":       {h, arfReport("abuse", "", token.NewSigner("x").MessageID("example.com", 42, 1)), http.StatusUnprocessableEntity},
+		"unknown message": {h, arfReport("abuse", links.VERP("b@example.com", 42, 9), ""), http.StatusNotFound},
+		"disabled":        {&Handlers{Store: fs}, arfReport("abuse", verp, ""), http.StatusNotFound},
+	}
+	for name, tc := range cases {
+		if rr := postComplaint(tc.h, tc.body); rr.Code != tc.want {
+			t.Errorf("%s: want %d, got %d (%s)", name, tc.want, rr.Code, rr.Body.String())
+		}
+	}
+	if len(fs.events) != 0 || len(fs.suppressions) != 0 {
+		t.Fatalf("nothing must be recorded: events=%+v suppressions=%+v", fs.events, fs.suppressions)
+	}
+}
+
+func TestGetCampaign_ComplaintRate(t *testing.T) {
+	h := &Handlers{Store: &fakeStore{}}
+	rr := httptest.NewRecorder()
+	NewHTTPServer(":0", h).Handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/campaigns/1", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status=%d, body=%s", rr.Code, rr.Body.String())
+	}
+	var resp campaign.CampaignDetails
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Stats.Complaints != 1 || resp.Stats.ComplaintRate != 0.5 {
+		t.Fatalf("unexpected stats: %+v", resp.Stats)
+	}
+}
+
+func TestGetCampaign_ComplaintRateCountsBouncedAsDelivered(t *testing.T) {
+	// на одно письмо пожаловались, а потом пришёл DSN: оно уже bounced
+	h := &Handlers{Store: &fakeStore{stats: &store.CampaignStats{Total: 2, Sent: 1, Bounced: 1, Complaints: 2}}}
+	rr := httptest.NewRecorder()
+	NewHTTPServer(":0", h).Handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/campaigns/1", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status=%d, body=%s", rr.Code, rr.Body.String())
+	}
+	var resp campaign.CampaignDetails
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Stats.ComplaintRate != 1 {
+		t.Fatalf("complaint rate must not exceed 1, got %v", resp.Stats.ComplaintRate)
+	}
+}
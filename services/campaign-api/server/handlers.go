@@ -3,116 +3,180 @@ package server
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/Mutter0815/MassMailer/internal/campaign"
+	"github.com/Mutter0815/MassMailer/internal/render"
 	"github.com/Mutter0815/MassMailer/internal/store"
+	"github.com/Mutter0815/MassMailer/internal/token"
 	"github.com/Mutter0815/MassMailer/pkg/logx"
-	"github.com/Mutter0815/MassMailer/pkg/metrics"
 	"github.com/Mutter0815/MassMailer/pkg/rmq"
+	"github.com/Mutter0815/MassMailer/services/campaign-api/imports"
 	"github.com/gin-gonic/gin"
 )
 
 type storeAPI interface {
 	WithTx(ctx context.Context, fn func(tx *sql.Tx) error) error
-	InsertCampaign(ctx context.Context, tx *sql.Tx, name, body string, scheduledAt any) (int64, error)
-	InsertRecipient(ctx context.Context, tx *sql.Tx, campaignID int64, address string) (int64, error)
-	InsertMessagePending(ctx context.Context, tx *sql.Tx, campaignID, recipientID int64) error
+	InsertCampaign(ctx context.Context, tx *sql.Tx, c store.NewCampaign) (int64, error)
+	InsertRecipients(ctx context.Context, tx *sql.Tx, campaignID int64, rs []store.NewRecipient) (int, int, error)
 	GetCampaign(ctx context.Context, id int64) (store.CampaignRow, error)
 	GetCampaignStats(ctx context.Context, id int64) (store.CampaignStats, error)
 	ListCampaigns(ctx context.Context, limit, offset int) ([]store.CampaignRow, []store.CampaignStats, error)
-}
-
-type publisherAPI interface {
-	PublishJSON(ctx context.Context, body []byte) error
+	SetCampaignStatus(ctx context.Context, q store.Querier, id int64, to string) error
+	CancelMessages(ctx context.Context, q store.Querier, campaignID int64) (int, error)
+	EnqueuePendingJobs(ctx context.Context, tx *sql.Tx, campaignID int64) (int, error)
+	ListAttempts(ctx context.Context, campaignID, messageID int64) ([]store.Attempt, error)
+	CreateImport(ctx context.Context, campaignID int64, format string) (int64, error)
+	GetImport(ctx context.Context, id int64) (store.ImportRow, error)
+	UpsertSuppressions(ctx context.Context, q store.Querier, ss []store.Suppression) (int, error)
+	GetSuppression(ctx context.Context, address string) (store.Suppression, error)
+	DeleteSuppression(ctx context.Context, address string) (bool, error)
+	ListSuppressions(ctx context.Context, q store.SuppressionQuery) ([]store.Suppression, error)
+	SuppressRecipient(ctx context.Context, campaignID, recipientID int64, reason, source string) (string, error)
+	RecordBounce(ctx context.Context, e store.MessageEvent, suppressAfter int) (store.BounceResult, error)
+	RecordComplaint(ctx context.Context, campaignID, recipientID int64, feedbackType, detail string) (string, error)
 }
 
 type storeAdapter struct{ *store.Store }
-type publisherAdapter struct{ *rmq.Publisher }
 
 type Handlers struct {
-	Store storeAPI
-	Pub   publisherAPI
+	Store   storeAPI
+	DLQ     dlqAPI
+	Imports importerAPI
+	// Retry — политика повторов для кампаний без retry_policy в запросе.
+	Retry campaign.RetryPolicy
+	// Links проверяет токены ссылок и адресов возврата из писем; nil —
+	// ссылки выключены.
+	Links *token.Signer
+	// BounceSuppressAfter — после скольких постоянных отказов адрес
+	// блокируется.
+	BounceSuppressAfter int
+	// Opens принимает открытия писем по пикселю; nil — открытия не
+	// записываются.
+	Opens openRecorder
 }
 
-func NewHandlers(s *store.Store, pub *rmq.Publisher) *Handlers {
-	return &Handlers{Store: &storeAdapter{s}, Pub: &publisherAdapter{pub}}
+func NewHandlers(s *store.Store, dlq *rmq.DeadLetters, im *imports.Importer, retry campaign.RetryPolicy, links *token.Signer) *Handlers {
+	return &Handlers{Store: &storeAdapter{s}, DLQ: dlq, Imports: im, Retry: retry, Links: links}
 }
 
 func (h *Handlers) Healthz(c *gin.Context) {
 	c.String(http.StatusOK, "ok")
 }
 
+// createTimeout — бюджет на запись кампании со всеми получателями.
+const createTimeout = 10 * time.Second
+
 func (h *Handlers) CreateCampaign(c *gin.Context) {
 	var req campaign.CreateCampaignReq
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	retry := req.RetryPolicy.Apply(h.Retry)
+	if err := retry.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "retry_policy: " + err.Error()})
+		return
+	}
+	// синтаксис шаблонов проверяем сразу, ошибки подстановки всплывут уже в воркере
+	format := req.Format
+	if format == "" {
+		format = render.FormatText
+	}
+	if format != render.FormatText && format != render.FormatHTML {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be text or html"})
+		return
+	}
+	if _, err := render.Parse(req.Body, format); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body template: " + err.Error()})
+		return
+	}
+	if req.TextBody != "" && format != render.FormatHTML {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "text_body is only allowed with format html"})
+		return
+	}
+	if _, err := render.Parse(req.TextBody, render.FormatText); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid text_body template: " + err.Error()})
+		return
+	}
+	if _, err := render.Parse(req.Subject, render.FormatText); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid subject template: " + err.Error()})
+		return
+	}
 
-	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	var recipients []campaign.Recipient
+	var report *campaign.RecipientsReport
+	if !req.Import {
+		var rep campaign.RecipientsReport
+		recipients, rep = campaign.PrepareRecipients(req.Recipients)
+		if len(recipients) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "no valid recipients", "recipients": rep})
+			return
+		}
+		report = &rep
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), createTimeout)
 	defer cancel()
 
-	var campaignID int64
-	recs := make([]struct {
-		id   int64
-		addr string
-	}, 0, len(req.Recipients))
+	status := campaign.StatusQueued
+	if req.Import {
+		status = campaign.StatusImporting
+	}
 
+	var campaignID int64
 	err := h.Store.WithTx(ctx, func(tx *sql.Tx) error {
-		id, err := h.Store.InsertCampaign(ctx, tx, req.Name, req.Body, req.ScheduledAt)
+		id, err := h.Store.InsertCampaign(ctx, tx, store.NewCampaign{
+			Name:        req.Name,
+			Body:        req.Body,
+			Format:      format,
+			TextBody:    req.TextBody,
+			ScheduledAt: req.ScheduledAt,
+			Status:      status,
+			Retry:       retry,
+			TrackOpens:  req.TrackOpens,
+			Envelope: store.Envelope{
+				Subject:     req.Subject,
+				FromName:    req.FromName,
+				FromAddress: req.FromAddress,
+				ReplyTo:     req.ReplyTo,
+				Headers:     req.Headers,
+			},
+		})
 		if err != nil {
 			return err
 		}
 		campaignID = id
+		if req.Import {
+			return nil
+		}
 
-		for _, addr := range req.Recipients {
-			rid, err := h.Store.InsertRecipient(ctx, tx, campaignID, addr)
-			if err != nil {
-				return err
-			}
-			if err := h.Store.InsertMessagePending(ctx, tx, campaignID, rid); err != nil {
-				return err
-			}
-			recs = append(recs, struct {
-				id   int64
-				addr string
-			}{id: rid, addr: addr})
+		rs := make([]store.NewRecipient, len(recipients))
+		for i, r := range recipients {
+			rs[i] = store.NewRecipient{Address: r.Address, Name: r.Name, Vars: r.Vars}
 		}
-		return nil
+		_, report.Suppressed, err = h.Store.InsertRecipients(ctx, tx, campaignID, rs)
+		return err
 	})
 	if err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
 	}
 
-	ctxPub, cancelPub := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancelPub()
-
-	for _, r := range recs {
-		job := campaign.JobMessage{
-			CampaignID:  campaignID,
-			RecipientID: r.id,
-			Address:     r.addr,
-		}
-		payload, err := json.Marshal(job)
-		if err != nil {
-			logx.L().Errorw("job_marshal_error", "campaign_id", campaignID, "recipient_id", r.id, "error", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "publish error"})
-			return
-		}
-		if err := h.Pub.PublishJSON(ctxPub, payload); err != nil {
-			logx.L().Errorw("publish_job_error", "campaign_id", campaignID, "recipient_id", r.id, "error", err)
-			c.JSON(http.StatusBadGateway, gin.H{"error": "queue unavailable"})
-			return
-		}
-		metrics.PublishedJobsTotal.Inc()
+	// задания публикует планировщик, когда наступит scheduled_at; кампанию
+	// с import он возьмёт только после загрузки получателей
+	if report != nil && (report.Rejected > 0 || report.Duplicates > 0) {
+		logx.L().Infow("campaign_recipients_filtered", "campaign_id", campaignID,
+			"accepted", report.Accepted, "rejected", report.Rejected, "duplicates", report.Duplicates)
 	}
-
-	c.JSON(http.StatusOK, campaign.CreateCampaignResp{ID: campaignID})
+	c.JSON(http.StatusOK, campaign.CreateCampaignResp{ID: campaignID, Status: status, Recipients: report})
 }
 
 func (h *Handlers) ListCampaigns(c *gin.Context) {
@@ -137,11 +201,22 @@ func (h *Handlers) ListCampaigns(c *gin.Context) {
 			ScheduledAt: r.ScheduledAt,
 			Status:      r.Status,
 			CreatedAt:   r.CreatedAt,
+			FinishedAt:  r.FinishedAt,
 		}
 		item.Stats.Total = stats[i].Total
 		item.Stats.Pending = stats[i].Pending
 		item.Stats.Sent = stats[i].Sent
 		item.Stats.Failed = stats[i].Failed
+		item.Stats.Suppressed = stats[i].Suppressed
+		item.Stats.Bounced = stats[i].Bounced
+		item.Stats.Canceled = stats[i].Canceled
+		item.Stats.SoftBounces = stats[i].SoftBounces
+		item.Stats.Complaints = stats[i].Complaints
+		item.Stats.ComplaintRate = campaign.Rate(stats[i].Complaints, stats[i].Delivered())
+		item.Stats.Opens = stats[i].Opens
+		item.Stats.UniqueOpens = stats[i].Opened
+		item.Stats.UniqueOpenRate = campaign.Rate(stats[i].Opened, stats[i].Delivered())
+		item.Stats.Failures = stats[i].Failures
 		out = append(out, item)
 	}
 
@@ -149,10 +224,8 @@ func (h *Handlers) ListCampaigns(c *gin.Context) {
 }
 
 func (h *Handlers) GetCampaign(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.ParseInt(idStr, 10, 64)
-	if err != nil || id <= 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+	id, ok := campaignID(c)
+	if !ok {
 		return
 	}
 
@@ -176,14 +249,179 @@ func (h *Handlers) GetCampaign(c *gin.Context) {
 		ID:          camp.ID,
 		Name:        camp.Name,
 		Body:        camp.Body,
+		Format:      camp.Format,
+		TextBody:    camp.TextBody,
+		Subject:     camp.Subject,
+		FromName:    camp.FromName,
+		FromAddress: camp.FromAddress,
+		ReplyTo:     camp.ReplyTo,
+		Headers:     camp.Headers,
+		RetryPolicy: camp.Retry,
+		TrackOpens:  camp.TrackOpens,
 		ScheduledAt: camp.ScheduledAt,
 		Status:      camp.Status,
 		CreatedAt:   camp.CreatedAt,
+		FinishedAt:  camp.FinishedAt,
 	}
 	resp.Stats.Total = stats.Total
 	resp.Stats.Pending = stats.Pending
 	resp.Stats.Sent = stats.Sent
 	resp.Stats.Failed = stats.Failed
+	resp.Stats.Suppressed = stats.Suppressed
+	resp.Stats.Bounced = stats.Bounced
+	resp.Stats.Canceled = stats.Canceled
+	resp.Stats.SoftBounces = stats.SoftBounces
+	resp.Stats.Complaints = stats.Complaints
+	resp.Stats.ComplaintRate = campaign.Rate(stats.Complaints, stats.Delivered())
+	resp.Stats.Opens = stats.Opens
+	resp.Stats.UniqueOpens = stats.Opened
+	resp.Stats.UniqueOpenRate = campaign.Rate(stats.Opened, stats.Delivered())
+	resp.Stats.Failures = stats.Failures
 
 	c.JSON(http.StatusOK, resp)
 }
+
+// ListMessageAttempts отдаёт историю попыток отправки одного сообщения.
+func (h *Handlers) ListMessageAttempts(c *gin.Context) {
+	id, ok := campaignID(c)
+	if !ok {
+		return
+	}
+	messageID, err := strconv.ParseInt(c.Param("message_id"), 10, 64)
+	if err != nil || messageID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid message_id"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	attempts, err := h.Store.ListAttempts(ctx, id, messageID)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "message not found"})
+		return
+	}
+	if err != nil {
+		logx.L().Errorw("list_attempts_error", "id", id, "message_id", messageID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error"})
+		return
+	}
+
+	out := make([]campaign.MessageAttempt, 0, len(attempts))
+	for _, a := range attempts {
+		out = append(out, campaign.MessageAttempt{
+			Attempt:      a.Attempt,
+			StartedAt:    a.StartedAt,
+			DurationMS:   a.Duration.Milliseconds(),
+			Transport:    a.Transport,
+			Provider:     a.Provider,
+			ResponseCode: a.ResponseCode,
+			ResponseText: a.ResponseText,
+			ErrorClass:   a.ErrorClass,
+			Error:        a.Error,
+		})
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+func (h *Handlers) CancelCampaign(c *gin.Context) {
+	h.changeStatus(c, campaign.StatusCanceled)
+}
+
+func (h *Handlers) PauseCampaign(c *gin.Context) {
+	h.changeStatus(c, campaign.StatusPaused)
+}
+
+// ResumeCampaign возвращает кампанию в processing и заново ставит её
+// pending-сообщения в outbox: пока кампания стояла на паузе, воркер
+// подтверждал задания без отправки.
+func (h *Handlers) ResumeCampaign(c *gin.Context) {
+	id, ok := campaignID(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	camp, err := h.Store.GetCampaign(ctx, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "campaign not found"})
+		return
+	}
+
+	// queued → processing тоже допустимый переход, но его делает только планировщик
+	if camp.Status != campaign.StatusPaused {
+		c.JSON(http.StatusConflict, gin.H{"error": "cannot resume campaign in status " + camp.Status})
+		return
+	}
+
+	err = h.Store.WithTx(ctx, func(tx *sql.Tx) error {
+		if err := h.Store.SetCampaignStatus(ctx, tx, id, campaign.StatusProcessing); err != nil {
+			return err
+		}
+		_, err := h.Store.EnqueuePendingJobs(ctx, tx, id)
+		return err
+	})
+	if errors.Is(err, campaign.ErrInvalidTransition) {
+		c.JSON(http.StatusConflict, gin.H{"error": "cannot resume campaign in status " + camp.Status})
+		return
+	}
+	if err != nil {
+		logx.L().Errorw("resume_campaign_error", "id", id, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "status update error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, campaign.CampaignStatusResp{ID: id, Status: campaign.StatusProcessing})
+}
+
+func (h *Handlers) changeStatus(c *gin.Context, to string) {
+	id, ok := campaignID(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	camp, err := h.Store.GetCampaign(ctx, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "campaign not found"})
+		return
+	}
+
+	canceled := 0
+	err = h.Store.WithTx(ctx, func(tx *sql.Tx) error {
+		if err := h.Store.SetCampaignStatus(ctx, tx, id, to); err != nil || to != campaign.StatusCanceled {
+			return err
+		}
+		// иначе pending-сообщения отменённой кампании висели бы вечно
+		var err error
+		canceled, err = h.Store.CancelMessages(ctx, tx, id)
+		return err
+	})
+	if errors.Is(err, campaign.ErrInvalidTransition) {
+		c.JSON(http.StatusConflict, gin.H{"error": "cannot move campaign from " + camp.Status + " to " + to})
+		return
+	}
+	if err != nil {
+		logx.L().Errorw("set_campaign_status_error", "id", id, "status", to, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "status update error"})
+		return
+	}
+	if to == campaign.StatusCanceled {
+		logx.L().Infow("campaign_canceled", "id", id, "messages_canceled", canceled)
+	}
+
+	c.JSON(http.StatusOK, campaign.CampaignStatusResp{ID: id, Status: to})
+}
+
+func campaignID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return 0, false
+	}
+	return id, true
+}
@@ -18,8 +18,20 @@ import (
 type fakeStore struct {
 	failTx            bool
 	insertCampaignHit bool
+	inserted          store.NewCampaign
 	recipientsN       int
-	msgsN             int
+	recipientVars     []map[string]any
+	recipients        []store.NewRecipient
+	suppressions      map[string]store.Suppression
+	events            []store.MessageEvent
+	recipientCalls    int
+	status            string
+	pendingJobs       int
+	enqueued          int
+	importExists      bool
+	messagesCanceled  int
+	// stats — что отдаёт GetCampaignStats вместо значений по умолчанию
+	stats *store.CampaignStats
 }
 
 func (f *fakeStore) WithTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
@@ -29,19 +41,26 @@ func (f *fakeStore) WithTx(ctx context.Context, fn func(tx *sql.Tx) error) error
 	return fn(&sql.Tx{})
 }
 
-func (f *fakeStore) InsertCampaign(ctx context.Context, tx *sql.Tx, name, body string, scheduledAt any) (int64, error) {
+func (f *fakeStore) InsertCampaign(ctx context.Context, tx *sql.Tx, c store.NewCampaign) (int64, error) {
 	f.insertCampaignHit = true
+	f.inserted = c
 	return int64(42), nil
 }
 
-func (f *fakeStore) InsertRecipient(ctx context.Context, tx *sql.Tx, campaignID int64, address string) (int64, error) {
-	f.recipientsN++
-	return int64(100 + f.recipientsN), nil
-}
-
-func (f *fakeStore) InsertMessagePending(ctx context.Context, tx *sql.Tx, campaignID, recipientID int64) error {
-	f.msgsN++
-	return nil
+func (f *fakeStore) InsertRecipients(ctx context.Context, tx *sql.Tx, campaignID int64, rs []store.NewRecipient) (int, int, error) {
+	f.recipientCalls++
+	f.recipientsN += len(rs)
+	for _, r := range rs {
+		f.recipientVars = append(f.recipientVars, r.Vars)
+	}
+	f.recipients = append(f.recipients, rs...)
+	suppressed := 0
+	for _, r := range rs {
+		if _, ok := f.suppressions[strings.ToLower(r.Address)]; ok {
+			suppressed++
+		}
+	}
+	return len(rs), suppressed, nil
 }
 
 func (f *fakeStore) GetCampaign(ctx context.Context, id int64) (store.CampaignRow, error) {
@@ -50,17 +69,50 @@ func (f *fakeStore) GetCampaign(ctx context.Context, id int64) (store.CampaignRo
 		Name:        "stub",
 		Body:        "body",
 		ScheduledAt: time.Unix(0, 0).UTC(),
-		Status:      "queued",
+		Status:      f.campaignStatus(),
 		CreatedAt:   time.Unix(0, 0).UTC(),
 	}, nil
 }
 
+func (f *fakeStore) campaignStatus() string {
+	if f.status == "" {
+		return campaign.StatusQueued
+	}
+	return f.status
+}
+
+func (f *fakeStore) SetCampaignStatus(ctx context.Context, q store.Querier, id int64, to string) error {
+	if !campaign.CanTransition(f.campaignStatus(), to) {
+		return campaign.ErrInvalidTransition
+	}
+	f.status = to
+	return nil
+}
+
+func (f *fakeStore) CancelMessages(ctx context.Context, q store.Querier, campaignID int64) (int, error) {
+	n := f.pendingJobs
+	f.messagesCanceled += n
+	f.pendingJobs = 0
+	return n, nil
+}
+
+func (f *fakeStore) EnqueuePendingJobs(ctx context.Context, tx *sql.Tx, campaignID int64) (int, error) {
+	f.enqueued += f.pendingJobs
+	return f.pendingJobs, nil
+}
+
 func (f *fakeStore) GetCampaignStats(ctx context.Context, id int64) (store.CampaignStats, error) {
+	if f.stats != nil {
+		return *f.stats, nil
+	}
 	return store.CampaignStats{
-		Total:   3,
-		Pending: 0,
-		Sent:    2,
-		Failed:  1,
+		Total:      3,
+		Pending:    0,
+		Sent:       2,
+		Failed:     1,
+		Complaints: 1,
+		Opens:      5,
+		Opened:     1,
 	}, nil
 }
 
@@ -76,21 +128,53 @@ func (f *fakeStore) ListCampaigns(ctx context.Context, limit, offset int) ([]sto
 	return rows, stats, nil
 }
 
-type fakePublisher struct{ n int }
+func (f *fakeStore) ListAttempts(ctx context.Context, campaignID, messageID int64) ([]store.Attempt, error) {
+	if messageID != 900 {
+		return nil, sql.ErrNoRows
+	}
+	started := time.Date(2025, 10, 2, 12, 0, 0, 0, time.UTC)
+	return []store.Attempt{
+		{Attempt: 1, StartedAt: started, Duration: 1200 * time.Millisecond, Transport: "smtp",
+			Provider: "mx.example.com:25", ResponseCode: 451, ResponseText: "4.3.0 try later",
+			ErrorClass: "temporary", Error: "smtp rcpt to: 451 4.3.0 try later"},
+		{Attempt: 2, StartedAt: started.Add(time.Second), Duration: 300 * time.Millisecond, Transport: "smtp",
+			Provider: "mx.example.com:25", ResponseCode: 250, ResponseText: "2.0.0 queued as ABC"},
+	}, nil
+}
+
+func (f *fakeStore) CreateImport(ctx context.Context, campaignID int64, format string) (int64, error) {
+	if f.importExists {
+		return 0, sql.ErrNoRows
+	}
+	f.importExists = true
+	return 55, nil
+}
 
-func (p *fakePublisher) PublishJSON(ctx context.Context, body []byte) error {
-	p.n++
-	return nil
+func (f *fakeStore) GetImport(ctx context.Context, id int64) (store.ImportRow, error) {
+	if id != 55 {
+		return store.ImportRow{}, sql.ErrNoRows
+	}
+	return store.ImportRow{
+		ID: 55, CampaignID: 42, Format: "ndjson", Status: "running",
+		Processed: 3, Accepted: 2, Rejected: 1,
+		Errors: []campaign.ImportRowError{{Row: 2, Error: "address is required"}},
+	}, nil
 }
 
 type errTest string
 
 func (e errTest) Error() string { return string(e) }
 
+var testRetry = campaign.RetryPolicy{
+	MaxAttempts: 4,
+	BaseDelay:   time.Second,
+	MaxDelay:    5 * time.Minute,
+	Deadline:    24 * time.Hour,
+}
+
 func TestCreateCampaign_OK(t *testing.T) {
 	fs := &fakeStore{}
-	fp := &fakePublisher{}
-	h := &Handlers{Store: fs, Pub: fp}
+	h := &Handlers{Store: fs, Retry: testRetry}
 
 	srv := NewHTTPServer(":0", h)
 	rr := httptest.NewRecorder()
@@ -98,8 +182,14 @@ func TestCreateCampaign_OK(t *testing.T) {
 	body := bytes.NewBufferString(`{
 		"name":"Smoke",
 		"body":"Hello",
+		"subject":"Hi {{.first_name}}",
+		"from_name":"Team",
+		"from_address":"team@example.com",
+		"headers":{"X-Campaign":"smoke"},
+		"retry_policy":{"max_attempts":6,"base_delay":"10s"},
+		"track_opens":true,
 		"scheduled_at":"2025-10-02T12:00:00Z",
-		"recipients":["u1@example.com","u2@example.com"]
+		"recipients":["u1@example.com",{"address":"u2@example.com","vars":{"first_name":"Ann"}}]
 	}`)
 	req := httptest.NewRequest(http.MethodPost, "/campaigns", body)
 	req.Header.Set("Content-Type", "application/json")
@@ -119,16 +209,177 @@ func TestCreateCampaign_OK(t *testing.T) {
 	if !fs.insertCampaignHit {
 		t.Fatal("insertCampaign not called")
 	}
-	if fs.recipientsN != 2 || fs.msgsN != 2 {
-		t.Fatalf("want 2 recipients & 2 messages, got %d/%d", fs.recipientsN, fs.msgsN)
+	if fs.recipientsN != 2 || fs.recipientCalls != 1 {
+		t.Fatalf("want 2 recipients in one batch, got %d in %d", fs.recipientsN, fs.recipientCalls)
+	}
+	if fs.enqueued != 0 {
+		t.Fatalf("jobs must be enqueued by the scheduler, got %d", fs.enqueued)
+	}
+	if fs.inserted.Subject != "Hi {{.first_name}}" || fs.inserted.FromAddress != "team@example.com" ||
+		fs.inserted.Headers["X-Campaign"] != "smoke" {
+		t.Fatalf("envelope not persisted: %+v", fs.inserted.Envelope)
+	}
+	wantRetry := testRetry
+	wantRetry.MaxAttempts, wantRetry.BaseDelay = 6, 10*time.Second
+	if fs.inserted.Retry != wantRetry {
+		t.Fatalf("retry policy: got %+v, want %+v", fs.inserted.Retry, wantRetry)
+	}
+	if !fs.inserted.TrackOpens {
+		t.Fatal("track_opens not persisted")
+	}
+	if fs.recipientVars[0] != nil || fs.recipientVars[1]["first_name"] != "Ann" {
+		t.Fatalf("unexpected recipient vars: %v", fs.recipientVars)
+	}
+}
+
+func TestCreateCampaign_InvalidEnvelope(t *testing.T) {
+	cases := map[string]string{
+		"bad from":        `"from_address":"not an address"`,
+		"named reply_to":  `"reply_to":"Bob <bob@example.com>"`,
+		"subject CRLF":    `"subject":"Hi\r\nBcc: x@example.com"`,
+		"reserved header": `"headers":{"bcc":"x@example.com"}`,
+		"header newline":  `"headers":{"X-Tag":"a\nb"}`,
+		"header name":     `"headers":{"X Tag":"a"}`,
+		"retry attempts":  `"retry_policy":{"max_attempts":0}`,
+		"retry delay":     `"retry_policy":{"base_delay":"soon"}`,
+		"retry max delay": `"retry_policy":{"base_delay":"10m"}`,
+		"retry jitter":    `"retry_policy":{"jitter":2}`,
+		"unknown format":  `"format":"markdown"`,
+		"long header":     `"headers":{"X-Tag":"` + strings.Repeat("a", 995) + `"}`,
+		"long subject":    `"subject":"Hi ` + strings.Repeat("a", 1000) + `"`,
+		"long reply_to":   `"reply_to":"` + strings.Repeat("a", 64) + "@" + strings.Repeat("b.", 100) + `com"`,
+		"text_body text":  `"text_body":"Hi"`,
+	}
+	for name, field := range cases {
+		fs := &fakeStore{}
+		srv := NewHTTPServer(":0", &Handlers{Store: fs, Retry: testRetry})
+
+		rr := httptest.NewRecorder()
+		body := bytes.NewBufferString(`{
+			"name":"X","body":"Y",` + field + `,
+			"scheduled_at":"2025-10-02T12:00:00Z",
+			"recipients":["u@example.com"]
+		}`)
+		req := httptest.NewRequest(http.MethodPost, "/campaigns", body)
+		req.Header.Set("Content-Type", "application/json")
+
+		srv.Handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d: %s", name, rr.Code, rr.Body.String())
+		}
+		if fs.insertCampaignHit {
+			t.Fatalf("%s: campaign must not be stored", name)
+		}
+	}
+}
+
+func TestCreateCampaign_ExplicitFormat(t *testing.T) {
+	cases := []struct{ field, want string }{
+		{``, "text"},
+		// HTML без ведущего тега: раньше угадывался как текст
+		{`"format":"html",`, "html"},
+	}
+	for _, c := range cases {
+		fs := &fakeStore{}
+		srv := NewHTTPServer(":0", &Handlers{Store: fs, Retry: testRetry})
+
+		rr := httptest.NewRecorder()
+		body := bytes.NewBufferString(`{
+			"name":"X","body":"Hi <b>{{.first_name}}</b>",` + c.field + `
+			"scheduled_at":"2025-10-02T12:00:00Z",
+			"recipients":["u@example.com"]
+		}`)
+		req := httptest.NewRequest(http.MethodPost, "/campaigns", body)
+		req.Header.Set("Content-Type", "application/json")
+
+		srv.Handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%q: status=%d, body=%s", c.field, rr.Code, rr.Body.String())
+		}
+		if fs.inserted.Format != c.want {
+			t.Fatalf("%q: format %q, want %q", c.field, fs.inserted.Format, c.want)
+		}
+	}
+}
+
+func TestCreateCampaign_InvalidTemplate(t *testing.T) {
+	fs := &fakeStore{}
+	srv := NewHTTPServer(":0", &Handlers{Store: fs, Retry: testRetry})
+
+	rr := httptest.NewRecorder()
+	body := bytes.NewBufferString(`{
+		"name":"X","body":"Hi {{if .plan}}",
+		"scheduled_at":"2025-10-02T12:00:00Z",
+		"recipients":["u@example.com"]
+	}`)
+	req := httptest.NewRequest(http.MethodPost, "/campaigns", body)
+	req.Header.Set("Content-Type", "application/json")
+
+	srv.Handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if fs.insertCampaignHit {
+		t.Fatal("campaign must not be stored")
+	}
+}
+
+func TestCreateCampaign_FiltersRecipients(t *testing.T) {
+	fs := &fakeStore{}
+	srv := NewHTTPServer(":0", &Handlers{Store: fs, Retry: testRetry})
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/campaigns", bytes.NewBufferString(`{
+		"name":"X","body":"Y",
+		"scheduled_at":"2025-10-02T12:00:00Z",
+		"recipients":["  Ann@Example.COM ","foo",{"address":"Ann Lee <Ann@example.com>"},"Bob <bob@пример.рф>",""]
+	}`))
+	req.Header.Set("Content-Type", "application/json")
+	srv.Handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status=%d, body=%s", rr.Code, rr.Body.String())
+	}
+	var resp campaign.CreateCampaignResp
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	rep := resp.Recipients
+	if rep == nil || rep.Accepted != 2 || rep.Rejected != 2 || rep.Duplicates != 1 || len(rep.Issues) != 3 {
+		t.Fatalf("unexpected report: %+v", rep)
+	}
+	if rep.Issues[1].Index != 2 || rep.Issues[1].Reason != "duplicate of recipients[0]" {
+		t.Fatalf("unexpected duplicate issue: %+v", rep.Issues[1])
+	}
+	if len(fs.recipients) != 2 || fs.recipients[0].Address != "Ann@example.com" ||
+		fs.recipients[1].Address != "bob@xn--e1afmkfd.xn--p1ai" || fs.recipients[1].Name != "Bob" {
+		t.Fatalf("unexpected recipients: %+v", fs.recipients)
+	}
+}
+
+func TestCreateCampaign_NoValidRecipients(t *testing.T) {
+	fs := &fakeStore{}
+	srv := NewHTTPServer(":0", &Handlers{Store: fs, Retry: testRetry})
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/campaigns", bytes.NewBufferString(`{
+		"name":"X","body":"Y",
+		"scheduled_at":"2025-10-02T12:00:00Z",
+		"recipients":["foo",{"address":""}]
+	}`))
+	req.Header.Set("Content-Type", "application/json")
+	srv.Handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("want 400, got %d", rr.Code)
 	}
-	if fp.n != 2 {
-		t.Fatalf("want 2 published messages, got %d", fp.n)
+	if fs.insertCampaignHit {
+		t.Fatal("campaign must not be created")
 	}
 }
 
 func TestCreateCampaign_ValidationError(t *testing.T) {
-	h := &Handlers{Store: &fakeStore{}, Pub: &fakePublisher{}}
+	h := &Handlers{Store: &fakeStore{}}
 	srv := NewHTTPServer(":0", h)
 
 	rr := httptest.NewRecorder()
@@ -143,7 +394,7 @@ func TestCreateCampaign_ValidationError(t *testing.T) {
 
 func TestCreateCampaign_TxError(t *testing.T) {
 	fs := &fakeStore{failTx: true}
-	h := &Handlers{Store: fs, Pub: &fakePublisher{}}
+	h := &Handlers{Store: fs, Retry: testRetry}
 	srv := NewHTTPServer(":0", h)
 
 	rr := httptest.NewRecorder()
@@ -162,7 +413,7 @@ func TestCreateCampaign_TxError(t *testing.T) {
 }
 
 func TestDocsEndpoints(t *testing.T) {
-	h := &Handlers{Store: &fakeStore{}, Pub: &fakePublisher{}}
+	h := &Handlers{Store: &fakeStore{}}
 	srv := NewHTTPServer(":0", h)
 
 	t.Run("html", func(t *testing.T) {
@@ -196,3 +447,123 @@ func TestDocsEndpoints(t *testing.T) {
 		}
 	})
 }
+
+func postStatus(t *testing.T, h *Handlers, path string) (*httptest.ResponseRecorder, campaign.CampaignStatusResp) {
+	t.Helper()
+	srv := NewHTTPServer(":0", h)
+	rr := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, path, nil))
+
+	var resp campaign.CampaignStatusResp
+	if rr.Code == http.StatusOK {
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return rr, resp
+}
+
+func TestPauseCampaign_OK(t *testing.T) {
+	fs := &fakeStore{status: campaign.StatusProcessing}
+	rr, resp := postStatus(t, &Handlers{Store: fs}, "/campaigns/5/pause")
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status=%d, body=%s", rr.Code, rr.Body.String())
+	}
+	if resp.ID != 5 || resp.Status != campaign.StatusPaused || fs.status != campaign.StatusPaused {
+		t.Fatalf("unexpected response %+v, store status %s", resp, fs.status)
+	}
+}
+
+func TestCancelCampaign_IllegalTransition(t *testing.T) {
+	fs := &fakeStore{status: campaign.StatusDone, pendingJobs: 2}
+	rr, _ := postStatus(t, &Handlers{Store: fs}, "/campaigns/5/cancel")
+
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d", rr.Code)
+	}
+	if fs.status != campaign.StatusDone || fs.messagesCanceled != 0 {
+		t.Fatalf("nothing must change, got status %s, canceled %d", fs.status, fs.messagesCanceled)
+	}
+}
+
+func TestCancelCampaign_CancelsPendingMessages(t *testing.T) {
+	fs := &fakeStore{status: campaign.StatusPaused, pendingJobs: 3}
+	rr, resp := postStatus(t, &Handlers{Store: fs}, "/campaigns/5/cancel")
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status=%d, body=%s", rr.Code, rr.Body.String())
+	}
+	if resp.Status != campaign.StatusCanceled || fs.status != campaign.StatusCanceled {
+		t.Fatalf("unexpected response %+v, store status %s", resp, fs.status)
+	}
+	if fs.messagesCanceled != 3 || fs.pendingJobs != 0 {
+		t.Fatalf("pending messages must be canceled, got %d (left %d)", fs.messagesCanceled, fs.pendingJobs)
+	}
+}
+
+func TestPauseCampaign_KeepsPendingMessages(t *testing.T) {
+	fs := &fakeStore{status: campaign.StatusProcessing, pendingJobs: 3}
+	if rr, _ := postStatus(t, &Handlers{Store: fs}, "/campaigns/5/pause"); rr.Code != http.StatusOK {
+		t.Fatalf("status=%d, body=%s", rr.Code, rr.Body.String())
+	}
+	if fs.messagesCanceled != 0 {
+		t.Fatalf("pause must not cancel messages, got %d", fs.messagesCanceled)
+	}
+}
+
+func TestResumeCampaign_RepublishesPending(t *testing.T) {
+	fs := &fakeStore{status: campaign.StatusPaused, pendingJobs: 2}
+	rr, resp := postStatus(t, &Handlers{Store: fs}, "/campaigns/5/resume")
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status=%d, body=%s", rr.Code, rr.Body.String())
+	}
+	if resp.Status != campaign.StatusProcessing {
+		t.Fatalf("want processing, got %s", resp.Status)
+	}
+	if fs.enqueued != 2 {
+		t.Fatalf("want 2 jobs re-enqueued, got %d", fs.enqueued)
+	}
+}
+
+func TestResumeCampaign_NotPaused(t *testing.T) {
+	fs := &fakeStore{status: campaign.StatusQueued, pendingJobs: 2}
+	rr, _ := postStatus(t, &Handlers{Store: fs}, "/campaigns/5/resume")
+
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d", rr.Code)
+	}
+	if fs.enqueued != 0 || fs.status != campaign.StatusQueued {
+		t.Fatalf("queued campaign must be left to the scheduler, enqueued=%d status=%s", fs.enqueued, fs.status)
+	}
+}
+
+func TestListMessageAttempts(t *testing.T) {
+	srv := NewHTTPServer(":0", &Handlers{Store: &fakeStore{}})
+
+	rr := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/campaigns/5/messages/900/attempts", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status=%d body=%s", rr.Code, rr.Body.String())
+	}
+	var got []campaign.MessageAttempt
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].ErrorClass != "temporary" || got[0].DurationMS != 1200 ||
+		got[1].ResponseCode != 250 || got[1].Error != "" {
+		t.Fatalf("unexpected attempts: %+v", got)
+	}
+
+	for path, want := range map[string]int{
+		"/campaigns/5/messages/1/attempts":   http.StatusNotFound,
+		"/campaigns/5/messages/abc/attempts": http.StatusBadRequest,
+	} {
+		rr := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		if rr.Code != want {
+			t.Fatalf("%s: status=%d, want %d", path, rr.Code, want)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Mutter0815/MassMailer/internal/campaign"
+	"github.com/Mutter0815/MassMailer/internal/store"
+	"github.com/Mutter0815/MassMailer/pkg/logx"
+	"github.com/Mutter0815/MassMailer/services/campaign-api/imports"
+)
+
+type importerAPI interface {
+	Acquire() bool
+	Release()
+	Spool(r io.Reader) (string, error)
+	Start(j imports.Job)
+}
+
+// importFormats сопоставляет Content-Type загрузки с форматом разбора.
+var importFormats = map[string]string{
+	"application/json":     campaign.ImportFormatJSON,
+	"application/x-ndjson": campaign.ImportFormatNDJSON,
+	"application/jsonl":    campaign.ImportFormatNDJSON,
+	"text/csv":             campaign.ImportFormatCSV,
+}
+
+// importExts — формат файла в multipart, если поле format не передано
+// и Content-Type части ничего не говорит.
+var importExts = map[string]string{
+	".json":   campaign.ImportFormatJSON,
+	".ndjson": campaign.ImportFormatNDJSON,
+	".jsonl":  campaign.ImportFormatNDJSON,
+	".csv":    campaign.ImportFormatCSV,
+}
+
+// maxFormField ограничивает текстовые поля multipart (format, mapping, delimiter).
+const maxFormField = 64 << 10
+
+// errBadUpload — ошибка в самой загрузке, на которую отвечаем 400.
+type errBadUpload struct{ msg string }
+
+func (e errBadUpload) Error() string { return e.msg }
+
+// CreateImport принимает получателей кампании в статусе importing и отвечает
+// 202, не дожидаясь их записи в БД. Тело — либо сам файл (JSON-массив, NDJSON
+// или CSV по Content-Type), либо multipart/form-data с частью file и
+// необязательными полями format, mapping и delimiter.
+func (h *Handlers) CreateImport(c *gin.Context) {
+	id, ok := campaignID(c)
+	if !ok {
+		return
+	}
+	mediaType, _, _ := mime.ParseMediaType(c.ContentType())
+	format, ok := importFormats[mediaType]
+	if !ok && mediaType != "multipart/form-data" {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Content-Type must be application/json, application/x-ndjson, text/csv or multipart/form-data"})
+		return
+	}
+
+	getCtx, cancelGet := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	camp, err := h.Store.GetCampaign(getCtx, id)
+	cancelGet()
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "campaign not found"})
+		return
+	}
+	if camp.Status != campaign.StatusImporting {
+		c.JSON(http.StatusConflict, gin.H{"error": "campaign in status " + camp.Status + " does not accept imports"})
+		return
+	}
+
+	if !h.Imports.Acquire() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "too many imports in progress, retry later"})
+		return
+	}
+	started := false
+	defer func() {
+		if !started {
+			h.Imports.Release()
+		}
+	}()
+
+	spec := imports.Spec{Format: format}
+	var path string
+	if ok {
+		path, err = h.Imports.Spool(c.Request.Body)
+	} else {
+		path, spec, err = h.spoolMultipart(c)
+	}
+	var bad errBadUpload
+	switch {
+	case errors.Is(err, imports.ErrTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		return
+	case errors.As(err, &bad):
+		c.JSON(http.StatusBadRequest, gin.H{"error": bad.msg})
+		return
+	case err != nil:
+		logx.L().Errorw("import_spool_error", "campaign_id", id, "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read request body"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	importID, err := h.Store.CreateImport(ctx, id, spec.Format)
+	if err != nil {
+		_ = os.Remove(path)
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusConflict, gin.H{"error": "campaign already has an import"})
+			return
+		}
+		logx.L().Errorw("create_import_error", "campaign_id", id, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error"})
+		return
+	}
+
+	h.Imports.Start(imports.Job{ID: importID, CampaignID: id, Path: path, Spec: spec})
+	started = true
+
+	c.JSON(http.StatusAccepted, campaign.CreateImportResp{ID: importID, CampaignID: id, Status: campaign.ImportPending})
+}
+
+// spoolMultipart читает части формы по порядку: файл сразу уходит во
+// временный файл, поля можно передавать до или после него. Формат без поля
+// format берётся из Content-Type части или расширения имени файла.
+func (h *Handlers) spoolMultipart(c *gin.Context) (path string, spec imports.Spec, err error) {
+	defer func() {
+		if err != nil && path != "" {
+			_ = os.Remove(path)
+			path = ""
+		}
+	}()
+
+	mr, err := c.Request.MultipartReader()
+	if err != nil {
+		return "", spec, errBadUpload{"invalid multipart body: " + err.Error()}
+	}
+	var fileFormat, mapping, delimiter string
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return path, spec, errBadUpload{"invalid multipart body: " + err.Error()}
+		}
+
+		if part.FormName() == "file" {
+			if path != "" {
+				return path, spec, errBadUpload{"only one file part is allowed"}
+			}
+			mediaType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+			if fileFormat = importFormats[mediaType]; fileFormat == "" {
+				fileFormat = importExts[strings.ToLower(filepath.Ext(part.FileName()))]
+			}
+			if path, err = h.Imports.Spool(part); err != nil {
+				return "", spec, err
+			}
+			continue
+		}
+
+		var dst *string
+		switch part.FormName() {
+		case "format":
+			dst = &spec.Format
+		case "mapping":
+			dst = &mapping
+		case "delimiter":
+			dst = &delimiter
+		default:
+			continue
+		}
+		b, err := io.ReadAll(io.LimitReader(part, maxFormField+1))
+		if err != nil {
+			return path, spec, err
+		}
+		if len(b) > maxFormField {
+			return path, spec, errBadUpload{part.FormName() + " is too large"}
+		}
+		*dst = strings.TrimSpace(string(b))
+	}
+
+	if path == "" {
+		return "", spec, errBadUpload{"file part is required"}
+	}
+	if spec.Format == "" {
+		spec.Format = fileFormat
+	}
+	if spec.Format == "" {
+		return path, spec, errBadUpload{"cannot detect file format; set format to json, ndjson or csv"}
+	}
+	if mapping != "" {
+		spec.Mapping = &campaign.ImportMapping{}
+		dec := json.NewDecoder(strings.NewReader(mapping))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(spec.Mapping); err != nil {
+			return path, spec, errBadUpload{"invalid mapping: " + err.Error()}
+		}
+	}
+	if delimiter != "" {
+		if delimiter == `\t` {
+			delimiter = "\t"
+		}
+		if utf8.RuneCountInString(delimiter) != 1 {
+			return path, spec, errBadUpload{"delimiter must be a single character"}
+		}
+		spec.Delimiter, _ = utf8.DecodeRuneInString(delimiter)
+	}
+	if err := spec.Validate(); err != nil {
+		return path, spec, errBadUpload{err.Error()}
+	}
+	return path, spec, nil
+}
+
+func (h *Handlers) GetImport(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	imp, err := h.Store.GetImport(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "import not found"})
+		return
+	}
+	if err != nil {
+		logx.L().Errorw("get_import_error", "id", id, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error"})
+		return
+	}
+	c.JSON(http.StatusOK, toImportResp(imp))
+}
+
+func toImportResp(r store.ImportRow) campaign.Import {
+	errs := r.Errors
+	if errs == nil {
+		errs = []campaign.ImportRowError{}
+	}
+	return campaign.Import{
+		ID:         r.ID,
+		CampaignID: r.CampaignID,
+		Format:     r.Format,
+		Status:     r.Status,
+		Processed:  r.Processed,
+		Accepted:   r.Accepted,
+		Rejected:   r.Rejected,
+		Duplicates: r.Duplicates,
+		Errors:     errs,
+		Error:      r.Error,
+		CreatedAt:  r.CreatedAt,
+		UpdatedAt:  r.UpdatedAt,
+		FinishedAt: r.FinishedAt,
+	}
+}
@@ -0,0 +1,252 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Mutter0815/MassMailer/internal/campaign"
+	"github.com/Mutter0815/MassMailer/services/campaign-api/imports"
+)
+
+type fakeImporter struct {
+	busy     bool
+	held     int
+	spooled  string
+	started  []imports.Job
+	tooLarge bool
+}
+
+func (f *fakeImporter) Acquire() bool {
+	if f.busy {
+		return false
+	}
+	f.held++
+	return true
+}
+
+func (f *fakeImporter) Release() { f.held-- }
+
+func (f *fakeImporter) Spool(r io.Reader) (string, error) {
+	if f.tooLarge {
+		return "", imports.ErrTooLarge
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	f.spooled = string(b)
+	return "/tmp/import-test", nil
+}
+
+func (f *fakeImporter) Start(j imports.Job) { f.started = append(f.started, j) }
+
+func postImport(h *Handlers, contentType, body string) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/campaigns/42/imports", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", contentType)
+	NewHTTPServer(":0", h).Handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestCreateCampaign_ImportMode(t *testing.T) {
+	fs := &fakeStore{}
+	srv := NewHTTPServer(":0", &Handlers{Store: fs, Retry: testRetry})
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/campaigns", bytes.NewBufferString(
+		`{"name":"bulk","body":"hi","scheduled_at":"2025-10-02T12:00:00Z","import":true}`))
+	req.Header.Set("Content-Type", "application/json")
+	srv.Handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status=%d, body=%s", rr.Code, rr.Body.String())
+	}
+	var resp campaign.CreateCampaignResp
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Status != campaign.StatusImporting || fs.inserted.Status != campaign.StatusImporting {
+		t.Fatalf("campaign must wait for import: resp=%+v inserted=%q", resp, fs.inserted.Status)
+	}
+	if fs.recipientCalls != 0 {
+		t.Fatalf("no recipients must be inserted, got %d calls", fs.recipientCalls)
+	}
+}
+
+func TestCreateCampaign_ImportWithRecipients(t *testing.T) {
+	srv := NewHTTPServer(":0", &Handlers{Store: &fakeStore{}, Retry: testRetry})
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/campaigns", bytes.NewBufferString(
+		`{"name":"bulk","body":"hi","scheduled_at":"2025-10-02T12:00:00Z","import":true,"recipients":["a@x.com"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	srv.Handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("want 400, got %d", rr.Code)
+	}
+}
+
+func TestCreateImport_Accepted(t *testing.T) {
+	fs := &fakeStore{status: campaign.StatusImporting}
+	fi := &fakeImporter{}
+	body := "{\"address\":\"a@x.com\"}\n\"b@x.com\"\n"
+
+	rr := postImport(&Handlers{Store: fs, Imports: fi}, "application/x-ndjson; charset=utf-8", body)
+
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("status=%d, body=%s", rr.Code, rr.Body.String())
+	}
+	var resp campaign.CreateImportResp
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.ID != 55 || resp.CampaignID != 42 || resp.Status != campaign.ImportPending {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+	if fi.spooled != body {
+		t.Fatalf("body not spooled as is: %q", fi.spooled)
+	}
+	want := imports.Job{ID: 55, CampaignID: 42, Path: "/tmp/import-test", Spec: imports.Spec{Format: campaign.ImportFormatNDJSON}}
+	if len(fi.started) != 1 || fi.started[0] != want {
+		t.Fatalf("unexpected jobs: %+v", fi.started)
+	}
+	if fi.held != 1 {
+		t.Fatalf("slot must stay held by the running import, held=%d", fi.held)
+	}
+}
+
+func TestCreateImport_Rejected(t *testing.T) {
+	cases := map[string]struct {
+		fs          *fakeStore
+		fi          *fakeImporter
+		contentType string
+		want        int
+	}{
+		"not importing":    {&fakeStore{status: campaign.StatusQueued}, &fakeImporter{}, "application/json", http.StatusConflict},
+		"unsupported type": {&fakeStore{status: campaign.StatusImporting}, &fakeImporter{}, "text/plain", http.StatusUnsupportedMediaType},
+		"busy":             {&fakeStore{status: campaign.StatusImporting}, &fakeImporter{busy: true}, "application/json", http.StatusServiceUnavailable},
+		"too large":        {&fakeStore{status: campaign.StatusImporting}, &fakeImporter{tooLarge: true}, "application/json", http.StatusRequestEntityTooLarge},
+		"already imported": {&fakeStore{status: campaign.StatusImporting, importExists: true}, &fakeImporter{}, "application/json", http.StatusConflict},
+	}
+	for name, tc := range cases {
+		rr := postImport(&Handlers{Store: tc.fs, Imports: tc.fi}, tc.contentType, `["a@x.com"]`)
+		if rr.Code != tc.want {
+			t.Errorf("%s: want %d, got %d (%s)", name, tc.want, rr.Code, rr.Body.String())
+		}
+		if len(tc.fi.started) != 0 || tc.fi.held != 0 {
+			t.Errorf("%s: import must not start or hold a slot: %+v", name, tc.fi)
+		}
+	}
+}
+
+// multipartImport собирает форму загрузки; поля пишутся после файла, как
+// делают некоторые клиенты.
+func multipartImport(t *testing.T, filename, fileType, content string, fields map[string]string) (string, string) {
+	t.Helper()
+	var b bytes.Buffer
+	w := multipart.NewWriter(&b)
+	if filename != "" {
+		hdr := make(map[string][]string)
+		hdr["Content-Disposition"] = []string{`form-data; name="file"; filename="` + filename + `"`}
+		if fileType != "" {
+			hdr["Content-Type"] = []string{fileType}
+		}
+		part, err := w.CreatePart(hdr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = io.WriteString(part, content)
+	}
+	for k, v := range fields {
+		if err := w.WriteField(k, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return w.FormDataContentType(), b.String()
+}
+
+func TestCreateImport_Multipart(t *testing.T) {
+	fs := &fakeStore{status: campaign.StatusImporting}
+	fi := &fakeImporter{}
+	content := "mail;who;plan\na@x.com;Ann;pro\n"
+	ct, body := multipartImport(t, "list.CSV", "application/octet-stream", content, map[string]string{
+		"mapping":   `{"address":"mail","name":"who","vars":{"tariff":"plan"}}`,
+		"delimiter": ";",
+	})
+
+	rr := postImport(&Handlers{Store: fs, Imports: fi}, ct, body)
+
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("status=%d, body=%s", rr.Code, rr.Body.String())
+	}
+	if fi.spooled != content {
+		t.Fatalf("file not spooled as is: %q", fi.spooled)
+	}
+	if len(fi.started) != 1 {
+		t.Fatalf("unexpected jobs: %+v", fi.started)
+	}
+	spec := fi.started[0].Spec
+	if spec.Format != campaign.ImportFormatCSV || spec.Delimiter != ';' || spec.Mapping == nil ||
+		spec.Mapping.Address != "mail" || spec.Mapping.Name != "who" || spec.Mapping.Vars["tariff"] != "plan" {
+		t.Fatalf("unexpected spec: %+v %+v", spec, spec.Mapping)
+	}
+}
+
+func TestCreateImport_MultipartRejected(t *testing.T) {
+	cases := map[string]struct {
+		filename, fileType string
+		fields             map[string]string
+	}{
+		"no file":         {"", "", map[string]string{"format": "csv"}},
+		"unknown format":  {"list.txt", "text/plain", nil},
+		"bad format":      {"list.csv", "", map[string]string{"format": "xml"}},
+		"bad mapping":     {"list.csv", "", map[string]string{"mapping": `{"adress":"mail"}`}},
+		"long delimiter":  {"list.csv", "", map[string]string{"delimiter": ";;"}},
+		"json delimiter":  {"list.json", "", map[string]string{"delimiter": ";"}},
+		"empty var named": {"list.csv", "", map[string]string{"mapping": `{"vars":{"x":""}}`}},
+	}
+	for name, tc := range cases {
+		fi := &fakeImporter{}
+		ct, body := multipartImport(t, tc.filename, tc.fileType, "email\na@x.com\n", tc.fields)
+		rr := postImport(&Handlers{Store: &fakeStore{status: campaign.StatusImporting}, Imports: fi}, ct, body)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: want 400, got %d (%s)", name, rr.Code, rr.Body.String())
+		}
+		if len(fi.started) != 0 || fi.held != 0 {
+			t.Errorf("%s: import must not start or hold a slot: %+v", name, fi)
+		}
+	}
+}
+
+func TestGetImport(t *testing.T) {
+	srv := NewHTTPServer(":0", &Handlers{Store: &fakeStore{}})
+
+	rr := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/imports/55", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status=%d, body=%s", rr.Code, rr.Body.String())
+	}
+	var got campaign.Import
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != "running" || got.Accepted != 2 || got.Rejected != 1 ||
+		len(got.Errors) != 1 || got.Errors[0].Row != 2 {
+		t.Fatalf("unexpected import: %+v", got)
+	}
+
+	rr = httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/imports/56", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("want 404, got %d", rr.Code)
+	}
+}
@@ -25,6 +25,32 @@ func NewHTTPServer(addr string, h *Handlers) *http.Server {
 	r.POST("/campaigns", h.CreateCampaign)
 	r.GET("/campaigns", h.ListCampaigns)
 	r.GET("/campaigns/:id", h.GetCampaign)
+	r.POST("/campaigns/:id/cancel", h.CancelCampaign)
+	r.POST("/campaigns/:id/pause", h.PauseCampaign)
+	r.POST("/campaigns/:id/resume", h.ResumeCampaign)
+	r.GET("/campaigns/:id/messages/:message_id/attempts", h.ListMessageAttempts)
+	r.POST("/campaigns/:id/imports", h.CreateImport)
+	r.GET("/imports/:id", h.GetImport)
+
+	r.GET("/suppressions", h.ListSuppressions)
+	r.POST("/suppressions", h.CreateSuppression)
+	r.POST("/suppressions/import", h.ImportSuppressions)
+	r.GET("/suppressions/export", h.ExportSuppressions)
+	r.GET("/suppressions/:address", h.GetSuppression)
+	r.DELETE("/suppressions/:address", h.DeleteSuppression)
+
+	r.GET("/u/:token", h.UnsubscribePage)
+	r.POST("/u/:token", h.Unsubscribe)
+	r.POST("/bounces", h.IngestBounce)
+	r.POST("/complaints", h.IngestComplaint)
+	r.GET("/t/o/:token", h.OpenPixel)
+
+	r.GET("/admin/dlq", h.ListDeadLetters)
+	r.DELETE("/admin/dlq", h.PurgeDeadLetters)
+	r.POST("/admin/dlq/replay", h.ReplayAllDeadLetters)
+	r.GET("/admin/dlq/:dlq_id", h.GetDeadLetter)
+	r.DELETE("/admin/dlq/:dlq_id", h.DeleteDeadLetter)
+	r.POST("/admin/dlq/:dlq_id/replay", h.ReplayDeadLetter)
 
 	r.GET("/metrics", gin.WrapH(metrics.Handler()))
 
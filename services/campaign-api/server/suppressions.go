@@ -0,0 +1,356 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Mutter0815/MassMailer/internal/campaign"
+	"github.com/Mutter0815/MassMailer/internal/store"
+	"github.com/Mutter0815/MassMailer/pkg/logx"
+	"github.com/Mutter0815/MassMailer/services/campaign-api/imports"
+)
+
+// suppressionBatch — сколько адресов загрузки пишется одним запросом.
+const suppressionBatch = 5000
+
+// maxSuppressionRowErrors — сколько отклонённых строк загрузки ответ
+// перечисляет поимённо; счётчик rejected учитывает все.
+const maxSuppressionRowErrors = 100
+
+// suppressionCSVHeader — колонки выгрузки; загрузка принимает те же.
+var suppressionCSVHeader = []string{"address", "reason", "source", "created_at", "expires_at"}
+
+func toSuppressionResp(s store.Suppression) campaign.Suppression {
+	return campaign.Suppression{
+		Address:   s.Address,
+		Reason:    s.Reason,
+		Source:    s.Source,
+		CreatedAt: s.CreatedAt,
+		ExpiresAt: s.ExpiresAt,
+	}
+}
+
+// suppressionAddress разбирает адрес из пути так же, как он хранится в списке.
+func suppressionAddress(c *gin.Context) (string, bool) {
+	addr, err := campaign.SuppressionKey(c.Param("address"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return "", false
+	}
+	return addr, true
+}
+
+// ListSuppressions отдаёт список блокировок по возрастанию адреса; следующая
+// страница — after=<последний адрес>.
+func (h *Handlers) ListSuppressions(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	list, err := h.Store.ListSuppressions(ctx, store.SuppressionQuery{
+		Reason: c.Query("reason"),
+		After:  c.Query("after"),
+		Limit:  limit,
+	})
+	if err != nil {
+		logx.L().Errorw("list_suppressions_error", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error"})
+		return
+	}
+	out := make([]campaign.Suppression, 0, len(list))
+	for _, s := range list {
+		out = append(out, toSuppressionResp(s))
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// CreateSuppression добавляет адрес в список блокировок или обновляет
+// причину и срок уже заблокированного.
+func (h *Handlers) CreateSuppression(c *gin.Context) {
+	var req campaign.CreateSuppressionReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	addr, err := campaign.SuppressionKey(req.Address)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	sp := store.Suppression{Address: addr, Reason: req.Reason, Source: req.Source, ExpiresAt: req.ExpiresAt}
+	if sp.Reason == "" {
+		sp.Reason = campaign.SuppressionManual
+	}
+	if sp.Source == "" {
+		sp.Source = "api"
+	}
+	if err := validateSuppression(sp); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	err = h.Store.WithTx(ctx, func(tx *sql.Tx) error {
+		_, err := h.Store.UpsertSuppressions(ctx, tx, []store.Suppression{sp})
+		return err
+	})
+	if err != nil {
+		logx.L().Errorw("create_suppression_error", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error"})
+		return
+	}
+	saved, err := h.Store.GetSuppression(ctx, addr)
+	if err != nil {
+		logx.L().Errorw("get_suppression_error", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error"})
+		return
+	}
+	logx.L().Infow("suppression_saved", "reason", saved.Reason, "source", saved.Source)
+	c.JSON(http.StatusOK, toSuppressionResp(saved))
+}
+
+func (h *Handlers) GetSuppression(c *gin.Context) {
+	addr, ok := suppressionAddress(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	sp, err := h.Store.GetSuppression(ctx, addr)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "address is not suppressed"})
+		return
+	}
+	if err != nil {
+		logx.L().Errorw("get_suppression_error", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error"})
+		return
+	}
+	c.JSON(http.StatusOK, toSuppressionResp(sp))
+}
+
+func (h *Handlers) DeleteSuppression(c *gin.Context) {
+	addr, ok := suppressionAddress(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	deleted, err := h.Store.DeleteSuppression(ctx, addr)
+	if err != nil {
+		logx.L().Errorw("delete_suppression_error", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error"})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "address is not suppressed"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ImportSuppressions загружает список блокировок потоком: CSV с заголовком
+// (address или email, reason, source, expires_at — как в выгрузке), NDJSON
+// или JSON-массив объектов с теми же полями. Пустые reason и source
+// берутся из параметров запроса. Запись идёт пачками, ответ — после
+// последней; строки с ошибками пропускаются.
+func (h *Handlers) ImportSuppressions(c *gin.Context) {
+	mediaType, _, _ := mime.ParseMediaType(c.ContentType())
+	format, ok := importFormats[mediaType]
+	if !ok {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Content-Type must be text/csv, application/x-ndjson or application/json"})
+		return
+	}
+	reason := c.DefaultQuery("reason", campaign.SuppressionManual)
+	if !campaign.IsSuppressionReason(reason) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unknown reason %q", reason)})
+		return
+	}
+	source := c.DefaultQuery("source", "import")
+
+	// без vars все поля, кроме адреса, попадают в Vars получателя
+	src, err := imports.NewSource(c.Request.Body, imports.Spec{Format: format, Mapping: &campaign.ImportMapping{}})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	resp := campaign.SuppressionImportResp{Errors: []campaign.ImportRowError{}}
+	reject := func(row int, err error) {
+		resp.Rejected++
+		if len(resp.Errors) < maxSuppressionRowErrors {
+			resp.Errors = append(resp.Errors, campaign.ImportRowError{Row: row, Error: err.Error()})
+		}
+	}
+	batch := make([]store.Suppression, 0, suppressionBatch)
+	flush := func() error {
+		err := h.Store.WithTx(ctx, func(tx *sql.Tx) error {
+			_, err := h.Store.UpsertSuppressions(ctx, tx, batch)
+			return err
+		})
+		batch = batch[:0]
+		return err
+	}
+
+	for {
+		r, err := src.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var rowErr *imports.RowError
+		if errors.As(err, &rowErr) {
+			resp.Processed++
+			reject(rowErr.Row, rowErr.Err)
+			continue
+		}
+		if err != nil {
+			// предыдущие пачки уже записаны; accepted — сколько именно
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "processed": resp.Processed, "accepted": resp.Accepted - len(batch)})
+			return
+		}
+
+		resp.Processed++
+		sp, err := suppressionFromRow(r, reason, source)
+		if err != nil {
+			reject(src.Row(), err)
+			continue
+		}
+		resp.Accepted++
+		batch = append(batch, sp)
+		if len(batch) == suppressionBatch {
+			if err := flush(); err != nil {
+				logx.L().Errorw("import_suppressions_error", "error", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "db error", "processed": resp.Processed})
+				return
+			}
+		}
+	}
+	if len(batch) > 0 {
+		if err := flush(); err != nil {
+			logx.L().Errorw("import_suppressions_error", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "db error", "processed": resp.Processed})
+			return
+		}
+	}
+
+	logx.L().Infow("suppressions_imported", "format", format, "accepted", resp.Accepted, "rejected", resp.Rejected)
+	c.JSON(http.StatusOK, resp)
+}
+
+// suppressionFromRow собирает запись из строки загрузки: адрес уже
+// нормализован источником, остальные колонки лежат в Vars.
+func suppressionFromRow(r campaign.Recipient, reason, source string) (store.Suppression, error) {
+	sp := store.Suppression{Address: strings.ToLower(r.Address), Reason: reason, Source: source}
+	v, err := stringVar(r.Vars, "reason")
+	if err != nil {
+		return store.Suppression{}, err
+	}
+	if v != "" {
+		sp.Reason = v
+	}
+	if v, err = stringVar(r.Vars, "source"); err != nil {
+		return store.Suppression{}, err
+	}
+	if v != "" {
+		sp.Source = v
+	}
+	if v, err = stringVar(r.Vars, "expires_at"); err != nil {
+		return store.Suppression{}, err
+	}
+	if v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return store.Suppression{}, fmt.Errorf("expires_at: %w", err)
+		}
+		sp.ExpiresAt = &t
+	}
+	return sp, validateSuppression(sp)
+}
+
+func stringVar(vars map[string]any, name string) (string, error) {
+	switch v := vars[name].(type) {
+	case nil:
+		return "", nil
+	case string:
+		return strings.TrimSpace(v), nil
+	}
+	return "", fmt.Errorf("%s must be a string", name)
+}
+
+func validateSuppression(sp store.Suppression) error {
+	if !campaign.IsSuppressionReason(sp.Reason) {
+		return fmt.Errorf("unknown reason %q", sp.Reason)
+	}
+	if sp.ExpiresAt != nil && !sp.ExpiresAt.After(time.Now()) {
+		return errors.New("expires_at must be in the future")
+	}
+	return nil
+}
+
+// ExportSuppressions выгружает весь список блокировок в CSV, страницами по
+// suppressionBatch, не держа его в памяти.
+func (h *Handlers) ExportSuppressions(c *gin.Context) {
+	ctx := c.Request.Context()
+	q := store.SuppressionQuery{Reason: c.Query("reason"), Limit: suppressionBatch}
+
+	// первую страницу читаем до заголовков ответа, чтобы ошибка БД ушла как 500
+	list, err := h.Store.ListSuppressions(ctx, q)
+	if err != nil {
+		logx.L().Errorw("export_suppressions_error", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error"})
+		return
+	}
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", `attachment; filename="suppressions.csv"`)
+	c.Status(http.StatusOK)
+
+	w := csv.NewWriter(c.Writer)
+	_ = w.Write(suppressionCSVHeader)
+	exported := 0
+	for {
+		for _, s := range list {
+			expires := ""
+			if s.ExpiresAt != nil {
+				expires = s.ExpiresAt.UTC().Format(time.RFC3339)
+			}
+			_ = w.Write([]string{s.Address, s.Reason, s.Source, s.CreatedAt.UTC().Format(time.RFC3339), expires})
+		}
+		w.Flush()
+		exported += len(list)
+		if len(list) < q.Limit {
+			break
+		}
+		q.After = list[len(list)-1].Address
+		if list, err = h.Store.ListSuppressions(ctx, q); err != nil {
+			// заголовки уже ушли: обрываем выгрузку, клиент получит неполный файл
+			logx.L().Errorw("export_suppressions_error", "exported", exported, "error", err)
+			return
+		}
+	}
+	if err := w.Error(); err != nil {
+		logx.L().Warnw("export_suppressions_write_error", "exported", exported, "error", err)
+	}
+}
@@ -0,0 +1,216 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Mutter0815/MassMailer/internal/campaign"
+	"github.com/Mutter0815/MassMailer/internal/store"
+)
+
+func (f *fakeStore) UpsertSuppressions(ctx context.Context, q store.Querier, ss []store.Suppression) (int, error) {
+	if f.suppressions == nil {
+		f.suppressions = map[string]store.Suppression{}
+	}
+	for _, s := range ss {
+		s.CreatedAt = time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+		f.suppressions[s.Address] = s
+	}
+	return len(ss), nil
+}
+
+func (f *fakeStore) GetSuppression(ctx context.Context, address string) (store.Suppression, error) {
+	s, ok := f.suppressions[address]
+	if !ok {
+		return store.Suppression{}, sql.ErrNoRows
+	}
+	return s, nil
+}
+
+func (f *fakeStore) DeleteSuppression(ctx context.Context, address string) (bool, error) {
+	_, ok := f.suppressions[address]
+	delete(f.suppressions, address)
+	return ok, nil
+}
+
+func (f *fakeStore) ListSuppressions(ctx context.Context, q store.SuppressionQuery) ([]store.Suppression, error) {
+	var addrs []string
+	for a, s := range f.suppressions {
+		if a > q.After && (q.Reason == "" || s.Reason == q.Reason) {
+			addrs = append(addrs, a)
+		}
+	}
+	sort.Strings(addrs)
+	out := []store.Suppression{}
+	for _, a := range addrs {
+		if len(out) == q.Limit {
+			break
+		}
+		out = append(out, f.suppressions[a])
+	}
+	return out, nil
+}
+
+func doJSON(t *testing.T, h *Handlers, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	NewHTTPServer(":0", h).Handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestSuppressionsCRUD(t *testing.T) {
+	fs := &fakeStore{}
+	h := &Handlers{Store: fs}
+
+	rr := doJSON(t, h, http.MethodPost, "/suppressions", `{"address":"Ann <Ann@Example.COM>","reason":"unsubscribe"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("create: status=%d, body=%s", rr.Code, rr.Body.String())
+	}
+	var got campaign.Suppression
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Address != "ann@example.com" || got.Reason != campaign.SuppressionUnsubscribe || got.Source != "api" {
+		t.Fatalf("unexpected suppression: %+v", got)
+	}
+
+	if rr := doJSON(t, h, http.MethodGet, "/suppressions/ANN@example.com", ""); rr.Code != http.StatusOK {
+		t.Fatalf("get: status=%d", rr.Code)
+	}
+	if rr := doJSON(t, h, http.MethodDelete, "/suppressions/ann@example.com", ""); rr.Code != http.StatusNoContent {
+		t.Fatalf("delete: status=%d", rr.Code)
+	}
+	if rr := doJSON(t, h, http.MethodDelete, "/suppressions/ann@example.com", ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("second delete: status=%d", rr.Code)
+	}
+	if rr := doJSON(t, h, http.MethodGet, "/suppressions/ann@example.com", ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("get deleted: status=%d", rr.Code)
+	}
+}
+
+func TestCreateSuppression_Invalid(t *testing.T) {
+	cases := map[string]string{
+		"bad address": `{"address":"foo"}`,
+		"bad reason":  `{"address":"a@example.com","reason":"spam"}`,
+		"expired":     `{"address":"a@example.com","expires_at":"2020-01-01T00:00:00Z"}`,
+		"no address":  `{}`,
+	}
+	for name, body := range cases {
+		fs := &fakeStore{}
+		if rr := doJSON(t, &Handlers{Store: fs}, http.MethodPost, "/suppressions", body); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: want 400, got %d", name, rr.Code)
+		}
+		if len(fs.suppressions) != 0 {
+			t.Errorf("%s: nothing must be stored", name)
+		}
+	}
+}
+
+func TestListSuppressions_Pages(t *testing.T) {
+	fs := &fakeStore{suppressions: map[string]store.Suppression{
+		"a@x.com": {Address: "a@x.com", Reason: "manual"},
+		"b@x.com": {Address: "b@x.com", Reason: "complaint"},
+		"c@x.com": {Address: "c@x.com", Reason: "manual"},
+	}}
+	rr := doJSON(t, &Handlers{Store: fs}, http.MethodGet, "/suppressions?reason=manual&after=a@x.com&limit=10", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status=%d", rr.Code)
+	}
+	var got []campaign.Suppression
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Address != "c@x.com" {
+		t.Fatalf("unexpected page: %+v", got)
+	}
+}
+
+func TestImportSuppressions_CSV(t *testing.T) {
+	fs := &fakeStore{}
+	body := "Email,reason,source,expires_at\n" +
+		"A@Example.com,,,\n" +
+		"b@example.com,complaint,fbl,2999-01-01T00:00:00Z\n" +
+		"foo,,,\n" +
+		"c@example.com,spam,,\n" +
+		"d@example.com,,,yesterday\n"
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/suppressions/import?reason=hard_bounce", strings.NewReader(body))
+	req.Header.Set("Content-Type", "text/csv")
+	NewHTTPServer(":0", &Handlers{Store: fs}).Handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status=%d, body=%s", rr.Code, rr.Body.String())
+	}
+	var resp campaign.SuppressionImportResp
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Processed != 5 || resp.Accepted != 2 || resp.Rejected != 3 || len(resp.Errors) != 3 {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+	if resp.Errors[0].Row != 4 || resp.Errors[1].Row != 5 || resp.Errors[2].Row != 6 {
+		t.Fatalf("unexpected rows: %+v", resp.Errors)
+	}
+	a, b := fs.suppressions["a@example.com"], fs.suppressions["b@example.com"]
+	if a.Reason != campaign.SuppressionHardBounce || a.Source != "import" || a.ExpiresAt != nil {
+		t.Fatalf("defaults not applied: %+v", a)
+	}
+	if b.Reason != campaign.SuppressionComplaint || b.Source != "fbl" || b.ExpiresAt == nil {
+		t.Fatalf("row values not applied: %+v", b)
+	}
+}
+
+func TestExportSuppressions_AllPages(t *testing.T) {
+	fs := &fakeStore{suppressions: map[string]store.Suppression{}}
+	for i := 0; i < suppressionBatch+3; i++ {
+		a := fmt.Sprintf("u%05d@x.com", i)
+		fs.suppressions[a] = store.Suppression{Address: a, Reason: "manual", CreatedAt: time.Unix(0, 0)}
+	}
+
+	rr := doJSON(t, &Handlers{Store: fs}, http.MethodGet, "/suppressions/export", "")
+	if rr.Code != http.StatusOK || !strings.HasPrefix(rr.Header().Get("Content-Type"), "text/csv") {
+		t.Fatalf("status=%d, type=%q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	records, err := csv.NewReader(rr.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != suppressionBatch+4 || strings.Join(records[0], ",") != "address,reason,source,created_at,expires_at" {
+		t.Fatalf("unexpected export: %d records, header %v", len(records), records[0])
+	}
+	if last := records[len(records)-1]; last[0] != fmt.Sprintf("u%05d@x.com", suppressionBatch+2) {
+		t.Fatalf("unexpected last record: %v", last)
+	}
+}
+
+func TestCreateCampaign_CountsSuppressed(t *testing.T) {
+	fs := &fakeStore{suppressions: map[string]store.Suppression{"b@example.com": {Address: "b@example.com"}}}
+	rr := doJSON(t, &Handlers{Store: fs, Retry: testRetry}, http.MethodPost, "/campaigns", `{
+		"name":"X","body":"Y",
+		"scheduled_at":"2025-10-02T12:00:00Z",
+		"recipients":["a@example.com","B@example.com"]
+	}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status=%d, body=%s", rr.Code, rr.Body.String())
+	}
+	var resp campaign.CreateCampaignResp
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Recipients == nil || resp.Recipients.Accepted != 2 || resp.Recipients.Suppressed != 1 {
+		t.Fatalf("unexpected report: %+v", resp.Recipients)
+	}
+}
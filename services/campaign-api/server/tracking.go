@@ -0,0 +1,40 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Mutter0815/MassMailer/internal/token"
+	"github.com/Mutter0815/MassMailer/pkg/metrics"
+)
+
+type openRecorder interface {
+	Record(campaignID, recipientID int64, at time.Time) bool
+}
+
+// pixelGIF — прозрачный GIF 1×1.
+var pixelGIF = []byte{
+	0x47, 0x49, 0x46, 0x38, 0x39, 0x61, 0x01, 0x00, 0x01, 0x00, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0xff, 0xff, 0xff, 0x21, 0xf9, 0x04, 0x01, 0x00, 0x00, 0x00, 0x00, 0x2c, 0x00, 0x00, 0x00, 0x00,
+	0x01, 0x00, 0x01, 0x00, 0x00, 0x02, 0x02, 0x44, 0x01, 0x00, 0x3b,
+}
+
+// OpenPixel отдаёт пиксель открытия письма. Открытие только передаётся
+// Opens, запись в базу идёт в фоне пачками. Пиксель отдаётся и на неверный
+// токен: битая картинка в письме ничего не даёт получателю.
+func (h *Handlers) OpenPixel(c *gin.Context) {
+	if h.Links != nil && h.Opens != nil {
+		if campaignID, recipientID, err := h.Links.Verify(token.KindOpen, c.Param("token")); err == nil {
+			if h.Opens.Record(campaignID, recipientID, time.Now()) {
+				metrics.APIOpens.Inc()
+			}
+		}
+	}
+	// прокси почтовых клиентов кешируют картинки — повторные открытия
+	// иначе не дойдут
+	c.Header("Cache-Control", "no-store, no-cache, must-revalidate, private")
+	c.Header("Expires", "0")
+	c.Data(http.StatusOK, "image/gif", pixelGIF)
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"image/gif"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Mutter0815/MassMailer/internal/campaign"
+	"github.com/Mutter0815/MassMailer/internal/store"
+	"github.com/Mutter0815/MassMailer/internal/token"
+)
+
+type fakeOpens struct{ got [][2]int64 }
+
+func (f *fakeOpens) Record(campaignID, recipientID int64, at time.Time) bool {
+	f.got = append(f.got, [2]int64{campaignID, recipientID})
+	return true
+}
+
+func getPixel(h *Handlers, tok string) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	NewHTTPServer(":0", h).Handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/t/o/"+tok, nil))
+	return rr
+}
+
+func TestOpenPixel_RecordsOpen(t *testing.T) {
+	links := token.NewSigner("secret")
+	opens := &fakeOpens{}
+	h := &Handlers{Store: &fakeStore{}, Links: links, Opens: opens}
+
+	rr := getPixel(h, links.Sign(token.KindOpen, 42, 3))
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "image/gif" {
+		t.Fatalf("status=%d, content-type=%q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	img, err := gif.Decode(bytes.NewReader(rr.Body.Bytes()))
+	if err != nil || img.Bounds().Dx() != 1 || img.Bounds().Dy() != 1 {
+		t.Fatalf("want a 1x1 gif, got err=%v", err)
+	}
+	if rr.Header().Get("Cache-Control") == "" {
+		t.Fatal("pixel must not be cached")
+	}
+	if len(opens.got) != 1 || opens.got[0] != [2]int64{42, 3} {
+		t.Fatalf("unexpected opens: %v", opens.got)
+	}
+}
+
+func TestOpenPixel_IgnoresInvalidToken(t *testing.T) {
+	links := token.NewSigner("secret")
+	opens := &fakeOpens{}
+	h := &Handlers{Store: &fakeStore{}, Links: links, Opens: opens}
+
+	for _, tok := range []string{
+		"garbage",
+		links.Sign(token.KindUnsubscribe, 42, 3),
+		token.NewSigner("x").Sign(token.KindOpen, 42, 3),
+	} {
+		if rr := getPixel(h, tok); rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "image/gif" {
+			t.Fatalf("%s: status=%d", tok, rr.Code)
+		}
+	}
+	if rr := getPixel(&Handlers{Store: &fakeStore{}}, links.Sign(token.KindOpen, 42, 3)); rr.Code != http.StatusOK {
+		t.Fatalf("disabled: status=%d", rr.Code)
+	}
+	if len(opens.got) != 0 {
+		t.Fatalf("nothing must be recorded: %v", opens.got)
+	}
+}
+
+func TestGetCampaign_OpenStats(t *testing.T) {
+	h := &Handlers{Store: &fakeStore{}}
+	rr := httptest.NewRecorder()
+	NewHTTPServer(":0", h).Handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/campaigns/1", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status=%d, body=%s", rr.Code, rr.Body.String())
+	}
+	var resp campaign.CampaignDetails
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Stats.Opens != 5 || resp.Stats.UniqueOpens != 1 || resp.Stats.UniqueOpenRate != 0.5 {
+		t.Fatalf("unexpected stats: %+v", resp.Stats)
+	}
+}
+
+func TestGetCampaign_OpenRateCountsBouncedAsDelivered(t *testing.T) {
+	// оба письма открыли, одно потом вернулось DSN и стало bounced
+	h := &Handlers{Store: &fakeStore{stats: &store.CampaignStats{Total: 2, Sent: 1, Bounced: 1, Opens: 3, Opened: 2}}}
+	rr := httptest.NewRecorder()
+	NewHTTPServer(":0", h).Handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/campaigns/1", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status=%d, body=%s", rr.Code, rr.Body.String())
+	}
+	var resp campaign.CampaignDetails
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Stats.UniqueOpenRate != 1 {
+		t.Fatalf("open rate must not exceed 1, got %v", resp.Stats.UniqueOpenRate)
+	}
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"html/template"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Mutter0815/MassMailer/internal/campaign"
+	"github.com/Mutter0815/MassMailer/internal/token"
+	"github.com/Mutter0815/MassMailer/pkg/logx"
+	"github.com/Mutter0815/MassMailer/pkg/metrics"
+)
+
+// unsubscribePage — страница ссылки отписки: с формой подтверждения или
+// с итогом, если Done.
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Unsubscribe</title></head>
+<body>
+{{if .Done}}<p>You have been unsubscribed and will not receive further emails.</p>
+{{else}}<form method="post">
+<p>Stop receiving emails at this address?</p>
+<button type="submit" name="List-Unsubscribe" value="One-Click">Unsubscribe</button>
+</form>
+{{end}}</body></html>
+`))
+
+// unsubscribeToken проверяет токен из пути. На неверный токен и выключенные
+// ссылки ответ одинаковый, чтобы не раскрывать, какие токены бывают.
+func (h *Handlers) unsubscribeToken(c *gin.Context) (campaignID, recipientID int64, ok bool) {
+	if h.Links == nil {
+		c.String(http.StatusNotFound, "link is not valid")
+		return 0, 0, false
+	}
+	campaignID, recipientID, err := h.Links.Verify(token.KindUnsubscribe, c.Param("token"))
+	if err != nil {
+		c.String(http.StatusNotFound, "link is not valid")
+		return 0, 0, false
+	}
+	return campaignID, recipientID, true
+}
+
+func renderUnsubscribePage(c *gin.Context, done bool) {
+	c.Status(http.StatusOK)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	_ = unsubscribePage.Execute(c.Writer, struct{ Done bool }{done})
+}
+
+// UnsubscribePage только показывает подтверждение: GET по ссылке из письма
+// делают и сканеры ссылок, поэтому отписывает лишь POST (RFC 8058).
+func (h *Handlers) UnsubscribePage(c *gin.Context) {
+	if _, _, ok := h.unsubscribeToken(c); !ok {
+		return
+	}
+	renderUnsubscribePage(c, false)
+}
+
+// Unsubscribe добавляет адрес получателя в список блокировок. Сюда приходят
+// и one-click POST почтового клиента по List-Unsubscribe-Post, и форма со
+// страницы подтверждения; повторный запрос ничего не меняет.
+func (h *Handlers) Unsubscribe(c *gin.Context) {
+	campaignID, recipientID, ok := h.unsubscribeToken(c)
+	if !ok {
+		return
+	}
+	source := "campaign:" + strconv.FormatInt(campaignID, 10)
+	_, err := h.Store.SuppressRecipient(c.Request.Context(), campaignID, recipientID, campaign.SuppressionUnsubscribe, source)
+	if errors.Is(err, sql.ErrNoRows) {
+		// кампанию или получателя уже удалили
+		c.String(http.StatusNotFound, "link is not valid")
+		return
+	}
+	if err != nil {
+		logx.L().Errorw("db_unsubscribe_error", "campaign_id", campaignID, "recipient_id", recipientID, "error", err)
+		c.String(http.StatusInternalServerError, "please try again later")
+		return
+	}
+	metrics.APIUnsubscribes.Inc()
+	logx.L().Infow("recipient_unsubscribed", "campaign_id", campaignID, "recipient_id", recipientID)
+	renderUnsubscribePage(c, true)
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Mutter0815/MassMailer/internal/campaign"
+	"github.com/Mutter0815/MassMailer/internal/store"
+	"github.com/Mutter0815/MassMailer/internal/token"
+)
+
+// SuppressRecipient считает id получателя кампании 42 его номером в recipients, с 1.
+func (f *fakeStore) SuppressRecipient(ctx context.Context, campaignID, recipientID int64, reason, source string) (string, error) {
+	if campaignID != 42 || recipientID < 1 || int(recipientID) > len(f.recipients) {
+		return "", sql.ErrNoRows
+	}
+	addr := strings.ToLower(f.recipients[recipientID-1].Address)
+	if f.suppressions == nil {
+		f.suppressions = map[string]store.Suppression{}
+	}
+	if _, ok := f.suppressions[addr]; !ok {
+		f.suppressions[addr] = store.Suppression{Address: addr, Reason: reason, Source: source}
+	}
+	return addr, nil
+}
+
+func doUnsubscribe(h *Handlers, method, tok, body string) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(method, "/u/"+tok, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	NewHTTPServer(":0", h).Handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestUnsubscribe_OneClick(t *testing.T) {
+	links := token.NewSigner("secret")
+	fs := &fakeStore{recipients: []store.NewRecipient{{Address: "Ann@Example.com"}}}
+	h := &Handlers{Store: fs, Links: links}
+	tok := links.Sign(token.KindUnsubscribe, 42, 1)
+
+	rr := doUnsubscribe(h, http.MethodGet, tok, "")
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `<form method="post">`) {
+		t.Fatalf("GET: status=%d, body=%s", rr.Code, rr.Body.String())
+	}
+	if len(fs.suppressions) != 0 {
+		t.Fatal("GET must not unsubscribe")
+	}
+
+	for i := 0; i < 2; i++ {
+		rr = doUnsubscribe(h, http.MethodPost, tok, "List-Unsubscribe=One-Click")
+		if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "unsubscribed") {
+			t.Fatalf("POST #%d: status=%d, body=%s", i+1, rr.Code, rr.Body.String())
+		}
+	}
+	got, ok := fs.suppressions["ann@example.com"]
+	if !ok || got.Reason != campaign.SuppressionUnsubscribe || got.Source != "campaign:42" {
+		t.Fatalf("unexpected suppressions: %+v", fs.suppressions)
+	}
+}
+
+func TestUnsubscribe_InvalidLink(t *testing.T) {
+	links := token.NewSigner("secret")
+	fs := &fakeStore{recipients: []store.NewRecipient{{Address: "a@example.com"}}}
+
+	cases := map[string]struct {
+		h   *Handlers
+		tok string
+	}{
+		"forged":         {&Handlers{Store: fs, Links: links}, token.NewSigner("other").Sign(token.KindUnsubscribe, 42, 1)},
+		"garbage":        {&Handlers{Store: fs, Links: links}, "abc"},
+		"unknown":        {&Handlers{Store: fs, Links: links}, links.Sign(token.KindUnsubscribe, 42, 2)},
+		"links disabled": {&Handlers{Store: fs}, links.Sign(token.KindUnsubscribe, 42, 1)},
+	}
+	for name, tc := range cases {
+		if rr := doUnsubscribe(tc.h, http.MethodPost, tc.tok, ""); rr.Code != http.StatusNotFound {
+			t.Errorf("%s: want 404, got %d", name, rr.Code)
+		}
+	}
+	if len(fs.suppressions) != 0 {
+		t.Fatalf("nothing must be suppressed: %+v", fs.suppressions)
+	}
+}
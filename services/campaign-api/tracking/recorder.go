@@ -0,0 +1,123 @@
+// Package tracking копит события отслеживания из писем и пишет их в базу
+// пачками, чтобы запрос пикселя не ждал базу.
+package tracking
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Mutter0815/MassMailer/internal/store"
+	"github.com/Mutter0815/MassMailer/pkg/logx"
+	"github.com/Mutter0815/MassMailer/pkg/metrics"
+)
+
+type storeAPI interface {
+	RecordOpens(ctx context.Context, opens []store.MessageOpen) error
+}
+
+type messageKey struct {
+	campaignID  int64
+	recipientID int64
+}
+
+// Recorder собирает открытия в памяти, сворачивая повторные открытия одного
+// сообщения, и раз в Interval записывает накопленное одним запросом. При
+// остановке накопленное дописывается; падение процесса теряет открытия за
+// последний Interval.
+type Recorder struct {
+	Store    storeAPI
+	Interval time.Duration
+	// MaxPending ограничивает число разных сообщений в памяти: сверх него
+	// открытия новых сообщений отбрасываются до следующей записи.
+	MaxPending int
+	// FlushTimeout — бюджет на последнюю запись при остановке.
+	FlushTimeout time.Duration
+
+	mu      sync.Mutex
+	pending map[messageKey]*store.MessageOpen
+}
+
+func New(st *store.Store, interval time.Duration) *Recorder {
+	return &Recorder{Store: st, Interval: interval, MaxPending: 100_000, FlushTimeout: 5 * time.Second}
+}
+
+// Record учитывает открытие сообщения и никогда не блокируется на базе;
+// false — открытие отброшено из-за переполнения.
+func (r *Recorder) Record(campaignID, recipientID int64, at time.Time) bool {
+	return r.add(store.MessageOpen{CampaignID: campaignID, RecipientID: recipientID, Count: 1, First: at, Last: at})
+}
+
+func (r *Recorder) add(o store.MessageOpen) bool {
+	k := messageKey{o.CampaignID, o.RecipientID}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if cur, ok := r.pending[k]; ok {
+		cur.Count += o.Count
+		if o.First.Before(cur.First) {
+			cur.First = o.First
+		}
+		if o.Last.After(cur.Last) {
+			cur.Last = o.Last
+		}
+		return true
+	}
+	if len(r.pending) >= r.MaxPending {
+		metrics.APIOpensDropped.Add(float64(o.Count))
+		return false
+	}
+	if r.pending == nil {
+		r.pending = make(map[messageKey]*store.MessageOpen)
+	}
+	r.pending[k] = &o
+	return true
+}
+
+func (r *Recorder) Run(ctx context.Context) {
+	logx.L().Infow("open_recorder_started", "interval", r.Interval.String())
+	t := time.NewTicker(r.Interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// ctx уже отменён — последняя запись идёт со своим бюджетом
+			fctx, cancel := context.WithTimeout(context.Background(), r.FlushTimeout)
+			if _, err := r.Flush(fctx); err != nil {
+				logx.L().Errorw("open_recorder_flush_error", "error", err)
+			}
+			cancel()
+			logx.L().Infow("open_recorder_stopped")
+			return
+		case <-t.C:
+			if _, err := r.Flush(ctx); err != nil {
+				logx.L().Errorw("open_recorder_flush_error", "error", err)
+			}
+		}
+	}
+}
+
+// Flush записывает накопленные открытия и возвращает число сообщений.
+// Если запись не удалась, открытия возвращаются в очередь и будут
+// записаны следующим Flush.
+func (r *Recorder) Flush(ctx context.Context) (int, error) {
+	r.mu.Lock()
+	batch := r.pending
+	r.pending = nil
+	r.mu.Unlock()
+	if len(batch) == 0 {
+		return 0, nil
+	}
+
+	opens := make([]store.MessageOpen, 0, len(batch))
+	for _, o := range batch {
+		opens = append(opens, *o)
+	}
+	if err := r.Store.RecordOpens(ctx, opens); err != nil {
+		for _, o := range opens {
+			r.add(o)
+		}
+		return 0, err
+	}
+	return len(opens), nil
+}
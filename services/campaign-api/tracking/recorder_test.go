@@ -0,0 +1,99 @@
+package tracking
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/Mutter0815/MassMailer/internal/store"
+)
+
+type fakeStore struct {
+	batches [][]store.MessageOpen
+	err     error
+}
+
+func (f *fakeStore) RecordOpens(ctx context.Context, opens []store.MessageOpen) error {
+	if f.err != nil {
+		return f.err
+	}
+	sort.Slice(opens, func(i, j int) bool { return opens[i].RecipientID < opens[j].RecipientID })
+	f.batches = append(f.batches, opens)
+	return nil
+}
+
+func TestRecorder_FoldsOpensPerMessage(t *testing.T) {
+	fs := &fakeStore{}
+	r := &Recorder{Store: fs, MaxPending: 10}
+	t0 := time.Date(2025, 10, 2, 12, 0, 0, 0, time.UTC)
+
+	r.Record(7, 101, t0.Add(time.Minute))
+	r.Record(7, 101, t0)
+	r.Record(7, 101, t0.Add(2*time.Minute))
+	r.Record(7, 102, t0)
+
+	n, err := r.Flush(context.Background())
+	if err != nil || n != 2 {
+		t.Fatalf("Flush = %d, %v", n, err)
+	}
+	want := []store.MessageOpen{
+		{CampaignID: 7, RecipientID: 101, Count: 3, First: t0, Last: t0.Add(2 * time.Minute)},
+		{CampaignID: 7, RecipientID: 102, Count: 1, First: t0, Last: t0},
+	}
+	if len(fs.batches) != 1 || len(fs.batches[0]) != 2 || fs.batches[0][0] != want[0] || fs.batches[0][1] != want[1] {
+		t.Fatalf("unexpected batches: %+v", fs.batches)
+	}
+	if n, _ := r.Flush(context.Background()); n != 0 || len(fs.batches) != 1 {
+		t.Fatalf("second flush must be empty, got %d", n)
+	}
+}
+
+func TestRecorder_DropsOverLimit(t *testing.T) {
+	r := &Recorder{Store: &fakeStore{}, MaxPending: 1}
+	now := time.Now()
+	if !r.Record(7, 101, now) || !r.Record(7, 101, now) {
+		t.Fatal("opens of a pending message must be accepted")
+	}
+	if r.Record(7, 102, now) {
+		t.Fatal("open of a new message over the limit must be dropped")
+	}
+}
+
+func TestRecorder_KeepsOpensOnStoreError(t *testing.T) {
+	fs := &fakeStore{err: errors.New("db down")}
+	r := &Recorder{Store: fs, MaxPending: 10}
+	t0 := time.Date(2025, 10, 2, 12, 0, 0, 0, time.UTC)
+	r.Record(7, 101, t0)
+
+	if _, err := r.Flush(context.Background()); err == nil {
+		t.Fatal("want store error")
+	}
+	r.Record(7, 101, t0.Add(time.Minute))
+	fs.err = nil
+	if n, err := r.Flush(context.Background()); err != nil || n != 1 {
+		t.Fatalf("Flush = %d, %v", n, err)
+	}
+	if got := fs.batches[0][0]; got.Count != 2 || !got.First.Equal(t0) || !got.Last.Equal(t0.Add(time.Minute)) {
+		t.Fatalf("unexpected open: %+v", got)
+	}
+}
+
+func TestRecorder_RunFlushesOnStop(t *testing.T) {
+	fs := &fakeStore{}
+	r := &Recorder{Store: fs, Interval: time.Hour, MaxPending: 10, FlushTimeout: time.Second}
+	r.Record(7, 101, time.Now())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		r.Run(ctx)
+		close(done)
+	}()
+	cancel()
+	<-done
+	if len(fs.batches) != 1 {
+		t.Fatalf("pending opens must be flushed on stop, got %+v", fs.batches)
+	}
+}
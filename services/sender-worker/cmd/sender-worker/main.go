@@ -2,17 +2,22 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"net/mail"
 	"os"
 	"os/signal"
 	"syscall"
 
+	"github.com/Mutter0815/MassMailer/internal/campaign"
 	"github.com/Mutter0815/MassMailer/internal/store"
+	"github.com/Mutter0815/MassMailer/internal/token"
 	"github.com/Mutter0815/MassMailer/pkg/config"
 	"github.com/Mutter0815/MassMailer/pkg/db"
 	"github.com/Mutter0815/MassMailer/pkg/logx"
 	"github.com/Mutter0815/MassMailer/pkg/metrics"
 	"github.com/Mutter0815/MassMailer/pkg/rmq"
+	"github.com/Mutter0815/MassMailer/services/sender-worker/sender"
 	"github.com/Mutter0815/MassMailer/services/sender-worker/worker"
 )
 
@@ -34,7 +39,7 @@ func main() {
 		}
 	}()
 
-	cons, err := rmq.NewConsumer(cfg.RMQURL, cfg.Queue)
+	cons, err := rmq.NewConsumer(cfg.RMQURL, cfg.Queue, cfg.Prefetch)
 	if err != nil {
 		logx.L().Fatalw("rmq_consumer_error", "error", err)
 	}
@@ -62,15 +67,61 @@ func main() {
 		_ = http.ListenAndServe(metricsAddr, mux)
 	}()
 
-	w := worker.New(store.New(sqlDB), cons, pub)
+	snd, err := newSender(cfg)
+	if err != nil {
+		logx.L().Fatalw("sender_init_error", "error", err)
+	}
+
+	if cfg.BounceAddress != "" {
+		if a, err := mail.ParseAddress(cfg.BounceAddress); err != nil || a.Address != cfg.BounceAddress {
+			logx.L().Fatalw("bounce_address_invalid", "address", cfg.BounceAddress)
+		}
+		if cfg.LinkSecret == "" {
+			logx.L().Warnw("verp_disabled", "reason", "LINK_SECRET is not set")
+		}
+	}
+
+	w := worker.New(store.New(sqlDB), cons, pub, snd, worker.Options{
+		From:          cfg.MailFrom,
+		Concurrency:   cfg.Concurrency,
+		DrainTimeout:  cfg.DrainTimeout,
+		Retry:         campaign.RetryPolicy(cfg.Retry),
+		WorkerID:      cfg.WorkerID,
+		ClaimLease:    cfg.ClaimLease,
+		Links:         token.NewSigner(cfg.LinkSecret),
+		PublicURL:     cfg.PublicURL,
+		BounceAddress: cfg.BounceAddress,
+	})
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
+	go worker.NewFinalizer(w.Store, cfg.FinalizeInterval, cfg.FailureThreshold).Run(ctx)
+	go worker.NewReconciler(w.Store, cfg.ReconcileInterval, cfg.StuckAfter).Run(ctx)
+
 	if err := w.Run(ctx, sqlDB); err != nil && err != context.Canceled {
 		logx.L().Fatalw("worker_error", "error", err)
 	}
 }
 
+func newSender(cfg config.WorkerConfig) (sender.Sender, error) {
+	switch cfg.Transport {
+	case "smtp":
+		return sender.NewSMTP(sender.SMTPConfig{
+			Host:               cfg.SMTP.Host,
+			Port:               cfg.SMTP.Port,
+			Username:           cfg.SMTP.Username,
+			Password:           cfg.SMTP.Password,
+			Auth:               cfg.SMTP.Auth,
+			TLSMode:            cfg.SMTP.TLSMode,
+			HelloName:          cfg.SMTP.HelloName,
+			Timeout:            cfg.SMTP.Timeout,
+			InsecureSkipVerify: cfg.SMTP.InsecureSkipVerify,
+		})
+	default:
+		return nil, fmt.Errorf("unknown mail transport %q", cfg.Transport)
+	}
+}
+
 func getenv(k, d string) string {
 	if v := os.Getenv(k); v != "" {
 		return v
@@ -0,0 +1,311 @@
+// Package message собирает письма по RFC 5322 / RFC 2045: multipart/alternative
+// для HTML и текста, multipart/mixed для вложений, quoted-printable и base64,
+// RFC 2047 для не-ASCII заголовков и Message-ID.
+package message
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxLineLen — жёсткий предел длины строки из RFC 5322 без CRLF.
+const maxLineLen = 998
+
+// foldWidth — рекомендуемая ширина строки заголовка.
+const foldWidth = 78
+
+var ErrNoBody = errors.New("message: neither text nor html body set")
+
+// ErrHeaderTooLong — заголовок содержит слово, которое не перенести так,
+// чтобы строка уложилась в maxLineLen.
+var ErrHeaderTooLong = errors.New("message: header line exceeds 998 octets")
+
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+type Message struct {
+	From    mail.Address
+	To      []string
+	ReplyTo string
+	Subject string
+	Date    time.Time
+	// MessageID без угловых скобок; если пусто — генерируется.
+	MessageID string
+	// Headers — дополнительные заголовки, проверенные вызывающим.
+	Headers map[string]string
+
+	Text        string
+	HTML        string
+	Attachments []Attachment
+}
+
+// Builder собирает письма. Random используется для Message-ID и границ
+// multipart; по умолчанию crypto/rand, в тестах — детерминированный поток.
+// Builder, общий для нескольких горутин, требует потокобезопасного Random.
+type Builder struct {
+	Random io.Reader
+}
+
+type entity struct {
+	contentType string
+	encoding    string
+	disposition string
+	body        []byte
+}
+
+// Build возвращает письмо с CRLF-переводами строк, готовое к DATA.
+func (b Builder) Build(m *Message) ([]byte, error) {
+	if m.Text == "" && m.HTML == "" {
+		return nil, ErrNoBody
+	}
+
+	msgID := m.MessageID
+	if msgID == "" {
+		id, err := b.randomHex(16)
+		if err != nil {
+			return nil, err
+		}
+		msgID = id + "@" + domainOf(m.From.Address)
+	}
+
+	root, err := b.rootEntity(m)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	var herr error
+	header := func(name, value string) {
+		if err := writeHeader(&buf, name, value); err != nil && herr == nil {
+			herr = fmt.Errorf("%w: %s", err, name)
+		}
+	}
+	header("From", m.From.String())
+	if m.ReplyTo != "" {
+		header("Reply-To", m.ReplyTo)
+	}
+	header("To", strings.Join(m.To, ", "))
+	if m.Subject != "" {
+		header("Subject", encodeHeader(m.Subject))
+	}
+	header("Date", m.Date.Format(time.RFC1123Z))
+	header("Message-ID", "<"+msgID+">")
+
+	names := make([]string, 0, len(m.Headers))
+	for name := range m.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		header(name, encodeHeader(m.Headers[name]))
+	}
+
+	header("MIME-Version", "1.0")
+	header("Content-Type", root.contentType)
+	if root.encoding != "" {
+		header("Content-Transfer-Encoding", root.encoding)
+	}
+	if herr != nil {
+		return nil, herr
+	}
+	buf.WriteString("\r\n")
+	buf.Write(root.body)
+	return buf.Bytes(), nil
+}
+
+func (b Builder) rootEntity(m *Message) (entity, error) {
+	var body entity
+	switch {
+	case m.Text != "" && m.HTML != "":
+		var err error
+		body, err = b.multipart("alternative", []entity{
+			textEntity("text/plain", m.Text),
+			textEntity("text/html", m.HTML),
+		})
+		if err != nil {
+			return entity{}, err
+		}
+	case m.HTML != "":
+		body = textEntity("text/html", m.HTML)
+	default:
+		body = textEntity("text/plain", m.Text)
+	}
+
+	if len(m.Attachments) == 0 {
+		return body, nil
+	}
+	parts := []entity{body}
+	for _, a := range m.Attachments {
+		parts = append(parts, attachmentEntity(a))
+	}
+	return b.multipart("mixed", parts)
+}
+
+func (b Builder) multipart(subtype string, parts []entity) (entity, error) {
+	boundary, err := b.randomHex(15)
+	if err != nil {
+		return entity{}, err
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	if err := mw.SetBoundary(boundary); err != nil {
+		return entity{}, err
+	}
+	for _, p := range parts {
+		h := textproto.MIMEHeader{"Content-Type": {p.contentType}}
+		if p.encoding != "" {
+			h.Set("Content-Transfer-Encoding", p.encoding)
+		}
+		if p.disposition != "" {
+			h.Set("Content-Disposition", p.disposition)
+		}
+		w, err := mw.CreatePart(h)
+		if err != nil {
+			return entity{}, err
+		}
+		if _, err := w.Write(p.body); err != nil {
+			return entity{}, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return entity{}, err
+	}
+
+	return entity{
+		contentType: mime.FormatMediaType("multipart/"+subtype, map[string]string{"boundary": boundary}),
+		body:        body.Bytes(),
+	}, nil
+}
+
+// textEntity выбирает 7bit для коротких ASCII-строк, иначе quoted-printable.
+func textEntity(mediaType, s string) entity {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	if !strings.HasSuffix(s, "\n") {
+		s += "\n"
+	}
+	e := entity{contentType: mediaType + "; charset=utf-8"}
+
+	if is7bit(s) {
+		e.encoding = "7bit"
+		e.body = []byte(strings.ReplaceAll(s, "\n", "\r\n"))
+		return e
+	}
+
+	var buf bytes.Buffer
+	qp := quotedprintable.NewWriter(&buf)
+	_, _ = qp.Write([]byte(s))
+	_ = qp.Close()
+	e.encoding = "quoted-printable"
+	e.body = buf.Bytes()
+	return e
+}
+
+func attachmentEntity(a Attachment) entity {
+	ct := a.ContentType
+	if ct == "" {
+		ct = "application/octet-stream"
+	}
+	params := map[string]string{"name": a.Filename}
+	if mediaType, ctParams, err := mime.ParseMediaType(ct); err == nil {
+		for k, v := range ctParams {
+			params[k] = v
+		}
+		ct = mime.FormatMediaType(mediaType, params)
+	}
+
+	enc := base64.StdEncoding.EncodeToString(a.Data)
+	var body strings.Builder
+	for len(enc) > 76 {
+		body.WriteString(enc[:76] + "\r\n")
+		enc = enc[76:]
+	}
+	if enc != "" {
+		body.WriteString(enc + "\r\n")
+	}
+
+	return entity{
+		contentType: ct,
+		encoding:    "base64",
+		disposition: mime.FormatMediaType("attachment", map[string]string{"filename": a.Filename}),
+		body:        []byte(body.String()),
+	}
+}
+
+func is7bit(s string) bool {
+	for _, line := range strings.Split(s, "\n") {
+		if len(line) > maxLineLen {
+			return false
+		}
+		for i := 0; i < len(line); i++ {
+			if c := line[i]; c >= 0x80 || c == 0 || c == '\r' {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func encodeHeader(v string) string {
+	return mime.QEncoding.Encode("utf-8", v)
+}
+
+// writeHeader пишет заголовок, перенося длинные значения по пробелам.
+// Переносится только перед непустым словом: лишние и хвостовые пробелы
+// остаются в конце строки, иначе вышла бы строка из одних пробелов, которую
+// RFC 5322 §3.2.2 запрещает. Слово, с которым строка не укладывается
+// в maxLineLen даже после переноса, — ErrHeaderTooLong: такое письмо
+// сервер вправе отвергнуть или обрезать.
+func writeHeader(buf *bytes.Buffer, name, value string) error {
+	line := name + ":"
+	lineLen := len(line)
+	buf.WriteString(line)
+	for i, word := range strings.Split(value, " ") {
+		if i > 0 && word != "" && lineLen+1+len(word) > foldWidth {
+			buf.WriteString("\r\n")
+			lineLen = 0
+		}
+		if lineLen+1+len(word) > maxLineLen {
+			return ErrHeaderTooLong
+		}
+		buf.WriteString(" " + word)
+		lineLen += 1 + len(word)
+	}
+	buf.WriteString("\r\n")
+	return nil
+}
+
+func (b Builder) randomHex(n int) (string, error) {
+	r := b.Random
+	if r == nil {
+		r = rand.Reader
+	}
+	p := make([]byte, n)
+	if _, err := io.ReadFull(r, p); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(p), nil
+}
+
+func domainOf(addr string) string {
+	if i := strings.LastIndexByte(addr, '@'); i >= 0 && i < len(addr)-1 {
+		return addr[i+1:]
+	}
+	return "localhost"
+}
@@ -0,0 +1,179 @@
+package message
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"mime"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files")
+
+// seqReader отдаёт 0,1,2,… — границы и Message-ID стабильны между прогонами.
+type seqReader struct{ n byte }
+
+func (r *seqReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = r.n
+		r.n++
+	}
+	return len(p), nil
+}
+
+func testBuilder() Builder { return Builder{Random: &seqReader{}} }
+
+func baseMessage() *Message {
+	return &Message{
+		From:    mail.Address{Name: "News", Address: "news@example.com"},
+		To:      []string{"u1@example.com"},
+		Subject: "Weekly digest",
+		Date:    time.Date(2025, 10, 2, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestBuild_Golden(t *testing.T) {
+	cases := map[string]func(m *Message){
+		"plain": func(m *Message) {
+			m.Text = "Hello\nWorld"
+		},
+		"alternative": func(m *Message) {
+			m.Text = "Hello, Ann"
+			m.HTML = "<p>Hello, <b>Ann</b></p>"
+			m.ReplyTo = "help@example.com"
+			m.Headers = map[string]string{"X-Campaign": "42"}
+		},
+		"attachments": func(m *Message) {
+			m.HTML = "<p>See attached</p>"
+			m.Attachments = []Attachment{
+				{Filename: "report.csv", ContentType: "text/csv", Data: []byte("a,b\n1,2\n")},
+				{Filename: "отчёт.bin", Data: bytes.Repeat([]byte{0xff, 0x00, 0x7f}, 40)},
+			}
+		},
+		"nonascii": func(m *Message) {
+			m.From.Name = "Команда рассылок"
+			m.Subject = "Привет! Это очень длинная тема письма, которую придётся перенести на несколько строк"
+			m.Text = "Привет, Анна!\n" + strings.Repeat("x", 1200)
+		},
+	}
+
+	for name, mutate := range cases {
+		t.Run(name, func(t *testing.T) {
+			m := baseMessage()
+			mutate(m)
+			got, err := testBuilder().Build(m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkLineLimits(t, got)
+
+			path := filepath.Join("testdata", name+".golden")
+			if *update {
+				if err := os.WriteFile(path, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Fatalf("output differs from %s (run with -update):\n%s", path, got)
+			}
+		})
+	}
+}
+
+func TestBuild_ParsesBack(t *testing.T) {
+	m := baseMessage()
+	m.From.Name = "Команда"
+	m.Subject = "Привет"
+	m.Text = "text"
+	m.HTML = "<p>html</p>"
+
+	raw, err := testBuilder().Build(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if err != nil || subject != "Привет" {
+		t.Fatalf("subject: %q %v", subject, err)
+	}
+	from, err := parsed.Header.AddressList("From")
+	if err != nil || from[0].Name != "Команда" {
+		t.Fatalf("from: %v %v", from, err)
+	}
+	if id := parsed.Header.Get("Message-ID"); !strings.HasSuffix(id, "@example.com>") {
+		t.Fatalf("message-id: %q", id)
+	}
+}
+
+func TestBuild_NoBody(t *testing.T) {
+	if _, err := testBuilder().Build(baseMessage()); err != ErrNoBody {
+		t.Fatalf("want ErrNoBody, got %v", err)
+	}
+}
+
+func checkLineLimits(t *testing.T, raw []byte) {
+	t.Helper()
+	for i, line := range strings.Split(string(raw), "\r\n") {
+		if len(line) > maxLineLen {
+			t.Fatalf("line %d is %d bytes long", i+1, len(line))
+		}
+		if strings.Contains(line, "\n") {
+			t.Fatalf("line %d contains a bare LF", i+1)
+		}
+	}
+}
+
+func TestBuild_LongHeaders(t *testing.T) {
+	m := baseMessage()
+	m.Text = "text"
+	m.Subject = strings.Repeat("слово ", 300)
+	m.Headers = map[string]string{"X-Tag": strings.Repeat("tag ", 400)}
+
+	raw, err := testBuilder().Build(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkLineLimits(t, raw)
+
+	m.Headers = map[string]string{"X-Tag": strings.Repeat("a", maxLineLen)}
+	if _, err := testBuilder().Build(m); !errors.Is(err, ErrHeaderTooLong) {
+		t.Fatalf("want ErrHeaderTooLong, got %v", err)
+	}
+}
+
+func TestWriteHeader_NoWhitespaceOnlyLines(t *testing.T) {
+	cases := map[string]string{
+		// «X-Tag: » и 71 символ занимают 78: следующий пробел попадает на границу переноса
+		"trailing space": strings.Repeat("x", 71) + " ",
+		"double space":   strings.Repeat("x", 71) + "  " + strings.Repeat("y", 77),
+		"space run":      "a" + strings.Repeat(" ", 200) + "b",
+	}
+	for name, value := range cases {
+		var buf bytes.Buffer
+		if err := writeHeader(&buf, "X-Tag", value); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		raw := buf.String()
+		for i, line := range strings.Split(strings.TrimSuffix(raw, "\r\n"), "\r\n") {
+			if strings.TrimSpace(line) == "" {
+				t.Fatalf("%s: line %d is whitespace only: %q", name, i+1, raw)
+			}
+		}
+		// разворачивание переноса (удаление CRLF) возвращает исходное значение
+		if got := strings.ReplaceAll(raw, "\r\n", ""); got != "X-Tag: "+value {
+			t.Fatalf("%s: unfolds to %q", name, got)
+		}
+	}
+}
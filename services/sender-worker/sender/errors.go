@@ -0,0 +1,87 @@
+package sender
+
+import (
+	"errors"
+	"fmt"
+	"net/textproto"
+	"regexp"
+	"strings"
+)
+
+// ErrorClass определяет, что воркер делает с неудачной отправкой.
+type ErrorClass string
+
+const (
+	// ClassTemporary — временная ошибка, повторяем с обычным backoff.
+	ClassTemporary ErrorClass = "temporary"
+	// ClassPermanent — адрес не существует и т. п., повтор бесполезен.
+	ClassPermanent ErrorClass = "permanent"
+	// ClassRateLimited — сервер просит снизить темп, повторяем с долгим backoff.
+	ClassRateLimited ErrorClass = "rate_limited"
+	// ClassPolicyBlocked — письмо отклонено политикой (спам, DMARC), не повторяем.
+	ClassPolicyBlocked ErrorClass = "policy_blocked"
+)
+
+// Retryable сообщает, имеет ли смысл повторять отправку.
+func (c ErrorClass) Retryable() bool {
+	return c == ClassTemporary || c == ClassRateLimited
+}
+
+// DeliveryError — ошибка доставки с классом, который транспорт определил по ответу сервера.
+type DeliveryError struct {
+	Class ErrorClass
+	// Code и EnhancedCode — ответ сервера, если он был (550, "5.1.1").
+	Code         int
+	EnhancedCode string
+	Err          error
+}
+
+func (e *DeliveryError) Error() string { return e.Err.Error() }
+
+func (e *DeliveryError) Unwrap() error { return e.Err }
+
+// Classify возвращает класс ошибки; всё, что транспорт не классифицировал
+// (сеть, таймауты), считается временным.
+func Classify(err error) ErrorClass {
+	var de *DeliveryError
+	if errors.As(err, &de) {
+		return de.Class
+	}
+	return ClassTemporary
+}
+
+var enhancedCodeRe = regexp.MustCompile(`^([245])\.(\d{1,3})\.(\d{1,3})\b`)
+
+// classifySMTP сопоставляет ответ SMTP-сервера классу ошибки по RFC 3463.
+func classifySMTP(stage string, terr *textproto.Error) *DeliveryError {
+	de := &DeliveryError{
+		Class: ClassTemporary,
+		Code:  terr.Code,
+		Err:   fmt.Errorf("smtp %s: %w", stage, terr),
+	}
+	var subject, detail string
+	if m := enhancedCodeRe.FindStringSubmatch(terr.Msg); m != nil {
+		de.EnhancedCode = m[0]
+		subject, detail = m[2], m[3]
+	}
+	msg := strings.ToLower(terr.Msg)
+
+	switch {
+	case terr.Code >= 500:
+		switch {
+		case subject == "7":
+			de.Class = ClassPolicyBlocked
+		case subject == "2" && detail == "2":
+			// 5.2.2 — ящик переполнен, со временем может освободиться
+			de.Class = ClassTemporary
+		default:
+			de.Class = ClassPermanent
+		}
+	case terr.Code >= 400:
+		if subject == "7" || (terr.Code == 421 && de.EnhancedCode == "") ||
+			strings.Contains(msg, "rate") || strings.Contains(msg, "too many") {
+			de.Class = ClassRateLimited
+		}
+	}
+	return de
+}
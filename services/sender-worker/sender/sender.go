@@ -0,0 +1,26 @@
+package sender
+
+import "context"
+
+// Message — готовое к отправке письмо: SMTP-конверт и полный текст (заголовки + тело).
+type Message struct {
+	From string
+	To   []string
+	Data []byte
+}
+
+// Result — диагностика попытки отправки. Заполняется и при ошибке,
+// насколько транспорт успел продвинуться.
+type Result struct {
+	// Transport — тип транспорта ("smtp"), Provider — куда именно отправляли.
+	Transport string
+	Provider  string
+	// Code и Response — последний ответ сервера (250 "2.0.0 Ok: queued as ...").
+	Code     int
+	Response string
+}
+
+// Sender доставляет письмо через конкретный транспорт.
+type Sender interface {
+	Send(ctx context.Context, msg Message) (Result, error)
+}
@@ -0,0 +1,226 @@
+// Package sendertest содержит in-process SMTP-сервер для тестов транспорта и воркера.
+package sendertest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"math/big"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Session — то, что сервер получил за одну транзакцию DATA.
+type Session struct {
+	TLS      bool
+	AuthUser string
+	AuthPass string
+	From     string
+	To       []string
+	Data     string
+}
+
+type Options struct {
+	// TLS включает STARTTLS (или implicit TLS, если Implicit=true).
+	TLS      *tls.Config
+	Implicit bool
+	// RcptReplies — ответы на RCPT TO для конкретных адресов, например "550 5.1.1 user unknown".
+	RcptReplies map[string]string
+}
+
+type Server struct {
+	ln   net.Listener
+	opts Options
+
+	mu       sync.Mutex
+	sessions []Session
+}
+
+func NewServer(t testing.TB, opts Options) *Server {
+	t.Helper()
+	var (
+		ln  net.Listener
+		err error
+	)
+	if opts.Implicit {
+		ln, err = tls.Listen("tcp", "127.0.0.1:0", opts.TLS)
+	} else {
+		ln, err = net.Listen("tcp", "127.0.0.1:0")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{ln: ln, opts: opts}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *Server) Host() string { return "127.0.0.1" }
+
+func (s *Server) Port() int { return s.ln.Addr().(*net.TCPAddr).Port }
+
+func (s *Server) Sessions() []Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Session(nil), s.sessions...)
+}
+
+func (s *Server) Last(t testing.TB) Session {
+	t.Helper()
+	all := s.Sessions()
+	if len(all) == 0 {
+		t.Fatal("no smtp sessions recorded")
+	}
+	return all[len(all)-1]
+}
+
+func (s *Server) serve(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	sess := Session{TLS: s.opts.Implicit}
+	tp := textproto.NewConn(conn)
+	_ = tp.PrintfLine("220 sendertest ESMTP ready")
+
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			lines := []string{"sendertest.local"}
+			if s.opts.TLS != nil && !sess.TLS {
+				lines = append(lines, "STARTTLS")
+			}
+			lines = append(lines, "AUTH PLAIN LOGIN", "8BITMIME")
+			for i, l := range lines {
+				sep := "-"
+				if i == len(lines)-1 {
+					sep = " "
+				}
+				_ = tp.PrintfLine("250%s%s", sep, l)
+			}
+		case "STARTTLS":
+			_ = tp.PrintfLine("220 go ahead")
+			tc := tls.Server(conn, s.opts.TLS)
+			if err := tc.Handshake(); err != nil {
+				return
+			}
+			conn = tc
+			tp = textproto.NewConn(conn)
+			sess.TLS = true
+		case "AUTH":
+			mech, initial, _ := strings.Cut(arg, " ")
+			switch strings.ToUpper(mech) {
+			case "PLAIN":
+				raw, _ := base64.StdEncoding.DecodeString(initial)
+				parts := strings.Split(string(raw), "\x00")
+				if len(parts) == 3 {
+					sess.AuthUser, sess.AuthPass = parts[1], parts[2]
+				}
+			case "LOGIN":
+				_ = tp.PrintfLine("334 %s", base64.StdEncoding.EncodeToString([]byte("Username:")))
+				u, _ := tp.ReadLine()
+				_ = tp.PrintfLine("334 %s", base64.StdEncoding.EncodeToString([]byte("Password:")))
+				p, _ := tp.ReadLine()
+				ub, _ := base64.StdEncoding.DecodeString(u)
+				pb, _ := base64.StdEncoding.DecodeString(p)
+				sess.AuthUser, sess.AuthPass = string(ub), string(pb)
+			}
+			_ = tp.PrintfLine("235 2.7.0 authenticated")
+		case "MAIL":
+			sess.From = between(arg, "<", ">")
+			sess.To = nil
+			_ = tp.PrintfLine("250 2.1.0 ok")
+		case "RCPT":
+			to := between(arg, "<", ">")
+			if reply, ok := s.opts.RcptReplies[to]; ok {
+				_ = tp.PrintfLine("%s", reply)
+				continue
+			}
+			sess.To = append(sess.To, to)
+			_ = tp.PrintfLine("250 2.1.5 ok")
+		case "DATA":
+			_ = tp.PrintfLine("354 go ahead")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			sess.Data = string(data)
+			s.mu.Lock()
+			s.sessions = append(s.sessions, sess)
+			s.mu.Unlock()
+			_ = tp.PrintfLine("250 2.0.0 queued as %d", len(s.Sessions()))
+		case "RSET", "NOOP":
+			_ = tp.PrintfLine("250 ok")
+		case "QUIT":
+			_ = tp.PrintfLine("221 bye")
+			return
+		default:
+			_ = tp.PrintfLine("502 command not implemented")
+		}
+	}
+}
+
+func between(s, l, r string) string {
+	i := strings.Index(s, l)
+	j := strings.Index(s, r)
+	if i < 0 || j < i {
+		return ""
+	}
+	return s[i+1 : j]
+}
+
+// TLSConfigs выпускает самоподписанный сертификат на 127.0.0.1
+// и возвращает конфиги для сервера и доверяющего ему клиента.
+func TLSConfigs(t testing.TB) (server, client *tls.Config) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	server = &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+		MinVersion:   tls.VersionTLS12,
+	}
+	client = &tls.Config{ServerName: "127.0.0.1", RootCAs: pool, MinVersion: tls.VersionTLS12}
+	return server, client
+}
@@ -0,0 +1,273 @@
+package sender
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	TLSModeNone     = "none"
+	TLSModeSTARTTLS = "starttls"
+	TLSModeImplicit = "tls"
+
+	AuthPlain = "plain"
+	AuthLogin = "login"
+)
+
+type SMTPConfig struct {
+	Host      string
+	Port      int
+	Username  string
+	Password  string
+	Auth      string // plain | login | пусто — выбрать по EHLO
+	TLSMode   string // starttls | tls | none
+	HelloName string
+	Timeout   time.Duration
+
+	InsecureSkipVerify bool
+}
+
+type SMTP struct {
+	cfg       SMTPConfig
+	tlsConfig *tls.Config
+}
+
+func NewSMTP(cfg SMTPConfig) (*SMTP, error) {
+	if cfg.Host == "" {
+		return nil, errors.New("smtp: host is required")
+	}
+	if cfg.Port <= 0 {
+		return nil, fmt.Errorf("smtp: invalid port %d", cfg.Port)
+	}
+	switch cfg.TLSMode {
+	case "":
+		cfg.TLSMode = TLSModeSTARTTLS
+	case TLSModeNone, TLSModeSTARTTLS, TLSModeImplicit:
+	default:
+		return nil, fmt.Errorf("smtp: unknown tls mode %q", cfg.TLSMode)
+	}
+	cfg.Auth = strings.ToLower(cfg.Auth)
+	switch cfg.Auth {
+	case "", AuthPlain, AuthLogin:
+	default:
+		return nil, fmt.Errorf("smtp: unknown auth mechanism %q", cfg.Auth)
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+
+	return &SMTP{
+		cfg: cfg,
+		tlsConfig: &tls.Config{
+			ServerName:         cfg.Host,
+			MinVersion:         tls.VersionTLS12,
+			InsecureSkipVerify: cfg.InsecureSkipVerify, //nolint:gosec // управляется конфигом
+		},
+	}, nil
+}
+
+func (s *SMTP) Send(ctx context.Context, msg Message) (Result, error) {
+	res := Result{
+		Transport: "smtp",
+		Provider:  net.JoinHostPort(s.cfg.Host, strconv.Itoa(s.cfg.Port)),
+	}
+	if len(msg.To) == 0 {
+		return res, errors.New("smtp: no recipients")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.Timeout)
+	defer cancel()
+
+	conn, err := s.dial(ctx)
+	if err != nil {
+		return res, fmt.Errorf("smtp dial: %w", err)
+	}
+	if dl, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(dl)
+	}
+	stop := context.AfterFunc(ctx, func() { _ = conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	c, err := smtp.NewClient(conn, s.cfg.Host)
+	if err != nil {
+		_ = conn.Close()
+		return res, s.wrapErr(ctx, &res, "greeting", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	if s.cfg.HelloName != "" {
+		if err := c.Hello(s.cfg.HelloName); err != nil {
+			return res, s.wrapErr(ctx, &res, "hello", err)
+		}
+	}
+
+	if s.cfg.TLSMode == TLSModeSTARTTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return res, errors.New("smtp: server does not support STARTTLS")
+		}
+		if err := c.StartTLS(s.tlsConfig.Clone()); err != nil {
+			return res, s.wrapErr(ctx, &res, "starttls", err)
+		}
+	}
+
+	if s.cfg.Username != "" {
+		auth, err := s.auth(c)
+		if err != nil {
+			return res, err
+		}
+		if err := c.Auth(auth); err != nil {
+			return res, s.wrapErr(ctx, &res, "auth", err)
+		}
+	}
+
+	if err := c.Mail(msg.From); err != nil {
+		return res, s.wrapErr(ctx, &res, "mail from", err)
+	}
+	for _, rcpt := range msg.To {
+		if err := c.Rcpt(rcpt); err != nil {
+			return res, s.wrapErr(ctx, &res, "rcpt to", err)
+		}
+	}
+
+	if err := s.data(c, msg.Data, &res); err != nil {
+		return res, s.wrapErr(ctx, &res, "data", err)
+	}
+
+	// письмо уже принято сервером, ошибка на QUIT на доставку не влияет
+	_ = c.Quit()
+	return res, nil
+}
+
+// data передаёт письмо командой DATA. В отличие от smtp.Client.Data сохраняет
+// итоговый ответ сервера: в нём обычно есть идентификатор письма в очереди.
+func (s *SMTP) data(c *smtp.Client, body []byte, res *Result) error {
+	id, err := c.Text.Cmd("DATA")
+	if err != nil {
+		return err
+	}
+	c.Text.StartResponse(id)
+	_, _, err = c.Text.ReadResponse(354)
+	c.Text.EndResponse(id)
+	if err != nil {
+		return err
+	}
+
+	w := c.Text.DotWriter()
+	if _, err := w.Write(body); err != nil {
+		_ = w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	code, msg, err := c.Text.ReadResponse(250)
+	if err != nil {
+		return err
+	}
+	res.Code, res.Response = code, msg
+	return nil
+}
+
+func (s *SMTP) dial(ctx context.Context) (net.Conn, error) {
+	addr := net.JoinHostPort(s.cfg.Host, strconv.Itoa(s.cfg.Port))
+	d := &net.Dialer{}
+	if s.cfg.TLSMode == TLSModeImplicit {
+		td := &tls.Dialer{NetDialer: d, Config: s.tlsConfig.Clone()}
+		return td.DialContext(ctx, "tcp", addr)
+	}
+	return d.DialContext(ctx, "tcp", addr)
+}
+
+func (s *SMTP) auth(c *smtp.Client) (smtp.Auth, error) {
+	ok, mechs := c.Extension("AUTH")
+	if !ok {
+		return nil, errors.New("smtp: server does not support AUTH")
+	}
+	supported := strings.Fields(strings.ToLower(mechs))
+	has := func(m string) bool {
+		for _, v := range supported {
+			if v == m {
+				return true
+			}
+		}
+		return false
+	}
+
+	mech := s.cfg.Auth
+	if mech == "" {
+		switch {
+		case has(AuthPlain):
+			mech = AuthPlain
+		case has(AuthLogin):
+			mech = AuthLogin
+		default:
+			return nil, fmt.Errorf("smtp: no supported auth mechanism in %q", mechs)
+		}
+	}
+
+	switch mech {
+	case AuthLogin:
+		return &loginAuth{username: s.cfg.Username, password: s.cfg.Password, host: s.cfg.Host}, nil
+	default:
+		return smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host), nil
+	}
+}
+
+// wrapErr добавляет к ошибке этап диалога и сохраняет ответ сервера в res. Ответы сервера на MAIL, RCPT
+// и DATA относятся к конкретному письму и классифицируются; сбои
+// соединения, TLS и AUTH остаются временными.
+func (s *SMTP) wrapErr(ctx context.Context, res *Result, stage string, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return fmt.Errorf("smtp %s: %w", stage, ctxErr)
+	}
+	var terr *textproto.Error
+	if errors.As(err, &terr) {
+		res.Code, res.Response = terr.Code, terr.Msg
+		switch stage {
+		case "mail from", "rcpt to", "data":
+			return classifySMTP(stage, terr)
+		}
+	}
+	return fmt.Errorf("smtp %s: %w", stage, err)
+}
+
+// loginAuth реализует AUTH LOGIN, которого нет в net/smtp.
+type loginAuth struct {
+	username, password, host string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, errors.New("unencrypted connection")
+	}
+	if server.Name != a.host {
+		return "", nil, errors.New("wrong host name")
+	}
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	switch strings.ToLower(strings.TrimSpace(string(fromServer))) {
+	case "username:":
+		return []byte(a.username), nil
+	case "password:":
+		return []byte(a.password), nil
+	default:
+		return nil, fmt.Errorf("unexpected server challenge %q", fromServer)
+	}
+}
+
+func isLocalhost(name string) bool {
+	return name == "localhost" || name == "127.0.0.1" || name == "::1"
+}
@@ -0,0 +1,176 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Mutter0815/MassMailer/services/sender-worker/sender/sendertest"
+)
+
+func testMessage() Message {
+	return Message{
+		From: "news@example.com",
+		To:   []string{"u1@example.com"},
+		Data: []byte("Subject: Hi\r\n\r\nHello\r\n.leading dot\r\n"),
+	}
+}
+
+func TestSMTP_PlainAuthNoTLS(t *testing.T) {
+	srv := sendertest.NewServer(t, sendertest.Options{})
+	s, err := NewSMTP(SMTPConfig{
+		Host: "127.0.0.1", Port: srv.Port(),
+		Username: "user", Password: "secret",
+		TLSMode: TLSModeNone,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := s.Send(context.Background(), testMessage())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Transport != "smtp" || res.Provider != net.JoinHostPort("127.0.0.1", strconv.Itoa(srv.Port())) || res.Code != 250 ||
+		!strings.HasPrefix(res.Response, "2.0.0 queued as") {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+
+	got := srv.Last(t)
+	if got.AuthUser != "user" || got.AuthPass != "secret" {
+		t.Fatalf("auth: got %q/%q", got.AuthUser, got.AuthPass)
+	}
+	if got.From != "news@example.com" {
+		t.Fatalf("mail from: got %q", got.From)
+	}
+	if len(got.To) != 1 || got.To[0] != "u1@example.com" {
+		t.Fatalf("rcpt to: got %v", got.To)
+	}
+	if want := "Subject: Hi\n\nHello\n.leading dot\n"; got.Data != want {
+		t.Fatalf("data: got %q", got.Data)
+	}
+}
+
+func TestSMTP_StartTLSLoginAuth(t *testing.T) {
+	serverTLS, clientTLS := sendertest.TLSConfigs(t)
+	srv := sendertest.NewServer(t, sendertest.Options{TLS: serverTLS})
+	s, err := NewSMTP(SMTPConfig{
+		Host: "127.0.0.1", Port: srv.Port(),
+		Username: "user", Password: "secret", Auth: AuthLogin,
+		TLSMode: TLSModeSTARTTLS,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.tlsConfig = clientTLS
+
+	if _, err := s.Send(context.Background(), testMessage()); err != nil {
+		t.Fatal(err)
+	}
+
+	got := srv.Last(t)
+	if !got.TLS {
+		t.Fatal("expected STARTTLS to be negotiated")
+	}
+	if got.AuthUser != "user" || got.AuthPass != "secret" {
+		t.Fatalf("auth: got %q/%q", got.AuthUser, got.AuthPass)
+	}
+}
+
+func TestSMTP_ImplicitTLS(t *testing.T) {
+	serverTLS, clientTLS := sendertest.TLSConfigs(t)
+	srv := sendertest.NewServer(t, sendertest.Options{TLS: serverTLS, Implicit: true})
+	s, err := NewSMTP(SMTPConfig{Host: "127.0.0.1", Port: srv.Port(), TLSMode: TLSModeImplicit})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.tlsConfig = clientTLS
+
+	if _, err := s.Send(context.Background(), testMessage()); err != nil {
+		t.Fatal(err)
+	}
+	if got := srv.Last(t); !got.TLS || got.From != "news@example.com" {
+		t.Fatalf("unexpected session: %+v", got)
+	}
+}
+
+func TestSMTP_StartTLSRequired(t *testing.T) {
+	srv := sendertest.NewServer(t, sendertest.Options{})
+	s, err := NewSMTP(SMTPConfig{Host: "127.0.0.1", Port: srv.Port(), TLSMode: TLSModeSTARTTLS})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Send(context.Background(), testMessage()); err == nil {
+		t.Fatal("expected error when server lacks STARTTLS")
+	}
+}
+
+func TestSMTP_RcptRejected(t *testing.T) {
+	srv := sendertest.NewServer(t, sendertest.Options{
+		RcptReplies: map[string]string{"u1@example.com": "550 5.1.1 user unknown"},
+	})
+	s, err := NewSMTP(SMTPConfig{Host: "127.0.0.1", Port: srv.Port(), TLSMode: TLSModeNone})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := s.Send(context.Background(), testMessage())
+	if res.Code != 550 || res.Response != "5.1.1 user unknown" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	var tpErr *textproto.Error
+	if !errors.As(err, &tpErr) || tpErr.Code != 550 {
+		t.Fatalf("expected 550 error, got %v", err)
+	}
+	var de *DeliveryError
+	if !errors.As(err, &de) || de.Class != ClassPermanent || de.EnhancedCode != "5.1.1" {
+		t.Fatalf("expected permanent 5.1.1 delivery error, got %#v", de)
+	}
+}
+
+func TestClassifySMTP(t *testing.T) {
+	cases := []struct {
+		code int
+		msg  string
+		want ErrorClass
+	}{
+		{550, "5.1.1 user unknown", ClassPermanent},
+		{550, "no such user", ClassPermanent},
+		{552, "5.2.2 mailbox full", ClassTemporary},
+		{554, "5.7.1 message rejected as spam", ClassPolicyBlocked},
+		{550, "5.7.26 unauthenticated email is not accepted", ClassPolicyBlocked},
+		{451, "4.3.0 temporary failure", ClassTemporary},
+		{450, "4.7.1 greylisted, try later", ClassRateLimited},
+		{421, "4.7.0 too many connections", ClassRateLimited},
+		{452, "too many recipients", ClassRateLimited},
+		{421, "service not available", ClassRateLimited},
+		{421, "4.4.2 connection dropped", ClassTemporary},
+	}
+	for _, c := range cases {
+		got := classifySMTP("rcpt to", &textproto.Error{Code: c.code, Msg: c.msg}).Class
+		if got != c.want {
+			t.Errorf("%d %s: got %s, want %s", c.code, c.msg, got, c.want)
+		}
+	}
+
+	if Classify(errors.New("dial tcp: connection refused")) != ClassTemporary {
+		t.Error("unclassified errors must be temporary")
+	}
+}
+
+func TestNewSMTP_Validation(t *testing.T) {
+	cases := []SMTPConfig{
+		{Port: 25},
+		{Host: "h"},
+		{Host: "h", Port: 25, TLSMode: "ssl"},
+		{Host: "h", Port: 25, Auth: "cram-md5"},
+	}
+	for i, cfg := range cases {
+		if _, err := NewSMTP(cfg); err == nil {
+			t.Fatalf("case %d: expected error", i)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Mutter0815/MassMailer/internal/campaign"
+	"github.com/Mutter0815/MassMailer/internal/store"
+	"github.com/Mutter0815/MassMailer/pkg/logx"
+)
+
+type finalizerStore interface {
+	ListDrainedCampaigns(ctx context.Context, limit int) ([]store.DrainedCampaign, error)
+	SetCampaignStatus(ctx context.Context, q store.Querier, id int64, to string) error
+}
+
+// Finalizer закрывает кампании, у которых не осталось сообщений в работе:
+// processing → done или failed в зависимости от доли неудачных отправок.
+type Finalizer struct {
+	Store    finalizerStore
+	DB       store.Querier
+	Interval time.Duration
+	// FailureThreshold — доля failed-сообщений, выше которой кампания считается failed.
+	FailureThreshold float64
+	BatchSize        int
+}
+
+func NewFinalizer(st *store.Store, interval time.Duration, threshold float64) *Finalizer {
+	return &Finalizer{Store: st, DB: st.DB, Interval: interval, FailureThreshold: threshold, BatchSize: 100}
+}
+
+func (f *Finalizer) Run(ctx context.Context) {
+	t := time.NewTicker(f.Interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if _, err := f.Tick(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				logx.L().Errorw("finalizer_tick_error", "error", err)
+			}
+		}
+	}
+}
+
+// Tick возвращает число кампаний, переведённых в итоговый статус.
+func (f *Finalizer) Tick(ctx context.Context) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	drained, err := f.Store.ListDrainedCampaigns(ctx, f.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, c := range drained {
+		status := campaign.Outcome(c.Total, c.Failed, f.FailureThreshold)
+		err := f.Store.SetCampaignStatus(ctx, f.DB, c.ID, status)
+		if errors.Is(err, campaign.ErrInvalidTransition) {
+			// кампанию уже закрыл другой воркер или её отменили
+			continue
+		}
+		if err != nil {
+			return n, err
+		}
+		n++
+		logx.L().Infow("campaign_finished", "campaign_id", c.ID, "status", status, "total", c.Total, "failed", c.Failed)
+	}
+	return n, nil
+}
@@ -0,0 +1,56 @@
+package worker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Mutter0815/MassMailer/internal/campaign"
+	"github.com/Mutter0815/MassMailer/internal/store"
+)
+
+type fakeFinalizerStore struct {
+	drained  []store.DrainedCampaign
+	statuses map[int64]string
+}
+
+func (f *fakeFinalizerStore) ListDrainedCampaigns(ctx context.Context, limit int) ([]store.DrainedCampaign, error) {
+	return f.drained, nil
+}
+
+func (f *fakeFinalizerStore) SetCampaignStatus(ctx context.Context, q store.Querier, id int64, to string) error {
+	if !campaign.CanTransition(f.statuses[id], to) {
+		return campaign.ErrInvalidTransition
+	}
+	f.statuses[id] = to
+	return nil
+}
+
+func TestFinalizer_Tick(t *testing.T) {
+	fs := &fakeFinalizerStore{
+		drained: []store.DrainedCampaign{
+			{ID: 1, Total: 10, Failed: 1},
+			{ID: 2, Total: 10, Failed: 8},
+			{ID: 3, Total: 4, Failed: 0},
+		},
+		statuses: map[int64]string{
+			1: campaign.StatusProcessing,
+			2: campaign.StatusProcessing,
+			3: campaign.StatusCanceled,
+		},
+	}
+	f := &Finalizer{Store: fs, FailureThreshold: 0.5, BatchSize: 10}
+
+	n, err := f.Tick(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("want 2 finalized campaigns, got %d", n)
+	}
+	want := map[int64]string{1: campaign.StatusDone, 2: campaign.StatusFailed, 3: campaign.StatusCanceled}
+	for id, st := range want {
+		if fs.statuses[id] != st {
+			t.Errorf("campaign %d: want %s, got %s", id, st, fs.statuses[id])
+		}
+	}
+}
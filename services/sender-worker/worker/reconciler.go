@@ -0,0 +1,66 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Mutter0815/MassMailer/internal/store"
+	"github.com/Mutter0815/MassMailer/pkg/logx"
+	"github.com/Mutter0815/MassMailer/pkg/metrics"
+)
+
+type reconcilerStore interface {
+	RequeueStuckMessages(ctx context.Context, stuckAfter time.Duration, limit int) (store.Requeued, error)
+}
+
+// Reconciler возвращает в очередь сообщения, задание которых потерялось:
+// публикация не дошла до брокера, задание пропало в нём или воркер упал,
+// не вернув сообщение. Без этого кампания навсегда осталась бы в processing.
+type Reconciler struct {
+	Store    reconcilerStore
+	Interval time.Duration
+	// StuckAfter — сколько сообщение может ждать в очереди, прежде чем его
+	// задание считается потерянным; должно перекрывать обычную очередь.
+	StuckAfter time.Duration
+	BatchSize  int
+}
+
+func NewReconciler(st *store.Store, interval, stuckAfter time.Duration) *Reconciler {
+	return &Reconciler{Store: st, Interval: interval, StuckAfter: stuckAfter, BatchSize: 500}
+}
+
+func (r *Reconciler) Run(ctx context.Context) {
+	t := time.NewTicker(r.Interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if _, err := r.Tick(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				logx.L().Errorw("reconciler_tick_error", "error", err)
+			}
+		}
+	}
+}
+
+// Tick возвращает число сообщений, заново поставленных в очередь.
+func (r *Reconciler) Tick(ctx context.Context) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	res, err := r.Store.RequeueStuckMessages(ctx, r.StuckAfter, r.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	metrics.WorkerMessagesReconciled.WithLabelValues("pending").Add(float64(res.Pending))
+	metrics.WorkerMessagesReconciled.WithLabelValues("sending").Add(float64(res.Sending))
+
+	n := res.Pending + res.Sending
+	if n > 0 {
+		logx.L().Warnw("stuck_messages_requeued", "pending", res.Pending, "sending", res.Sending)
+	}
+	return n, nil
+}
@@ -0,0 +1,48 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/Mutter0815/MassMailer/internal/store"
+	"github.com/Mutter0815/MassMailer/pkg/metrics"
+)
+
+type fakeReconcilerStore struct {
+	res        store.Requeued
+	stuckAfter time.Duration
+	limit      int
+}
+
+func (f *fakeReconcilerStore) RequeueStuckMessages(ctx context.Context, stuckAfter time.Duration, limit int) (store.Requeued, error) {
+	f.stuckAfter, f.limit = stuckAfter, limit
+	return f.res, nil
+}
+
+func TestReconciler_Tick(t *testing.T) {
+	fs := &fakeReconcilerStore{res: store.Requeued{Pending: 3, Sending: 1}}
+	r := &Reconciler{Store: fs, StuckAfter: 15 * time.Minute, BatchSize: 100}
+
+	pendingBefore := testutil.ToFloat64(metrics.WorkerMessagesReconciled.WithLabelValues("pending"))
+	sendingBefore := testutil.ToFloat64(metrics.WorkerMessagesReconciled.WithLabelValues("sending"))
+
+	n, err := r.Tick(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 4 {
+		t.Fatalf("want 4 requeued messages, got %d", n)
+	}
+	if fs.stuckAfter != 15*time.Minute || fs.limit != 100 {
+		t.Fatalf("unexpected store call: stuckAfter=%s limit=%d", fs.stuckAfter, fs.limit)
+	}
+	if d := testutil.ToFloat64(metrics.WorkerMessagesReconciled.WithLabelValues("pending")) - pendingBefore; d != 3 {
+		t.Fatalf("pending counter grew by %v, want 3", d)
+	}
+	if d := testutil.ToFloat64(metrics.WorkerMessagesReconciled.WithLabelValues("sending")) - sendingBefore; d != 1 {
+		t.Fatalf("sending counter grew by %v, want 1", d)
+	}
+}
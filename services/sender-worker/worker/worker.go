@@ -5,41 +5,77 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
-	"math"
-	"math/rand"
+	"fmt"
+	"html"
+	"math/rand/v2"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
 	"time"
 
 	amqp "github.com/rabbitmq/amqp091-go"
 
 	"github.com/Mutter0815/MassMailer/internal/campaign"
+	"github.com/Mutter0815/MassMailer/internal/render"
 	"github.com/Mutter0815/MassMailer/internal/store"
+	"github.com/Mutter0815/MassMailer/internal/token"
 	"github.com/Mutter0815/MassMailer/pkg/logx"
 	"github.com/Mutter0815/MassMailer/pkg/metrics"
 	"github.com/Mutter0815/MassMailer/pkg/rmq"
+	"github.com/Mutter0815/MassMailer/services/sender-worker/message"
+	"github.com/Mutter0815/MassMailer/services/sender-worker/sender"
 )
 
-var errTemp = errors.New("temporary send error")
-
-func init() {
-	rand.Seed(time.Now().UnixNano())
+type Options struct {
+	// From — адрес отправителя (MAIL FROM и заголовок From), если кампания
+	// не задала свой from_address.
+	From string
+	// Concurrency — число заданий, обрабатываемых одновременно.
+	Concurrency int
+	// DrainTimeout ограничивает дообработку взятых заданий при остановке;
+	// ноль — ждать без ограничения.
+	DrainTimeout time.Duration
+	// Retry — политика повторов для кампаний, у которых её нет.
+	Retry campaign.RetryPolicy
+	// WorkerID записывается владельцем взятого в отправку сообщения.
+	WorkerID string
+	// ClaimLease — на сколько воркер забирает сообщение; должно с запасом
+	// перекрывать таймаут отправки.
+	ClaimLease time.Duration
+	// Links подписывает ссылки отписки, пиксели открытия и Message-ID
+	// писем, PublicURL — внешний адрес campaign-api, к которому ведут
+	// ссылки. Без любого из них письма уходят без List-Unsubscribe, без
+	// переменной unsubscribe_url и без пикселя даже при track_opens.
+	Links     *token.Signer
+	PublicURL string
+	// BounceAddress — базовый адрес возврата: при заданном Links MAIL FROM
+	// каждого письма становится VERP-адресом вида local+токен@domain, по
+	// которому campaign-api узнаёт письмо в уведомлении о недоставке.
+	BounceAddress string
 }
 
-// имитация отправки (здесь будет SMTP/API)
-func simulateSend(address, body string) error {
-	if rand.Float64() < 0.85 {
-		return nil
-	}
-	return errTemp
+const defaultClaimLease = 2 * time.Minute
+
+type publisherAPI interface {
+	PublishDelayed(ctx context.Context, body []byte, headers amqp.Table, delay time.Duration) error
+	PublishDead(ctx context.Context, body []byte, headers amqp.Table, reason string) error
 }
 
 type Worker struct {
-	Store *store.Store
-	Cons  *rmq.Consumer
-	Pub   *rmq.Publisher
+	Store  *store.Store
+	Cons   *rmq.Consumer
+	Pub    publisherAPI
+	Sender sender.Sender
+	Opts   Options
+
+	now     func() time.Time
+	rand    func() float64
+	builder message.Builder
 }
 
-func New(st *store.Store, cons *rmq.Consumer, pub *rmq.Publisher) *Worker {
-	return &Worker{Store: st, Cons: cons, Pub: pub}
+func New(st *store.Store, cons *rmq.Consumer, pub *rmq.Publisher, snd sender.Sender, opts Options) *Worker {
+	return &Worker{Store: st, Cons: cons, Pub: pub, Sender: snd, Opts: opts, now: time.Now, rand: rand.Float64}
 }
 
 func (w *Worker) Run(ctx context.Context, db *sql.DB) error {
@@ -47,121 +83,487 @@ func (w *Worker) Run(ctx context.Context, db *sql.DB) error {
 	if err != nil {
 		return err
 	}
-	logx.L().Infow("worker_started", "queue", w.Cons.Queue)
+	logx.L().Infow("worker_started", "queue", w.Cons.Queue, "concurrency", w.concurrency(), "worker_id", w.Opts.WorkerID)
+	return w.process(ctx, db, msgs)
+}
 
-	for {
-		select {
-		case <-ctx.Done():
-			logx.L().Infow("worker_stopping")
-			return ctx.Err()
-
-		case d, ok := <-msgs:
-			if !ok {
-				logx.L().Warnw("consumer_channel_closed")
-				return nil
+// process раздаёт доставки пулу обработчиков. Отмена ctx только прекращает
+// приём новых доставок: взятые задания доделываются на отдельном контексте,
+// который отменяется по истечении DrainTimeout.
+func (w *Worker) process(ctx context.Context, db *sql.DB, msgs <-chan amqp.Delivery) error {
+	jobCtx, cancelJobs := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelJobs()
+
+	var wg sync.WaitGroup
+	for i := 0; i < w.concurrency(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case d, ok := <-msgs:
+					if !ok {
+						return
+					}
+					metrics.WorkerJobsInFlight.Inc()
+					w.handle(jobCtx, db, d)
+					metrics.WorkerJobsInFlight.Dec()
+				}
 			}
+		}()
+	}
 
-			start := time.Now()
-			metrics.WorkerJobsConsumed.Inc()
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
 
-			var job campaign.JobMessage
-			if err := json.Unmarshal(d.Body, &job); err != nil {
-				logx.L().Warnw("job_unmarshal_error", "error", err)
-				_ = d.Ack(false)
-				metrics.WorkerProcessDuration.Observe(time.Since(start).Seconds())
-				continue
-			}
-			fields := []any{
-				"campaign_id", job.CampaignID,
-				"recipient_id", job.RecipientID,
-				"address", job.Address,
-			}
+	select {
+	case <-done:
+		if ctx.Err() == nil {
+			logx.L().Warnw("consumer_channel_closed")
+			return nil
+		}
+	case <-ctx.Done():
+		logx.L().Infow("worker_stopping")
+		var timeout <-chan time.Time
+		if w.Opts.DrainTimeout > 0 {
+			t := time.NewTimer(w.Opts.DrainTimeout)
+			defer t.Stop()
+			timeout = t.C
+		}
+		select {
+		case <-done:
+		case <-timeout:
+			logx.L().Warnw("worker_drain_timeout", "timeout", w.Opts.DrainTimeout.String())
+			cancelJobs()
+			<-done
+		}
+	}
+	logx.L().Infow("worker_drained")
+	return ctx.Err()
+}
 
-			ctx1, cancel1 := context.WithTimeout(ctx, 5*time.Second)
-			body, err := w.Store.GetCampaignBody(ctx1, db, job.CampaignID)
-			cancel1()
-			if err != nil {
-				logx.L().Errorw("db_get_campaign_body_error", append(fields, "error", err)...)
-				_ = d.Nack(false, true)
-				metrics.WorkerProcessDuration.Observe(time.Since(start).Seconds())
-				continue
-			}
+// lease — сколько воркер держит взятое сообщение. Округляется до секунды:
+// повторная проверка ждёт в очереди с таким же TTL.
+func (w *Worker) lease() time.Duration {
+	if w.Opts.ClaimLease < time.Second {
+		return defaultClaimLease
+	}
+	return w.Opts.ClaimLease.Round(time.Second)
+}
 
-			if err := simulateSend(job.Address, body); err != nil {
-				logx.L().Infow("send_failed", append(fields, "error", err)...)
+func (w *Worker) concurrency() int {
+	if w.Opts.Concurrency < 1 {
+		return 1
+	}
+	return w.Opts.Concurrency
+}
 
-				ctx2, cancel2 := context.WithTimeout(ctx, 5*time.Second)
-				if err := w.Store.MarkMessageFailed(ctx2, db, job.CampaignID, job.RecipientID, err.Error()); err != nil {
-					cancel2()
-					logx.L().Errorw("db_mark_failed_error", append(fields, "error", err)...)
-					metrics.WorkerProcessDuration.Observe(time.Since(start).Seconds())
-					_ = d.Nack(false, true)
-					continue
-				}
-				cancel2()
-
-				metrics.WorkerJobsFailed.Inc()
-
-				retries := headerRetries(d.Headers)
-				if retries < 3 {
-					delay := backoffDelay(retries)
-					metrics.WorkerJobRetries.Inc()
-					logx.L().Infow("retry_requeue", append(fields, "retries", retries+1, "delay", delay.String())...)
-					if err := w.requeueMessage(ctx, d, retries+1, delay); err != nil {
-						logx.L().Errorw("retry_publish_error", append(fields, "retries", retries+1, "error", err)...)
-						_ = d.Nack(false, true)
-					}
-				} else {
-					logx.L().Warnw("drop_after_retries", append(fields, "retries", retries)...)
-					_ = d.Ack(false)
-				}
+func (w *Worker) handle(ctx context.Context, db *sql.DB, d amqp.Delivery) {
+	// очередь ожидания вернула сообщение по своей ступени, а пауза ещё не вышла
+	if rest := rmq.DelayRemaining(d.Headers, w.now()); rest > 0 {
+		pubCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		if err := w.Pub.PublishDelayed(pubCtx, d.Body, copyHeaders(d.Headers), rest); err != nil {
+			logx.L().Errorw("delay_republish_error", "error", err)
+			_ = d.Nack(false, true)
+			return
+		}
+		_ = d.Ack(false)
+		return
+	}
+
+	start := time.Now()
+	metrics.WorkerJobsConsumed.Inc()
+	defer func() { metrics.WorkerProcessDuration.Observe(time.Since(start).Seconds()) }()
+
+	var job campaign.JobMessage
+	if err := json.Unmarshal(d.Body, &job); err != nil {
+		logx.L().Warnw("job_unmarshal_error", "error", err)
+		w.deadLetter(ctx, d, "malformed_payload: "+err.Error())
+		return
+	}
+	fields := []any{
+		"campaign_id", job.CampaignID,
+		"recipient_id", job.RecipientID,
+		"address", job.Address,
+	}
 
-				metrics.WorkerProcessDuration.Observe(time.Since(start).Seconds())
-				continue
+	ctx1, cancel1 := context.WithTimeout(ctx, 5*time.Second)
+	row, err := w.Store.LoadJob(ctx1, db, job.CampaignID, job.RecipientID)
+	cancel1()
+	if errors.Is(err, sql.ErrNoRows) {
+		logx.L().Warnw("job_message_not_found", fields...)
+		_ = d.Ack(false)
+		return
+	}
+	if err != nil {
+		logx.L().Errorw("db_load_job_error", append(fields, "error", err)...)
+		_ = d.Nack(false, true)
+		return
+	}
+
+	switch {
+	case row.CampaignStatus == campaign.StatusCanceled:
+		logx.L().Infow("skip_canceled", fields...)
+		_ = d.Ack(false)
+		return
+	case row.CampaignStatus == campaign.StatusPaused:
+		// сообщение остаётся pending — resume опубликует его заново
+		logx.L().Infow("hold_paused", fields...)
+		_ = d.Ack(false)
+		return
+	case row.MessageStatus != "pending" && row.MessageStatus != "sending":
+		logx.L().Infow("skip_not_pending", append(fields, "status", row.MessageStatus)...)
+		_ = d.Ack(false)
+		return
+	case row.Suppressed:
+		// адрес заблокировали уже после создания кампании
+		ctx2, cancel2 := context.WithTimeout(ctx, 5*time.Second)
+		err := w.Store.MarkMessageSuppressed(ctx2, db, job.CampaignID, job.RecipientID)
+		cancel2()
+		if err != nil {
+			logx.L().Errorw("db_mark_suppressed_error", append(fields, "error", err)...)
+			_ = d.Nack(false, true)
+			return
+		}
+		metrics.WorkerJobsSuppressed.Inc()
+		logx.L().Infow("skip_suppressed", fields...)
+		_ = d.Ack(false)
+		return
+	}
+
+	msg, err := w.compose(job, row)
+	if err != nil {
+		// ошибка шаблона не исправится ретраем — сразу failed
+		metrics.WorkerJobsFailed.Inc()
+		ctx2, cancel2 := context.WithTimeout(ctx, 5*time.Second)
+		// заявки ещё нет: пустой владелец означает «только из pending»
+		err := w.Store.MarkMessageFailed(ctx2, db, job.CampaignID, job.RecipientID, "", err.Error(), string(sender.ClassPermanent))
+		cancel2()
+		if errors.Is(err, store.ErrClaimLost) {
+			claimLost(d, fields)
+			return
+		}
+		if err != nil {
+			logx.L().Errorw("db_mark_failed_error", append(fields, "error", err)...)
+			_ = d.Nack(false, true)
+			return
+		}
+		logx.L().Warnw("compose_failed", fields...)
+		_ = d.Ack(false)
+		return
+	}
+
+	ctx4, cancel4 := context.WithTimeout(ctx, 5*time.Second)
+	claimed, err := w.Store.ClaimMessage(ctx4, db, job.CampaignID, job.RecipientID, w.Opts.WorkerID, w.lease())
+	cancel4()
+	if err != nil {
+		logx.L().Errorw("db_claim_error", append(fields, "error", err)...)
+		_ = d.Nack(false, true)
+		return
+	}
+	if !claimed {
+		// сообщение уже отправлено или его держит другой воркер. Если тот
+		// упадёт, заявка истечёт — проверим ещё раз, когда пройдёт lease.
+		logx.L().Infow("skip_claimed", fields...)
+		if err := w.scheduleRecheck(ctx, d); err != nil {
+			logx.L().Errorw("recheck_publish_error", append(fields, "error", err)...)
+			_ = d.Nack(false, true)
+		}
+		return
+	}
+
+	retries := headerRetries(d.Headers)
+	startedAt, t0 := w.now(), time.Now()
+	res, err := w.Sender.Send(ctx, msg)
+	w.recordAttempt(ctx, db, job, retries+1, startedAt, time.Since(t0), res, err, fields)
+
+	if err != nil {
+		class := sender.Classify(err)
+		logx.L().Infow("send_failed", append(fields, "class", class, "error", err)...)
+
+		metrics.WorkerJobsFailed.Inc()
+
+		delay, exhausted := w.nextRetry(row.Retry, class, retries, d.Headers)
+		if class.Retryable() && exhausted == "" {
+			// до исчерпания ретраев сообщение возвращается в pending, сохраняем только ошибку
+			ctx2, cancel2 := context.WithTimeout(ctx, 5*time.Second)
+			markErr := w.Store.RecordMessageError(ctx2, db, job.CampaignID, job.RecipientID, w.Opts.WorkerID, err.Error(), string(class), delay)
+			cancel2()
+			if errors.Is(markErr, store.ErrClaimLost) {
+				claimLost(d, fields)
+				return
+			}
+			if markErr != nil {
+				logx.L().Errorw("db_record_error_error", append(fields, "error", markErr)...)
 			}
 
-			ctx3, cancel3 := context.WithTimeout(ctx, 5*time.Second)
-			if err := w.Store.MarkMessageSent(ctx3, db, job.CampaignID, job.RecipientID); err != nil {
-				cancel3()
-				logx.L().Errorw("db_mark_sent_error", append(fields, "error", err)...)
-				metrics.WorkerProcessDuration.Observe(time.Since(start).Seconds())
+			metrics.WorkerJobRetries.Inc()
+			logx.L().Infow("retry_scheduled", append(fields, "retries", retries+1, "delay", delay.String())...)
+			if err := w.scheduleRetry(ctx, d, retries+1, delay); err != nil {
+				logx.L().Errorw("retry_publish_error", append(fields, "retries", retries+1, "error", err)...)
 				_ = d.Nack(false, true)
-				continue
 			}
-			cancel3()
+			return
+		}
 
-			metrics.WorkerJobsSent.Inc()
-			metrics.WorkerProcessDuration.Observe(time.Since(start).Seconds())
+		ctx2, cancel2 := context.WithTimeout(ctx, 5*time.Second)
+		markErr := w.Store.MarkMessageFailed(ctx2, db, job.CampaignID, job.RecipientID, w.Opts.WorkerID, err.Error(), string(class))
+		cancel2()
+		if errors.Is(markErr, store.ErrClaimLost) {
+			claimLost(d, fields)
+			return
+		}
+		if markErr != nil {
+			logx.L().Errorw("db_mark_failed_error", append(fields, "error", markErr)...)
+			_ = d.Nack(false, true)
+			return
+		}
 
-			logx.L().Infow("send_success", fields...)
+		if !class.Retryable() {
+			// постоянный отказ — штатный исход, в dead-letter его не кладём
+			logx.L().Warnw("send_rejected", append(fields, "class", class)...)
 			_ = d.Ack(false)
+			return
 		}
+
+		logx.L().Warnw("dead_letter_after_retries", append(fields, "retries", retries, "reason", exhausted)...)
+		w.deadLetter(ctx, d, exhausted+": "+err.Error(), fields...)
+		return
+	}
+
+	ctx3, cancel3 := context.WithTimeout(ctx, 5*time.Second)
+	err = w.Store.MarkMessageSent(ctx3, db, job.CampaignID, job.RecipientID, w.Opts.WorkerID)
+	cancel3()
+	if errors.Is(err, store.ErrClaimLost) {
+		claimLost(d, fields)
+		return
 	}
+	if err != nil {
+		logx.L().Errorw("db_mark_sent_error", append(fields, "error", err)...)
+		_ = d.Nack(false, true)
+		return
+	}
+
+	metrics.WorkerJobsSent.Inc()
+	logx.L().Infow("send_success", fields...)
+	_ = d.Ack(false)
 }
 
-func (w *Worker) requeueMessage(ctx context.Context, d amqp.Delivery, retries int, delay time.Duration) error {
-	if delay > 0 {
-		timer := time.NewTimer(delay)
-		defer timer.Stop()
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case <-timer.C:
+// claimLost закрывает задание, чьё сообщение воркер больше не держит: его
+// забрал другой воркер после истечения заявки или отменили с кампанией.
+// Итог этой попытки в БД не пишется — сообщением распоряжается новый владелец.
+func claimLost(d amqp.Delivery, fields []any) {
+	logx.L().Warnw("claim_lost", fields...)
+	_ = d.Ack(false)
+}
+
+// recordAttempt пишет попытку в историю сообщения. Ошибка записи не влияет
+// на обработку задания: история — диагностика, а не состояние.
+func (w *Worker) recordAttempt(ctx context.Context, db *sql.DB, job campaign.JobMessage, attempt int,
+	startedAt time.Time, dur time.Duration, res sender.Result, sendErr error, fields []any) {
+	a := store.Attempt{
+		Attempt:      attempt,
+		StartedAt:    startedAt,
+		Duration:     dur,
+		Transport:    res.Transport,
+		Provider:     res.Provider,
+		ResponseCode: res.Code,
+		ResponseText: res.Response,
+	}
+	if sendErr != nil {
+		a.ErrorClass = string(sender.Classify(sendErr))
+		a.Error = sendErr.Error()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := w.Store.InsertAttempt(ctx, db, job.CampaignID, job.RecipientID, a); err != nil {
+		logx.L().Errorw("db_record_attempt_error", append(fields, "error", err)...)
+	}
+}
+
+// templateVars дополняет переменные получателя его адресом и именем, если
+// те не заданы явно.
+func templateVars(address, name string, vars map[string]any) map[string]any {
+	out := make(map[string]any, len(vars)+2)
+	for k, v := range vars {
+		out[k] = v
+	}
+	if _, ok := out["address"]; !ok {
+		out["address"] = address
+	}
+	if _, ok := out["name"]; !ok {
+		out["name"] = name
+	}
+	return out
+}
+
+// unsubscribeURL — ссылка отписки получателя; пусто, если ссылки выключены.
+func (w *Worker) unsubscribeURL(job campaign.JobMessage) string {
+	if w.Opts.Links == nil || w.Opts.PublicURL == "" {
+		return ""
+	}
+	return w.Opts.PublicURL + "/u/" + w.Opts.Links.Sign(token.KindUnsubscribe, job.CampaignID, job.RecipientID)
+}
+
+// openPixelURL — пиксель открытия письма; пусто, если ссылки выключены.
+func (w *Worker) openPixelURL(job campaign.JobMessage) string {
+	if w.Opts.Links == nil || w.Opts.PublicURL == "" {
+		return ""
+	}
+	return w.Opts.PublicURL + "/t/o/" + w.Opts.Links.Sign(token.KindOpen, job.CampaignID, job.RecipientID)
+}
+
+// withOpenPixel вставляет картинку 1×1 перед последним </body>, а если его
+// нет — в конец документа.
+func withOpenPixel(body, url string) string {
+	img := `<img src="` + html.EscapeString(url) + `" width="1" height="1" alt="" style="display:block;border:0;width:1px;height:1px">`
+	const closing = "</body>"
+	for i := len(body) - len(closing); i >= 0; i-- {
+		if strings.EqualFold(body[i:i+len(closing)], closing) {
+			return body[:i] + img + body[i:]
+		}
+	}
+	return body + img
+}
+
+// withUnsubscribe добавляет к заголовкам кампании one-click отписку по
+// RFC 8058. Одноимённые заголовки из кампании заменяются: ссылка должна
+// вести на токен этого получателя.
+func withUnsubscribe(headers map[string]string, url string) map[string]string {
+	out := make(map[string]string, len(headers)+2)
+	for name, v := range headers {
+		switch textproto.CanonicalMIMEHeaderKey(name) {
+		case "List-Unsubscribe", "List-Unsubscribe-Post":
+			continue
+		}
+		out[name] = v
+	}
+	out["List-Unsubscribe"] = "<" + url + ">"
+	out["List-Unsubscribe-Post"] = "List-Unsubscribe=One-Click"
+	return out
+}
+
+// compose рендерит шаблоны кампании для получателя и собирает MIME-письмо.
+func (w *Worker) compose(job campaign.JobMessage, row store.JobRow) (sender.Message, error) {
+	to := job.Address
+	vars := templateVars(to, row.RecipientName, row.Vars)
+	toHeader := to
+	if row.RecipientName != "" {
+		toHeader = (&mail.Address{Name: row.RecipientName, Address: to}).String()
+	}
+	m := message.Message{
+		To:      []string{toHeader},
+		ReplyTo: row.ReplyTo,
+		Date:    w.now(),
+		Headers: row.Headers,
+	}
+	if u := w.unsubscribeURL(job); u != "" {
+		if _, ok := vars["unsubscribe_url"]; !ok {
+			vars["unsubscribe_url"] = u
+		}
+		m.Headers = withUnsubscribe(row.Headers, u)
+	}
+
+	var err error
+	if m.Subject, err = render.Render(row.Subject, render.FormatText, vars); err != nil {
+		return sender.Message{}, fmt.Errorf("render subject: %w", err)
+	}
+	format := row.Format
+	body, err := render.Render(row.Body, format, vars)
+	if err != nil {
+		return sender.Message{}, fmt.Errorf("render: %w", err)
+	}
+	if format == render.FormatHTML {
+		m.HTML = body
+		if u := w.openPixelURL(job); u != "" && row.TrackOpens {
+			m.HTML = withOpenPixel(m.HTML, u)
+		}
+		if m.Text, err = render.Render(row.TextBody, render.FormatText, vars); err != nil {
+			return sender.Message{}, fmt.Errorf("render text_body: %w", err)
+		}
+	} else {
+		m.Text = body
+	}
+
+	if row.FromAddress != "" {
+		m.From = mail.Address{Address: row.FromAddress}
+	} else {
+		from, err := mail.ParseAddress(w.Opts.From)
+		if err != nil {
+			return sender.Message{}, fmt.Errorf("parse MAIL_FROM: %w", err)
 		}
+		m.From = *from
+	}
+	if row.FromName != "" {
+		m.From.Name = row.FromName
 	}
+	// подписанный Message-ID позволяет узнать письмо по жалобе или отчёту,
+	// вернувшим только его заголовки
+	if w.Opts.Links != nil {
+		domain := m.From.Address[strings.LastIndexByte(m.From.Address, '@')+1:]
+		m.MessageID = w.Opts.Links.MessageID(domain, job.CampaignID, job.RecipientID)
+	}
+
+	data, err := w.builder.Build(&m)
+	if err != nil {
+		return sender.Message{}, fmt.Errorf("build message: %w", err)
+	}
+	envFrom := m.From.Address
+	if w.Opts.Links != nil && w.Opts.BounceAddress != "" {
+		envFrom = w.Opts.Links.VERP(w.Opts.BounceAddress, job.CampaignID, job.RecipientID)
+	}
+	return sender.Message{From: envFrom, To: []string{to}, Data: data}, nil
+}
 
+// scheduleRetry публикует копию задания в очередь ожидания и сразу
+// подтверждает исходную доставку: обработчик не простаивает на backoff.
+func (w *Worker) scheduleRetry(ctx context.Context, d amqp.Delivery, retries int, delay time.Duration) error {
 	headers := copyHeaders(d.Headers)
 	setHeaderRetries(&headers, retries)
+	if _, ok := headerFirstFailure(headers); !ok {
+		headers[headerFirstFailureAt] = w.now().UnixMilli()
+	}
 
 	pubCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
-	if err := w.Pub.PublishJSONWithHeaders(pubCtx, d.Body, headers); err != nil {
+	if err := w.Pub.PublishDelayed(pubCtx, d.Body, headers, delay); err != nil {
 		return err
 	}
 
 	return d.Ack(false)
 }
 
+// scheduleRecheck откладывает доставку на время lease, не считая это попыткой.
+func (w *Worker) scheduleRecheck(ctx context.Context, d amqp.Delivery) error {
+	pubCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := w.Pub.PublishDelayed(pubCtx, d.Body, copyHeaders(d.Headers), w.lease()); err != nil {
+		return err
+	}
+	return d.Ack(false)
+}
+
+// deadLetter перекладывает доставку в dead-letter очередь и подтверждает её.
+// Если публикация не удалась, доставка возвращается в основную очередь.
+func (w *Worker) deadLetter(ctx context.Context, d amqp.Delivery, reason string, fields ...any) {
+	pubCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := w.Pub.PublishDead(pubCtx, d.Body, d.Headers, reason); err != nil {
+		logx.L().Errorw("dead_letter_publish_error", append(fields, "error", err)...)
+		_ = d.Nack(false, true)
+		return
+	}
+	metrics.WorkerJobsDeadLettered.Inc()
+	_ = d.Ack(false)
+}
+
 func headerRetries(h amqp.Table) int {
 	if h == nil {
 		return 0
@@ -188,12 +590,47 @@ func setHeaderRetries(h *amqp.Table, n int) {
 	(*h)["x-retries"] = int32(n)
 }
 
-func backoffDelay(retries int) time.Duration {
-	if retries <= 0 {
-		return 0
+// rateLimitFactor удлиняет паузы после отказа из-за ограничения темпа.
+const rateLimitFactor = 30
+
+// nextRetry решает, повторять ли отправку после retries неудачных повторов.
+// Если повтор возможен, возвращает паузу перед ним, иначе — причину отказа
+// для dead-letter.
+func (w *Worker) nextRetry(policy campaign.RetryPolicy, class sender.ErrorClass, retries int, h amqp.Table) (time.Duration, string) {
+	if policy.MaxAttempts == 0 {
+		policy = w.Opts.Retry
+	}
+	if retries+1 >= policy.MaxAttempts {
+		return 0, "retries_exhausted"
+	}
+	if class == sender.ClassRateLimited {
+		policy.BaseDelay *= rateLimitFactor
+		policy.MaxDelay = max(policy.MaxDelay, policy.BaseDelay)
+	}
+	delay := policy.Backoff(retries, w.rand())
+
+	if policy.Deadline > 0 {
+		now := w.now()
+		first, ok := headerFirstFailure(h)
+		if !ok {
+			first = now
+		}
+		if now.Add(delay).After(first.Add(policy.Deadline)) {
+			return 0, "retry_deadline_exceeded"
+		}
+	}
+	return delay, ""
+}
+
+// headerFirstFailureAt — время первой неудачной попытки в миллисекундах Unix;
+// от него отсчитывается deadline политики повторов.
+const headerFirstFailureAt = "x-first-failure-at"
+
+func headerFirstFailure(h amqp.Table) (time.Time, bool) {
+	if v, ok := h[headerFirstFailureAt].(int64); ok {
+		return time.UnixMilli(v), true
 	}
-	sec := math.Pow(2, float64(retries-1))
-	return time.Duration(sec) * time.Second
+	return time.Time{}, false
 }
 
 func copyHeaders(h amqp.Table) amqp.Table {
@@ -0,0 +1,848 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/quotedprintable"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	amqp "github.com/rabbitmq/amqp091-go"
+
+	"github.com/Mutter0815/MassMailer/internal/campaign"
+	"github.com/Mutter0815/MassMailer/internal/store"
+	"github.com/Mutter0815/MassMailer/internal/token"
+	"github.com/Mutter0815/MassMailer/pkg/rmq"
+	"github.com/Mutter0815/MassMailer/services/sender-worker/message"
+	"github.com/Mutter0815/MassMailer/services/sender-worker/sender"
+	"github.com/Mutter0815/MassMailer/services/sender-worker/sender/sendertest"
+)
+
+type fakeAck struct {
+	acked, nacked, requeued int
+}
+
+func (a *fakeAck) Ack(tag uint64, multiple bool) error { a.acked++; return nil }
+
+func (a *fakeAck) Nack(tag uint64, multiple, requeue bool) error {
+	a.nacked++
+	if requeue {
+		a.requeued++
+	}
+	return nil
+}
+
+func (a *fakeAck) Reject(tag uint64, requeue bool) error { return a.Nack(tag, false, requeue) }
+
+func newTestWorker(t *testing.T, srv *sendertest.Server) (*Worker, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	smtp, err := sender.NewSMTP(sender.SMTPConfig{Host: srv.Host(), Port: srv.Port(), TLSMode: sender.TLSModeNone})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := New(store.New(db), nil, nil, smtp, Options{From: "news@example.com", Retry: testRetry, WorkerID: "w1"})
+	w.Pub = &fakePublisher{}
+	w.now = func() time.Time { return time.Date(2025, 10, 2, 12, 0, 0, 0, time.UTC) }
+	w.rand = func() float64 { return 0.5 }
+	w.builder = message.Builder{Random: bytes.NewReader(make([]byte, 64))}
+	return w, mock
+}
+
+var testRetry = campaign.RetryPolicy{
+	MaxAttempts: 4,
+	BaseDelay:   time.Second,
+	MaxDelay:    5 * time.Minute,
+	Deadline:    24 * time.Hour,
+}
+
+// expectClaim ожидает попытку забрать сообщение; ok — удалась ли она.
+func expectClaim(mock sqlmock.Sqlmock, recipientID int64, ok bool) {
+	var n int64
+	if ok {
+		n = 1
+	}
+	mock.ExpectExec(`(?s)UPDATE messages.*SET status='sending'`).
+		WithArgs(int64(7), recipientID, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, n))
+}
+
+// expectAttempt ожидает запись попытки номер attempt с классом ошибки class
+// ("" — успешная отправка).
+func expectAttempt(mock sqlmock.Sqlmock, recipientID int64, attempt int, class string) {
+	anyArg := sqlmock.AnyArg()
+	mock.ExpectExec(`(?s)INSERT INTO message_attempts`).
+		WithArgs(int64(7), recipientID, attempt, anyArg, anyArg, anyArg, anyArg, anyArg, anyArg, class, anyArg).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func jobRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"body", "status", "status", "vars",
+		"subject", "from_name", "from_address", "reply_to", "headers", "text_body", "retry_policy", "name", "suppressed", "track_opens", "body_format"})
+}
+
+func TestHandle_SendsViaSMTP(t *testing.T) {
+	srv := sendertest.NewServer(t, sendertest.Options{})
+	w, mock := newTestWorker(t, srv)
+
+	mock.ExpectQuery(`(?s)SELECT c.body, c.status, m.status.*FROM messages m`).
+		WithArgs(int64(7), int64(101)).
+		WillReturnRows(jobRows().
+			AddRow("Hello {{.first_name}}\nWorld", "processing", "pending", []byte(`{"first_name":"Ann"}`),
+				"Привет, {{.first_name}}", "Команда", "team@example.com", "help@example.com", []byte(`{"X-Campaign":"7"}`), "", []byte(`{}`), "Ann Lee", false, false, "text"))
+	expectClaim(mock, 101, true)
+	mock.ExpectExec(`(?s)INSERT INTO message_attempts`).
+		WithArgs(int64(7), int64(101), 1, time.Date(2025, 10, 2, 12, 0, 0, 0, time.UTC), sqlmock.AnyArg(),
+			"smtp", fmt.Sprintf("127.0.0.1:%d", srv.Port()), 250, "2.0.0 queued as 1", "", "").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`(?s)UPDATE messages.*SET status=.sent.`).
+		WithArgs(int64(7), int64(101), "w1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	ack := &fakeAck{}
+	w.handle(context.Background(), w.Store.DB, amqp.Delivery{
+		Acknowledger: ack,
+		Body:         []byte(`{"campaign_id":7,"recipient_id":101,"address":"u1@example.com"}`),
+	})
+
+	if ack.acked != 1 || ack.nacked != 0 {
+		t.Fatalf("want 1 ack, got ack=%d nack=%d", ack.acked, ack.nacked)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+
+	got := srv.Last(t)
+	if got.From != "team@example.com" {
+		t.Fatalf("mail from: got %q", got.From)
+	}
+	if len(got.To) != 1 || got.To[0] != "u1@example.com" {
+		t.Fatalf("rcpt to: got %v", got.To)
+	}
+	want := "From: =?utf-8?q?=D0=9A=D0=BE=D0=BC=D0=B0=D0=BD=D0=B4=D0=B0?=\n <team@example.com>\n" +
+		"Reply-To: help@example.com\n" +
+		"To: \"Ann Lee\" <u1@example.com>\n" +
+		"Subject: =?utf-8?q?=D0=9F=D1=80=D0=B8=D0=B2=D0=B5=D1=82,_Ann?=\n" +
+		"Date: Thu, 02 Oct 2025 12:00:00 +0000\n" +
+		"Message-ID: <00000000000000000000000000000000@example.com>\n" +
+		"X-Campaign: 7\n" +
+		"MIME-Version: 1.0\n" +
+		"Content-Type: text/plain; charset=utf-8\n" +
+		"Content-Transfer-Encoding: 7bit\n" +
+		"\n" +
+		"Hello Ann\nWorld\n"
+	if got.Data != want {
+		t.Fatalf("data mismatch:\ngot:  %q\nwant: %q", got.Data, want)
+	}
+}
+
+func TestHandle_BadPayloadDeadLettered(t *testing.T) {
+	srv := sendertest.NewServer(t, sendertest.Options{})
+	w, mock := newTestWorker(t, srv)
+
+	ack := &fakeAck{}
+	w.handle(context.Background(), w.Store.DB, amqp.Delivery{Acknowledger: ack, Body: []byte(`not json`)})
+
+	if ack.acked != 1 {
+		t.Fatalf("want ack for malformed payload, got %+v", ack)
+	}
+	if dead := w.Pub.(*fakePublisher).dead; len(dead) != 1 || !strings.HasPrefix(dead[0], "malformed_payload") {
+		t.Fatalf("payload must be dead-lettered, got %v", dead)
+	}
+	if len(srv.Sessions()) != 0 {
+		t.Fatal("nothing should be sent")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHandle_SkipsCanceledAndPaused(t *testing.T) {
+	cases := []struct {
+		campaignStatus, messageStatus string
+	}{
+		{"canceled", "pending"},
+		{"paused", "pending"},
+		{"processing", "sent"},
+	}
+	for _, c := range cases {
+		srv := sendertest.NewServer(t, sendertest.Options{})
+		w, mock := newTestWorker(t, srv)
+
+		mock.ExpectQuery(`(?s)SELECT c.body, c.status, m.status`).
+			WithArgs(int64(7), int64(101)).
+			WillReturnRows(jobRows().AddRow("Hi", c.campaignStatus, c.messageStatus, []byte(`{}`), "", "", "", "", []byte(`{}`), "", []byte(`{}`), "", false, false, "text"))
+
+		ack := &fakeAck{}
+		w.handle(context.Background(), w.Store.DB, amqp.Delivery{
+			Acknowledger: ack,
+			Body:         []byte(`{"campaign_id":7,"recipient_id":101,"address":"u1@example.com"}`),
+		})
+
+		if ack.acked != 1 || ack.nacked != 0 {
+			t.Fatalf("%s/%s: want ack without send, got %+v", c.campaignStatus, c.messageStatus, ack)
+		}
+		if n := len(srv.Sessions()); n != 0 {
+			t.Fatalf("%s/%s: nothing should be sent, got %d sessions", c.campaignStatus, c.messageStatus, n)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestHandle_SuppressedNotSent(t *testing.T) {
+	srv := sendertest.NewServer(t, sendertest.Options{})
+	w, mock := newTestWorker(t, srv)
+
+	mock.ExpectQuery(`(?s)SELECT c.body, c.status, m.status.*FROM suppressions s`).
+		WithArgs(int64(7), int64(101)).
+		WillReturnRows(jobRows().AddRow("Hi", "processing", "pending", []byte(`{}`), "", "", "", "", []byte(`{}`), "", []byte(`{}`), "", true, false, "text"))
+	mock.ExpectExec(`(?s)UPDATE messages.*SET status='suppressed'.*status IN \('pending','sending'\)`).
+		WithArgs(int64(7), int64(101)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	ack := &fakeAck{}
+	w.handle(context.Background(), w.Store.DB, amqp.Delivery{
+		Acknowledger: ack,
+		Body:         []byte(`{"campaign_id":7,"recipient_id":101,"address":"u1@example.com"}`),
+	})
+
+	if ack.acked != 1 || ack.nacked != 0 {
+		t.Fatalf("want ack without send, got %+v", ack)
+	}
+	if n := len(srv.Sessions()); n != 0 {
+		t.Fatalf("nothing should be sent, got %d sessions", n)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHandle_RenderErrorMarksFailed(t *testing.T) {
+	srv := sendertest.NewServer(t, sendertest.Options{})
+	w, mock := newTestWorker(t, srv)
+
+	mock.ExpectQuery(`(?s)SELECT c.body, c.status, m.status`).
+		WithArgs(int64(7), int64(101)).
+		WillReturnRows(jobRows().
+			AddRow("Hi {{.first_name}}", "processing", "pending", []byte(`{}`), "", "", "", "", []byte(`{}`), "", []byte(`{}`), "", false, false, "text"))
+	mock.ExpectExec(`(?s)UPDATE messages.*SET status='failed'.*status='pending' AND \$5 = ''`).
+		WithArgs(sqlmock.AnyArg(), int64(7), int64(101), "permanent", "").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	ack := &fakeAck{}
+	w.handle(context.Background(), w.Store.DB, amqp.Delivery{
+		Acknowledger: ack,
+		Body:         []byte(`{"campaign_id":7,"recipient_id":101,"address":"u1@example.com"}`),
+	})
+
+	if ack.acked != 1 || ack.nacked != 0 {
+		t.Fatalf("want ack after render error, got %+v", ack)
+	}
+	if len(srv.Sessions()) != 0 {
+		t.Fatal("nothing should be sent")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// blockingSender держит отправку, пока тест не закроет release.
+type blockingSender struct {
+	mu       sync.Mutex
+	inFlight int
+	peak     int
+	started  chan struct{}
+	release  chan struct{}
+}
+
+func (s *blockingSender) Send(ctx context.Context, msg sender.Message) (sender.Result, error) {
+	s.mu.Lock()
+	s.inFlight++
+	if s.inFlight > s.peak {
+		s.peak = s.inFlight
+	}
+	s.mu.Unlock()
+	s.started <- struct{}{}
+
+	<-s.release
+	s.mu.Lock()
+	s.inFlight--
+	s.mu.Unlock()
+	return sender.Result{}, ctx.Err()
+}
+
+func TestProcess_ParallelAndDrains(t *testing.T) {
+	const workers = 4
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	mock.MatchExpectationsInOrder(false)
+
+	snd := &blockingSender{started: make(chan struct{}, workers), release: make(chan struct{})}
+	w := New(store.New(db), nil, nil, snd, Options{From: "news@example.com", Concurrency: workers, WorkerID: "w1"})
+
+	msgs := make(chan amqp.Delivery, workers)
+	acks := make([]*fakeAck, workers)
+	for i := range acks {
+		rid := int64(101 + i)
+		mock.ExpectQuery(`(?s)SELECT c.body, c.status, m.status`).
+			WithArgs(int64(7), rid).
+			WillReturnRows(jobRows().AddRow("Hi", "processing", "pending", []byte(`{}`), "", "", "", "", []byte(`{}`), "", []byte(`{}`), "", false, false, "text"))
+		expectClaim(mock, rid, true)
+		expectAttempt(mock, rid, 1, "")
+		mock.ExpectExec(`(?s)UPDATE messages.*SET status='sent'`).
+			WithArgs(int64(7), rid, "w1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		acks[i] = &fakeAck{}
+		msgs <- amqp.Delivery{
+			Acknowledger: acks[i],
+			Body:         []byte(fmt.Sprintf(`{"campaign_id":7,"recipient_id":%d,"address":"u@example.com"}`, rid)),
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	go func() { result <- w.process(ctx, db, msgs) }()
+
+	// все задания должны оказаться в отправке одновременно
+	for i := 0; i < workers; i++ {
+		select {
+		case <-snd.started:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("only %d of %d jobs started concurrently", i, workers)
+		}
+	}
+
+	// остановка во время отправки: взятые задания должны доделаться
+	cancel()
+	close(snd.release)
+
+	select {
+	case err := <-result:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("want context.Canceled, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("process did not drain")
+	}
+
+	if snd.peak != workers {
+		t.Fatalf("peak concurrency %d, want %d", snd.peak, workers)
+	}
+	for i, a := range acks {
+		if a.acked != 1 || a.nacked != 0 {
+			t.Fatalf("delivery %d: want exactly one ack, got %+v", i, a)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type delayedPublish struct {
+	headers amqp.Table
+	delay   time.Duration
+}
+
+type fakePublisher struct {
+	published []delayedPublish
+	dead      []string
+}
+
+func (p *fakePublisher) PublishDelayed(ctx context.Context, body []byte, headers amqp.Table, delay time.Duration) error {
+	p.published = append(p.published, delayedPublish{headers: headers, delay: delay})
+	return nil
+}
+
+func (p *fakePublisher) PublishDead(ctx context.Context, body []byte, headers amqp.Table, reason string) error {
+	p.dead = append(p.dead, reason)
+	return nil
+}
+
+func TestHandle_SendErrorSchedulesRetry(t *testing.T) {
+	srv := sendertest.NewServer(t, sendertest.Options{
+		RcptReplies: map[string]string{"u1@example.com": "451 4.3.0 try later"},
+	})
+	w, mock := newTestWorker(t, srv)
+	pub := w.Pub.(*fakePublisher)
+
+	mock.ExpectQuery(`(?s)SELECT c.body, c.status, m.status`).
+		WithArgs(int64(7), int64(101)).
+		WillReturnRows(jobRows().AddRow("Hi", "processing", "pending", []byte(`{}`), "", "", "", "", []byte(`{}`), "", []byte(`{}`), "", false, false, "text"))
+	expectClaim(mock, 101, true)
+	expectAttempt(mock, 101, 2, "temporary")
+	mock.ExpectExec(`(?s)UPDATE messages.*SET status='pending', last_error=`).
+		WithArgs(sqlmock.AnyArg(), int64(7), int64(101), "temporary", float64(2), "w1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	ack := &fakeAck{}
+	w.handle(context.Background(), w.Store.DB, amqp.Delivery{
+		Acknowledger: ack,
+		Headers:      amqp.Table{"x-retries": int32(1)},
+		Body:         []byte(`{"campaign_id":7,"recipient_id":101,"address":"u1@example.com"}`),
+	})
+
+	if ack.acked != 1 || ack.nacked != 0 {
+		t.Fatalf("delivery must be acked right away, got %+v", ack)
+	}
+	if len(pub.published) != 1 {
+		t.Fatalf("want one delayed publish, got %d", len(pub.published))
+	}
+	got := pub.published[0]
+	if got.delay != 2*time.Second || headerRetries(got.headers) != 2 {
+		t.Fatalf("unexpected retry: delay=%s retries=%d", got.delay, headerRetries(got.headers))
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHandle_RetriesExhaustedDeadLettered(t *testing.T) {
+	srv := sendertest.NewServer(t, sendertest.Options{
+		RcptReplies: map[string]string{"u1@example.com": "451 4.3.0 try later"},
+	})
+	w, mock := newTestWorker(t, srv)
+
+	mock.ExpectQuery(`(?s)SELECT c.body, c.status, m.status`).
+		WithArgs(int64(7), int64(101)).
+		WillReturnRows(jobRows().AddRow("Hi", "processing", "pending", []byte(`{}`), "", "", "", "", []byte(`{}`), "", []byte(`{}`), "", false, false, "text"))
+	expectClaim(mock, 101, true)
+	expectAttempt(mock, 101, 4, "temporary")
+	mock.ExpectExec(`(?s)UPDATE messages.*SET status='failed'`).
+		WithArgs(sqlmock.AnyArg(), int64(7), int64(101), "temporary", "w1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	ack := &fakeAck{}
+	w.handle(context.Background(), w.Store.DB, amqp.Delivery{
+		Acknowledger: ack,
+		Headers:      amqp.Table{"x-retries": int32(3)},
+		Body:         []byte(`{"campaign_id":7,"recipient_id":101,"address":"u1@example.com"}`),
+	})
+
+	pub := w.Pub.(*fakePublisher)
+	if ack.acked != 1 || len(pub.published) != 0 {
+		t.Fatalf("want ack without retry, got ack=%+v retries=%d", ack, len(pub.published))
+	}
+	if len(pub.dead) != 1 || !strings.HasPrefix(pub.dead[0], "retries_exhausted") {
+		t.Fatalf("job must be dead-lettered, got %v", pub.dead)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHandle_SendErrorClasses(t *testing.T) {
+	cases := []struct {
+		reply     string
+		class     string
+		wantDelay time.Duration // 0 — повтора быть не должно
+	}{
+		{"550 5.1.1 no such user", "permanent", 0},
+		{"550 5.7.1 message rejected as spam", "policy_blocked", 0},
+		{"451 4.7.1 rate limit exceeded", "rate_limited", 60 * time.Second},
+	}
+	for _, c := range cases {
+		srv := sendertest.NewServer(t, sendertest.Options{
+			RcptReplies: map[string]string{"u1@example.com": c.reply},
+		})
+		w, mock := newTestWorker(t, srv)
+		pub := w.Pub.(*fakePublisher)
+
+		mock.ExpectQuery(`(?s)SELECT c.body, c.status, m.status`).
+			WithArgs(int64(7), int64(101)).
+			WillReturnRows(jobRows().AddRow("Hi", "processing", "pending", []byte(`{}`), "", "", "", "", []byte(`{}`), "", []byte(`{}`), "", false, false, "text"))
+		expectClaim(mock, 101, true)
+		expectAttempt(mock, 101, 2, c.class)
+		if c.wantDelay > 0 {
+			mock.ExpectExec(`(?s)UPDATE messages.*SET status='pending', last_error=`).
+				WithArgs(sqlmock.AnyArg(), int64(7), int64(101), c.class, c.wantDelay.Seconds(), "w1").
+				WillReturnResult(sqlmock.NewResult(0, 1))
+		} else {
+			mock.ExpectExec(`(?s)UPDATE messages.*SET status='failed'`).
+				WithArgs(sqlmock.AnyArg(), int64(7), int64(101), c.class, "w1").
+				WillReturnResult(sqlmock.NewResult(0, 1))
+		}
+
+		ack := &fakeAck{}
+		w.handle(context.Background(), w.Store.DB, amqp.Delivery{
+			Acknowledger: ack,
+			Headers:      amqp.Table{"x-retries": int32(1)},
+			Body:         []byte(`{"campaign_id":7,"recipient_id":101,"address":"u1@example.com"}`),
+		})
+
+		if ack.acked != 1 || ack.nacked != 0 {
+			t.Fatalf("%s: want ack, got %+v", c.reply, ack)
+		}
+		if len(pub.dead) != 0 {
+			t.Fatalf("%s: must not be dead-lettered, got %v", c.reply, pub.dead)
+		}
+		switch {
+		case c.wantDelay == 0 && len(pub.published) != 0:
+			t.Fatalf("%s: must not be retried", c.reply)
+		case c.wantDelay > 0 && (len(pub.published) != 1 || pub.published[0].delay != c.wantDelay):
+			t.Fatalf("%s: want retry after %s, got %+v", c.reply, c.wantDelay, pub.published)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatalf("%s: %v", c.reply, err)
+		}
+	}
+}
+
+func TestNextRetry_CampaignPolicy(t *testing.T) {
+	w := &Worker{
+		Opts: Options{Retry: testRetry},
+		now:  func() time.Time { return time.Date(2025, 10, 2, 12, 0, 0, 0, time.UTC) },
+		rand: func() float64 { return 1 },
+	}
+	first := amqp.Table{headerFirstFailureAt: w.now().Add(-50 * time.Second).UnixMilli()}
+	policy := campaign.RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   10 * time.Second,
+		MaxDelay:    15 * time.Second,
+		Jitter:      0.5,
+		Deadline:    time.Minute,
+	}
+	unbounded := policy
+	unbounded.Deadline = 0
+
+	cases := []struct {
+		name    string
+		policy  campaign.RetryPolicy
+		class   sender.ErrorClass
+		retries int
+		headers amqp.Table
+		delay   time.Duration
+		reason  string
+	}{
+		{"config fallback", campaign.RetryPolicy{}, sender.ClassTemporary, 2, nil, 4 * time.Second, ""},
+		{"jitter within max delay", policy, sender.ClassTemporary, 0, nil, 15 * time.Second, ""},
+		{"attempts exhausted", policy, sender.ClassTemporary, 2, nil, 0, "retries_exhausted"},
+		{"deadline exceeded", policy, sender.ClassTemporary, 1, first, 0, "retry_deadline_exceeded"},
+		{"rate limited", unbounded, sender.ClassRateLimited, 0, nil, 300 * time.Second, ""},
+	}
+	for _, c := range cases {
+		delay, reason := w.nextRetry(c.policy, c.class, c.retries, c.headers)
+		if delay != c.delay || reason != c.reason {
+			t.Errorf("%s: got delay=%s reason=%q, want %s %q", c.name, delay, reason, c.delay, c.reason)
+		}
+	}
+}
+
+func TestHandle_ClaimedElsewhereRechecked(t *testing.T) {
+	srv := sendertest.NewServer(t, sendertest.Options{})
+	w, mock := newTestWorker(t, srv)
+	pub := w.Pub.(*fakePublisher)
+
+	// повторная доставка, пока сообщение держит другой живой воркер
+	mock.ExpectQuery(`(?s)SELECT c.body, c.status, m.status`).
+		WithArgs(int64(7), int64(101)).
+		WillReturnRows(jobRows().AddRow("Hi", "processing", "sending", []byte(`{}`), "", "", "", "", []byte(`{}`), "", []byte(`{}`), "", false, false, "text"))
+	mock.ExpectExec(`(?s)UPDATE messages.*SET status='sending'`).
+		WithArgs(int64(7), int64(101), "w1", float64(120)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	ack := &fakeAck{}
+	w.handle(context.Background(), w.Store.DB, amqp.Delivery{
+		Acknowledger: ack,
+		Headers:      amqp.Table{"x-retries": int32(1)},
+		Body:         []byte(`{"campaign_id":7,"recipient_id":101,"address":"u1@example.com"}`),
+	})
+
+	if n := len(srv.Sessions()); n != 0 {
+		t.Fatalf("claimed message must not be sent, got %d sessions", n)
+	}
+	if ack.acked != 1 || ack.nacked != 0 {
+		t.Fatalf("want ack, got %+v", ack)
+	}
+	if len(pub.published) != 1 || pub.published[0].delay != defaultClaimLease ||
+		headerRetries(pub.published[0].headers) != 1 {
+		t.Fatalf("want recheck after lease without spending a retry, got %+v", pub.published)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHandle_EarlyDelayedDeliveryDeferredAgain(t *testing.T) {
+	srv := sendertest.NewServer(t, sendertest.Options{})
+	w, mock := newTestWorker(t, srv)
+	pub := w.Pub.(*fakePublisher)
+
+	// ступень 1m очереди ожидания истекла, а до срока ещё 90s
+	until := w.now().Add(90 * time.Second).UnixMilli()
+	ack := &fakeAck{}
+	w.handle(context.Background(), w.Store.DB, amqp.Delivery{
+		Acknowledger: ack,
+		Headers:      amqp.Table{"x-retries": int32(2), rmq.HeaderDelayUntil: until},
+		Body:         []byte(`{"campaign_id":7,"recipient_id":101,"address":"u1@example.com"}`),
+	})
+
+	if ack.acked != 1 || ack.nacked != 0 {
+		t.Fatalf("want ack, got %+v", ack)
+	}
+	if len(pub.published) != 1 || pub.published[0].delay != 90*time.Second ||
+		headerRetries(pub.published[0].headers) != 2 {
+		t.Fatalf("want deferral for the rest of the delay, got %+v", pub.published)
+	}
+	if n := len(srv.Sessions()); n != 0 {
+		t.Fatalf("deferred message must not be sent, got %d sessions", n)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHandle_AddsUnsubscribeLink(t *testing.T) {
+	srv := sendertest.NewServer(t, sendertest.Options{})
+	w, mock := newTestWorker(t, srv)
+	links := token.NewSigner("secret")
+	w.Opts.Links, w.Opts.PublicURL = links, "https://mail.example.com"
+	tok := links.Sign(token.KindUnsubscribe, 7, 101)
+
+	mock.ExpectQuery(`(?s)SELECT c.body, c.status, m.status.*FROM messages m`).
+		WithArgs(int64(7), int64(101)).
+		WillReturnRows(jobRows().
+			AddRow("Bye: {{.unsubscribe_url}}", "processing", "pending", []byte(`{}`),
+				"", "", "", "", []byte(`{"list-unsubscribe":"<mailto:old@example.com>"}`), "", []byte(`{}`), "", false, false, "text"))
+	expectClaim(mock, 101, true)
+	expectAttempt(mock, 101, 1, "")
+	mock.ExpectExec(`(?s)UPDATE messages.*SET status=.sent.`).
+		WithArgs(int64(7), int64(101), "w1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	ack := &fakeAck{}
+	w.handle(context.Background(), w.Store.DB, amqp.Delivery{
+		Acknowledger: ack,
+		Body:         []byte(`{"campaign_id":7,"recipient_id":101,"address":"u1@example.com"}`),
+	})
+	if ack.acked != 1 {
+		t.Fatalf("want ack, got ack=%d nack=%d", ack.acked, ack.nacked)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+
+	url := "https://mail.example.com/u/" + tok
+	data := srv.Last(t).Data
+	for _, want := range []string{
+		"List-Unsubscribe: <" + url + ">\n",
+		"List-Unsubscribe-Post: List-Unsubscribe=One-Click\n",
+		"\nBye: " + url + "\n",
+	} {
+		if !strings.Contains(data, want) {
+			t.Fatalf("message lacks %q:\n%s", want, data)
+		}
+	}
+	if strings.Contains(data, "mailto:old@example.com") {
+		t.Fatalf("campaign List-Unsubscribe must be replaced:\n%s", data)
+	}
+}
+
+func TestHandle_VERPReturnPath(t *testing.T) {
+	srv := sendertest.NewServer(t, sendertest.Options{})
+	w, mock := newTestWorker(t, srv)
+	links := token.NewSigner("secret")
+	w.Opts.Links, w.Opts.BounceAddress = links, "bounces@mail.example.com"
+
+	mock.ExpectQuery(`(?s)SELECT c.body, c.status, m.status.*FROM messages m`).
+		WithArgs(int64(7), int64(101)).
+		WillReturnRows(jobRows().
+			AddRow("Hi", "processing", "pending", []byte(`{}`),
+				"", "", "team@example.com", "", []byte(`{}`), "", []byte(`{}`), "", false, false, "text"))
+	expectClaim(mock, 101, true)
+	expectAttempt(mock, 101, 1, "")
+	mock.ExpectExec(`(?s)UPDATE messages.*SET status=.sent.`).
+		WithArgs(int64(7), int64(101), "w1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	w.handle(context.Background(), w.Store.DB, amqp.Delivery{
+		Acknowledger: &fakeAck{},
+		Body:         []byte(`{"campaign_id":7,"recipient_id":101,"address":"u1@example.com"}`),
+	})
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+
+	got := srv.Last(t)
+	if got.From != links.VERP("bounces@mail.example.com", 7, 101) {
+		t.Fatalf("mail from: got %q", got.From)
+	}
+	if c, r, err := links.ParseVERP(got.From); err != nil || c != 7 || r != 101 {
+		t.Fatalf("ParseVERP(%q) = %d, %d, %v", got.From, c, r, err)
+	}
+	if !strings.Contains(got.Data, "From: <team@example.com>\n") {
+		t.Fatalf("header From must stay the campaign sender:\n%s", got.Data)
+	}
+	if !strings.Contains(got.Data, "Message-ID: <"+links.MessageID("example.com", 7, 101)+">\n") {
+		t.Fatalf("Message-ID must be signed:\n%s", got.Data)
+	}
+}
+
+func TestHandle_InjectsOpenPixel(t *testing.T) {
+	links := token.NewSigner("secret")
+	for _, trackOpens := range []bool{true, false} {
+		srv := sendertest.NewServer(t, sendertest.Options{})
+		w, mock := newTestWorker(t, srv)
+		w.Opts.Links, w.Opts.PublicURL = links, "https://mail.example.com"
+
+		mock.ExpectQuery(`(?s)SELECT c.body, c.status, m.status.*FROM messages m`).
+			WithArgs(int64(7), int64(101)).
+			WillReturnRows(jobRows().
+				AddRow("<html><body><p>Hi</p></BODY></html>", "processing", "pending", []byte(`{}`),
+					"", "", "", "", []byte(`{}`), "Hi", []byte(`{}`), "", false, trackOpens, "html"))
+		expectClaim(mock, 101, true)
+		expectAttempt(mock, 101, 1, "")
+		mock.ExpectExec(`(?s)UPDATE messages.*SET status=.sent.`).
+			WithArgs(int64(7), int64(101), "w1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		w.handle(context.Background(), w.Store.DB, amqp.Delivery{
+			Acknowledger: &fakeAck{},
+			Body:         []byte(`{"campaign_id":7,"recipient_id":101,"address":"u1@example.com"}`),
+		})
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatal(err)
+		}
+
+		raw, err := io.ReadAll(quotedprintable.NewReader(strings.NewReader(srv.Last(t).Data)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		pixel := `<p>Hi</p><img src="https://mail.example.com/t/o/` + links.Sign(token.KindOpen, 7, 101) + `"`
+		if got := strings.Contains(string(raw), pixel); got != trackOpens {
+			t.Fatalf("track_opens=%v: pixel present=%v:\n%s", trackOpens, got, raw)
+		}
+	}
+}
+
+func TestHandle_HTMLFormatEscapesVars(t *testing.T) {
+	srv := sendertest.NewServer(t, sendertest.Options{})
+	w, mock := newTestWorker(t, srv)
+
+	// тело не начинается с тега, но формат задан кампанией явно
+	mock.ExpectQuery(`(?s)SELECT c.body, c.status, m.status.*FROM messages m`).
+		WithArgs(int64(7), int64(101)).
+		WillReturnRows(jobRows().
+			AddRow("Hi {{.first_name}}", "processing", "pending", []byte(`{"first_name":"<script>x</script>"}`),
+				"", "", "", "", []byte(`{}`), "", []byte(`{}`), "", false, false, "html"))
+	expectClaim(mock, 101, true)
+	expectAttempt(mock, 101, 1, "")
+	mock.ExpectExec(`(?s)UPDATE messages.*SET status=.sent.`).
+		WithArgs(int64(7), int64(101), "w1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	w.handle(context.Background(), w.Store.DB, amqp.Delivery{
+		Acknowledger: &fakeAck{},
+		Body:         []byte(`{"campaign_id":7,"recipient_id":101,"address":"u1@example.com"}`),
+	})
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+
+	data := srv.Last(t).Data
+	if !strings.Contains(data, "Content-Type: text/html") {
+		t.Fatalf("want text/html part:\n%s", data)
+	}
+	raw, err := io.ReadAll(quotedprintable.NewReader(strings.NewReader(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(raw), "<script>") || !strings.Contains(string(raw), "Hi &lt;script&gt;") {
+		t.Fatalf("variable must be HTML-escaped:\n%s", raw)
+	}
+}
+
+func TestWithOpenPixel(t *testing.T) {
+	const img = `<img src="https://x.test/t/o/a?b=1&amp;c=2" width="1" height="1" alt="" style="display:block;border:0;width:1px;height:1px">`
+	cases := map[string]string{
+		"<p>Hi</p>":                        "<p>Hi</p>" + img,
+		"<body>Hi</Body>":                  "<body>Hi" + img + "</Body>",
+		"<body><pre></body></pre></body>x": "<body><pre></body></pre>" + img + "</body>x",
+		"<p>Привет</p></body>":             "<p>Привет</p>" + img + "</body>",
+	}
+	for in, want := range cases {
+		if got := withOpenPixel(in, "https://x.test/t/o/a?b=1&c=2"); got != want {
+			t.Errorf("%q:\n got %q\nwant %q", in, got, want)
+		}
+	}
+}
+
+func TestHandle_LostClaimLeavesMessageAlone(t *testing.T) {
+	cases := map[string]struct {
+		rcpt   string
+		expect func(mock sqlmock.Sqlmock)
+	}{
+		"sent": {
+			expect: func(mock sqlmock.Sqlmock) {
+				expectAttempt(mock, 101, 1, "")
+				mock.ExpectExec(`(?s)UPDATE messages.*SET status=.sent.*status='sending' AND claimed_by=\$3`).
+					WithArgs(int64(7), int64(101), "w1").
+					WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+		},
+		"retry": {
+			rcpt: "451 4.3.0 try later",
+			expect: func(mock sqlmock.Sqlmock) {
+				expectAttempt(mock, 101, 1, "temporary")
+				mock.ExpectExec(`(?s)UPDATE messages.*SET status='pending', last_error=.*status='sending' AND claimed_by=\$6`).
+					WithArgs(sqlmock.AnyArg(), int64(7), int64(101), "temporary", sqlmock.AnyArg(), "w1").
+					WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+		},
+		"failed": {
+			rcpt: "550 5.1.1 no such user",
+			expect: func(mock sqlmock.Sqlmock) {
+				expectAttempt(mock, 101, 1, "permanent")
+				mock.ExpectExec(`(?s)UPDATE messages.*SET status='failed'.*status='sending' AND claimed_by=\$5`).
+					WithArgs(sqlmock.AnyArg(), int64(7), int64(101), "permanent", "w1").
+					WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			opts := sendertest.Options{}
+			if c.rcpt != "" {
+				opts.RcptReplies = map[string]string{"u1@example.com": c.rcpt}
+			}
+			w, mock := newTestWorker(t, sendertest.NewServer(t, opts))
+
+			mock.ExpectQuery(`(?s)SELECT c.body, c.status, m.status`).
+				WithArgs(int64(7), int64(101)).
+				WillReturnRows(jobRows().AddRow("Hi", "processing", "pending", []byte(`{}`), "", "", "", "", []byte(`{}`), "", []byte(`{}`), "", false, false, "text"))
+			expectClaim(mock, 101, true)
+			c.expect(mock)
+
+			ack := &fakeAck{}
+			w.handle(context.Background(), w.Store.DB, amqp.Delivery{
+				Acknowledger: ack,
+				Body:         []byte(`{"campaign_id":7,"recipient_id":101,"address":"u1@example.com"}`),
+			})
+
+			pub := w.Pub.(*fakePublisher)
+			if ack.acked != 1 || ack.nacked != 0 || len(pub.published) != 0 || len(pub.dead) != 0 {
+				t.Fatalf("lost claim must only ack, got ack=%+v published=%d dead=%v", ack, len(pub.published), pub.dead)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}